@@ -1,88 +1,137 @@
 package chat_client
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"github.com/golang/protobuf/proto"
 	"github.com/jroimartin/gocui"
 	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
 	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/block"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/endpoints"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/groups"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/invites"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/ping"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/profile"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/requests"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/stats"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/trust"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/verify"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/core"
+	"github.com/nymtech/demo-mixnet-chat-client/chatconfig"
 	"github.com/nymtech/demo-mixnet-chat-client/gui"
 	"github.com/nymtech/demo-mixnet-chat-client/gui/layout"
-	"github.com/nymtech/demo-mixnet-chat-client/message"
-	"github.com/nymtech/demo-mixnet-chat-client/storage"
 	"github.com/nymtech/demo-mixnet-chat-client/types"
-	"github.com/nymtech/nym-mixnet/client"
-	clientConfig "github.com/nymtech/nym-mixnet/client/config"
-	"path/filepath"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/sirupsen/logrus"
 	"strings"
-	"sync"
 	"time"
 )
 
 const (
-	refreshClientOption = "refresh the list of clients"
-
-	// TODO: create new config.toml or include this in existing client config?
-	defaultStoreFile = "chatstore"
-	defaultStoreDir  = "chat-application"
+	// the message is sent to every member of the group separately, so it might take a while
+	groupSendTimeout = 30 * time.Second
 )
 
 var (
-	ErrNoRecipient = errors.New("no recipient was chosen")
+	ErrNoRecipient        = errors.New("no recipient was chosen")
+	ErrMalformedRecipient = errors.New("malformed recipient data")
 )
 
+// ChatClient is the terminal user interface of the chat client
 type ChatClient struct {
 	session           *types.Session
 	availableCommands []commands.Command
-	chatStore         storage.ChatStore
-	// so we wouldn't need to load it from file storage on every single received message
-	aliasCache map[string]string
-	mixClient  *client.NetClient
-	haltedCh   chan struct{}
-	haltOnce   sync.Once
+	core              *core.Client
+	received          <-chan *types.Received
+	unsubscribe       func()
+	router            *router
+	hideHelpOnStart   bool
+	log               *logrus.Entry
 }
 
-func New(baseClientCfg *clientConfig.Config) (*ChatClient, error) {
-	baseClient, err := client.NewClient(baseClientCfg)
-	if err != nil {
-		return nil, err
-	}
+func New(chatCore *core.Client, displayCfg *chatconfig.Display) *ChatClient {
+	gui.SetStyle(gui.Style{
+		TimeFormat:   displayCfg.TimeFormat,
+		TimeColour:   displayCfg.ColourCode(displayCfg.TimeColour),
+		NoticeColour: displayCfg.ColourCode(displayCfg.NoticeColour),
+		InfoColour:   displayCfg.ColourCode(displayCfg.InfoColour),
+	})
 
-	// TODO: configurable?
-	chatStoreFile := defaultStoreFile
-	chatStoreDir := defaultStoreDir
+	return &ChatClient{
+		core:            chatCore,
+		router:          newRouter(),
+		hideHelpOnStart: displayCfg.HideHelpOnStart,
+		log:             chatCore.Logger().GetLogger("tui"),
+	}
+}
 
-	chatStore, err := storage.NewDbStore(chatStoreFile, filepath.Join(baseClientCfg.Client.FullMixAppsDir(), chatStoreDir))
+// preferredRecipient returns the preferred endpoint of the contact of the recipient
+// and whether it is different from the recipient itself
+func (c *ChatClient) preferredRecipient(recipient config.ClientConfig) (config.ClientConfig, bool) {
+	contactKey, contactProviderKey := c.core.ContactOf(recipient.PubKey, recipient.Provider.PubKey)
+	if bytes.Equal(contactKey, recipient.PubKey) && bytes.Equal(contactProviderKey, recipient.Provider.PubKey) {
+		return recipient, false
+	}
+	preferred, err := c.core.ResolveRecipient(types.Contact{
+		PublicKey:         base64.URLEncoding.EncodeToString(contactKey),
+		ProviderPublicKey: base64.URLEncoding.EncodeToString(contactProviderKey),
+	})
+	if err != nil {
+		// the messages still get to the preferred endpoint, as the core client resolves it on its own
+		return recipient, false
+	}
+	return preferred, true
+}
 
-	cc := &ChatClient{
-		haltedCh:   make(chan struct{}),
-		mixClient:  baseClient,
-		chatStore:  chatStore,
-		aliasCache: make(map[string]string),
+// followPreferredEndpoint moves the session to the preferred endpoint of the contact of the recipient,
+// which might have changed, for example by merging the recipient into another contact.
+func (c *ChatClient) followPreferredEndpoint() {
+	recipient, changed := c.preferredRecipient(c.session.Recipient())
+	if !changed {
+		return
 	}
+	c.session.UpdateRecipient(recipient)
+	if storedAlias := c.core.LookupAlias(recipient.PubKey, recipient.Provider.PubKey); storedAlias != nil && storedAlias.AssignedName != "" {
+		c.session.UpdateAlias(storedAlias.AssignedName)
+	}
+	c.core.SetActiveContact(recipient.PubKey, recipient.Provider.PubKey)
+	// the group stays open until it is closed explicitly
+	if c.session.Group() != "" {
+		return
+	}
+	c.router.Lock()
+	c.router.activeKey = conversationKey(
+		base64.URLEncoding.EncodeToString(recipient.PubKey),
+		base64.URLEncoding.EncodeToString(recipient.Provider.PubKey),
+	)
+	c.router.Unlock()
+}
 
-	return cc, nil
+// OpenGroup sends the messages to the group instead of the recipient and displays its pending messages.
+func (c *ChatClient) OpenGroup(group *types.Group) {
+	c.session.OpenGroup(group.ID, group.Name)
+	c.switchConversation(groupConversationKey(group.ID))
 }
 
-// checkCache makes sure that any entry present in the cache still exists in the store
-// only called on new command execution
-func (c *ChatClient) checkCache() {
-	for k, v := range c.aliasCache {
-		clientKey, clientProviderKey := c.recoverKeysFromCacheKey(k)
-		storedAlias := c.chatStore.GetAlias(clientKey, clientProviderKey)
-		if storedAlias == nil || storedAlias.AssignedName != v {
-			delete(c.aliasCache, k)
-		}
-	}
+// CloseGroup sends the messages to the recipient again and displays its pending messages.
+func (c *ChatClient) CloseGroup() {
+	c.session.CloseGroup()
+	recipient := c.session.Recipient()
+	c.switchConversation(conversationKey(
+		base64.URLEncoding.EncodeToString(recipient.PubKey),
+		base64.URLEncoding.EncodeToString(recipient.Provider.PubKey),
+	))
 }
 
 func (c *ChatClient) updateSession(g *gocui.Gui) error {
+	c.followPreferredEndpoint()
 	if err := c.updateSendViewTitle(g); err != nil {
 		return err
 	}
-	c.checkCache()
+	c.core.RefreshAliasCache()
 
 	return nil
 }
@@ -123,63 +172,76 @@ func (c *ChatClient) parseCommand(g *gocui.Gui, cmd string) error {
 	return nil
 }
 
-func (c *ChatClient) parseReceivedMessages(msgs [][]byte) []*message.ChatMessage {
-	parsedMsgs := make([]*message.ChatMessage, 0, len(msgs))
-	if msgs == nil {
-		return parsedMsgs
-	}
-	for _, msg := range msgs {
-		if msg != nil {
-			parsedMsg := &message.ChatMessage{}
-			if err := proto.Unmarshal(msg, parsedMsg); err == nil {
-				parsedMsgs = append(parsedMsgs, parsedMsg)
-			}
+func (c *ChatClient) handleReceived(g *gocui.Gui, received *types.Received) {
+	switch received.Kind {
+	case types.ReceivedContactRequest:
+		gui.WriteNotice(fmt.Sprintf("New contact %s is trying to reach you. Type /requests to review it.\n",
+			received.Message.Sender,
+		), g, "Request")
+	case types.ReceivedMessage, types.ReceivedGroupMessage:
+		// for now ignore any data in the message apart from the content
+		content := received.Message.Content
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		gui.WriteMessage(content, received.Message.Sender, g)
+	case types.ReceivedPresence:
+		if received.Presence.Online {
+			gui.WriteNotice(fmt.Sprintf("%s is now online\n", received.Presence.Alias), g, "Presence")
 		}
+		// the current recipient might be the one that came or went
+		g.Update(c.updateSendViewTitle)
+	case types.ReceivedKeyChange:
+		gui.WriteWarning(fmt.Sprintf("%s Type /trust to review it.\n", trust.Describe(received.KeyChange)), g)
+	case types.ReceivedProfile:
+		c.showProfile(g, received.Profile)
+	case types.ReceivedGroupChange:
+		gui.WriteNotice(groups.DescribeChange(received.GroupChange, base64.URLEncoding.EncodeToString(c.core.PublicKey())), g, "Group")
+		c.followGroupChange(g, received.GroupChange)
 	}
-
-	// for now completely ignore ordering
-	return parsedMsgs
 }
 
-func (c *ChatClient) pollForMessages(g *gocui.Gui, sessionHalt <-chan struct{}) {
-	time.Sleep(time.Second) // to make sure the main loop of gui starts first; TODO: better solution
-	heartbeat := time.NewTicker(50 * time.Millisecond)
-	// note: this does not perform any external queries,
-	// it just checks the buffer of NetClient for whether it has any messages
-	for {
-		select {
-		case <-c.haltedCh:
-			return
-		case <-sessionHalt:
-			return
-		case <-heartbeat.C:
-			msgs := c.mixClient.GetReceivedMessages()
-			if len(msgs) > 0 {
-				parsedMsgs := c.parseReceivedMessages(msgs)
-				for _, msg := range parsedMsgs {
-					// for now ignore any data in the message apart from the content
-					content := string(msg.Content)
-					if !strings.HasSuffix(content, "\n") {
-						content += "\n"
-					}
-					gui.WriteMessage(content, c.getDisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey), g)
-				}
-			}
-		}
+// followGroupChange keeps the session up to date with the open group, which might have been renamed
+// or which we might have been removed from. It must be called with the router lock held.
+func (c *ChatClient) followGroupChange(g *gocui.Gui, change *types.GroupChange) {
+	if change.Group.ID != c.session.Group() {
+		return
+	}
+	removed := change.Kind == types.GroupMemberRemoved || change.Kind == types.GroupMemberBanned
+	if removed && change.Member.PublicKey == base64.URLEncoding.EncodeToString(c.core.PublicKey()) {
+		c.session.CloseGroup()
+		recipient := c.session.Recipient()
+		c.router.activeKey = conversationKey(
+			base64.URLEncoding.EncodeToString(recipient.PubKey),
+			base64.URLEncoding.EncodeToString(recipient.Provider.PubKey),
+		)
+		gui.WriteNotice(fmt.Sprintf("You're currently sending messages to: %s\n", c.session.RecipientAlias()), g)
+		c.showPending(g)
+	} else {
+		c.session.OpenGroup(change.Group.ID, change.Group.Name)
 	}
+	g.Update(c.updateSendViewTitle)
 }
 
-func (c *ChatClient) createMessagePayload(msg string) ([]byte, error) {
-	protoPayload := &message.ChatMessage{
-		Content:                 []byte(msg),
-		SenderPublicKey:         c.mixClient.GetPublicKey().Bytes(),
-		SenderProviderPublicKey: c.mixClient.Provider.PubKey,
-		MessageNonce:            c.session.IncrementNonce(),
-		SenderTimestamp:         time.Now().UnixNano(),
-		Signature:               nil, // will be done later
+// showProfile tells the user about the updated profile of the contact, offering its name as the alias.
+// It must be called with the router lock held.
+func (c *ChatClient) showProfile(g *gocui.Gui, contactProfile *types.ContactProfile) {
+	publicKey, _ := base64.URLEncoding.DecodeString(contactProfile.PublicKey)
+	providerPublicKey, _ := base64.URLEncoding.DecodeString(contactProfile.ProviderPublicKey)
+	name := c.core.DisplayName(publicKey, providerPublicKey)
+	isRecipient := conversationKey(contactProfile.PublicKey, contactProfile.ProviderPublicKey) == c.router.activeKey
+
+	// the alias is never replaced without the user asking for it
+	if suggested := alias.SuggestedName(contactProfile.Name); suggested != "" && suggested != contactProfile.Alias {
+		gui.WriteNotice(fmt.Sprintf("%s would like to be called %q. Type %s to use it as their alias.\n",
+			name,
+			contactProfile.Name,
+			profile.AcceptNickUsage(contactProfile.Contact, isRecipient),
+		), g, "Profile")
+	}
+	if contactProfile.Status != "" {
+		gui.WriteNotice(fmt.Sprintf("%s's status: %s\n", name, contactProfile.Status), g, "Profile")
 	}
-
-	return proto.Marshal(protoPayload)
 }
 
 func (c *ChatClient) handleSend(g *gocui.Gui, v *gocui.View) error {
@@ -188,7 +250,7 @@ func (c *ChatClient) handleSend(g *gocui.Gui, v *gocui.View) error {
 	}
 	defer func() {
 		if err := c.resetView(v); err != nil {
-			// log.../
+			c.log.WithError(err).Error("Failed to reset the input view")
 		}
 	}()
 
@@ -199,15 +261,12 @@ func (c *ChatClient) handleSend(g *gocui.Gui, v *gocui.View) error {
 		return c.parseCommand(g, rawMsg)
 	}
 
-	chatMsg, err := c.createMessagePayload(rawMsg)
-	if err != nil {
-		// todo: handle
+	if groupID := c.session.Group(); groupID != "" {
+		go c.sendToGroup(g, groupID, rawMsg)
+	} else if err := c.core.SendTo(context.Background(), c.session.Recipient(), rawMsg); err != nil {
+		// the details are already logged by the core client
+		gui.WriteNotice("Could not send message. Press F2 to see the details.\n", g, "ERROR")
 	}
-	if err := c.mixClient.SendMessage(chatMsg, c.session.Recipient()); err != nil {
-		// log
-		gui.WriteNotice("Could not send message", g, "ERROR")
-	}
-
 
 	msg := rawMsg
 	if !strings.HasSuffix(msg, "\n") {
@@ -219,74 +278,185 @@ func (c *ChatClient) handleSend(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+// sendToGroup sends the message to the group in the background, as it is sent to each of the members separately,
+// and reports the members it could not be sent to once it is done
+func (c *ChatClient) sendToGroup(g *gocui.Gui, groupID string, content string) {
+	ctx, cancel := context.WithTimeout(context.Background(), groupSendTimeout)
+	defer cancel()
+	err := c.core.SendToGroup(ctx, groupID, content)
+	if err == nil {
+		return
+	}
+	g.Update(func(g *gocui.Gui) error {
+		sendErr, ok := err.(*core.GroupSendError)
+		if !ok {
+			gui.WriteNotice(fmt.Sprintf("Could not send message: %v. Press F2 to see the details.\n", err), g, "ERROR")
+			return nil
+		}
+		for _, member := range sendErr.Failed {
+			gui.WriteNotice(fmt.Sprintf("Could not send message to %s. Press F2 to see the details.\n", groups.MemberName(member)), g, "ERROR")
+		}
+		if !sendErr.Stored {
+			gui.WriteNotice("The message was not sent to anybody, so it is not kept in the conversation.\n", g, "ERROR")
+		}
+		return nil
+	})
+}
+
+func (c *ChatClient) toggleDebug(g *gocui.Gui, v *gocui.View) error {
+	if err := layout.ToggleDebugView(g, v); err != nil {
+		return err
+	}
+	// the view is only going to be created during the next layout, so fill it afterwards
+	gui.ResetDebug(g, c.core.Logger().Recent().Lines)
+	return nil
+}
+
 func (c *ChatClient) initKeybindings(g *gocui.Gui) error {
 
 	if err := g.SetKeybinding(layout.InputViewName, gocui.KeyEnter, gocui.ModNone, c.handleSend); err != nil {
 		return err
 	}
 
+	if err := g.SetKeybinding("", gocui.KeyF2, gocui.ModNone, c.toggleDebug); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (c *ChatClient) initCommands(g *gocui.Gui) {
+	chatStore := c.core.Store()
 	c.availableCommands = []commands.Command{
-		alias.AliasCommand(g, c.chatStore, c.session),
+		alias.AliasCommand(g, chatStore, chatStore, c.core, c.session),
+		requests.RequestsCommand(g, chatStore),
+		requests.AcceptCommand(g, chatStore, chatStore, c.session),
+		requests.IgnoreCommand(g, chatStore),
+		block.BlockCommand(g, chatStore, chatStore, c.session),
+		block.MuteCommand(g, chatStore, chatStore, c.session),
+		block.BlockedCommand(g, chatStore, chatStore),
+		stats.StatsCommand(g, c.core),
+		ping.PingCommand(g, c.core, c.session),
+		invites.InviteCommand(g, c.core),
+		invites.QRCommand(g, c.core),
+		invites.AddContactCommand(g, chatStore, c.core, c.core, c.session),
+		verify.VerifyCommand(g, chatStore, chatStore, c.core, c.session),
+		trust.TrustCommand(g, c.core),
+		endpoints.MergeCommand(g, c.core, c.session),
+		endpoints.EndpointsCommand(g, c.core, c.session),
+		profile.NickCommand(g, c.core),
+		profile.StatusCommand(g, c.core),
+		profile.AcceptNickCommand(g, c.core, c.session),
+		groups.GroupCommand(g, c.core, c, c.session),
 	}
 }
 
-func (c *ChatClient) startNewChatSession(sessionHalt chan struct{}) error {
-	recipient, err := c.getRecipient()
-	if err != nil {
-		return err
-	}
-	storedAlias := c.tryAliasStore(recipient.PubKey, recipient.Provider.PubKey)
+// openConversation replaces the recipient picker with the chat views. It is called from within the main loop of the gui.
+func (c *ChatClient) openConversation(g *gocui.Gui, recipient config.ClientConfig, sessionHalt chan struct{}) error {
+	// conversations are held with the preferred endpoint of the contact
+	recipient, _ = c.preferredRecipient(recipient)
+	storedAlias := c.core.LookupAlias(recipient.PubKey, recipient.Provider.PubKey)
 
+	b64Key := base64.URLEncoding.EncodeToString(recipient.PubKey)
 	fullRecipientName := ""
 	if storedAlias == nil || storedAlias.AssignedName == "" {
-		fullRecipientName = base64.URLEncoding.EncodeToString(recipient.PubKey)
+		fullRecipientName = b64Key
 	} else {
 		fullRecipientName = storedAlias.AssignedName
 	}
 
 	c.session = types.NewSession(recipient, fullRecipientName)
+	c.core.SetActiveContact(recipient.PubKey, recipient.Provider.PubKey)
 
-	g, err := gui.CreateGUI()
+	guiReady, err := gui.ShowChat(g)
 	if err != nil {
 		return err
 	}
-	defer g.Close()
-
 	if err := c.initKeybindings(g); err != nil {
 		return err
 	}
 	c.initCommands(g)
 
-	// initial notices
-	g.Update(func(g *gocui.Gui) error {
-		b64Key := base64.URLEncoding.EncodeToString(c.mixClient.GetPublicKey().Bytes())
-		gui.WriteNotice(fmt.Sprintf("Your public key is: %s Share it off channel with anyone you wish to communicate with.\n",
-			b64Key,
-		), g, "Reminder")
+	b64ProviderKey := base64.URLEncoding.EncodeToString(recipient.Provider.PubKey)
+	go func() {
+		// do not write anything to the gui before we are able to actually display it
+		select {
+		case <-guiReady:
+		case <-sessionHalt:
+			return
+		}
 
-		gui.WriteNotice(fmt.Sprintf("You're currently sending messages to: %s\n",
-			fullRecipientName,
-		), g, "Reminder")
-		c.showAvailableCommands(g)
+		// initial notices
+		g.Update(func(g *gocui.Gui) error {
+			ourB64Key := base64.URLEncoding.EncodeToString(c.core.PublicKey())
+			gui.WriteNotice(fmt.Sprintf("Your public key is: %s Share it off channel with anyone you wish to communicate with, or type /invite to get a single code to share.\n",
+				ourB64Key,
+			), g, "Reminder")
+
+			gui.WriteNotice(fmt.Sprintf("You're currently sending messages to: %s\n",
+				fullRecipientName,
+			), g, "Reminder")
+			gui.WriteNotice("Press F2 to show or hide the debug log.\n", g, "Reminder")
+			if !c.hideHelpOnStart {
+				c.showAvailableCommands(g)
+			}
 
-		return c.updateSession(g)
-	})
+			return c.updateSession(g)
+		})
 
-	go c.pollForMessages(g, sessionHalt)
+		c.activateSession(g, b64Key, b64ProviderKey, sessionHalt)
+		// the debug view might have been left open in the previous session
+		gui.ResetDebug(g, c.core.Logger().Recent().Lines)
+	}()
+
+	c.log.WithField("recipient", fullRecipientName).Info("Started new chat session")
+	return nil
+}
+
+// startNewChatSession lets the user choose the recipient and then chat with them until the session is quit.
+func (c *ChatClient) startNewChatSession(sessionHalt chan struct{}) error {
+	// the session must be halted before the gui stops receiving messages
+	defer c.deactivateSession()
+	defer close(sessionHalt)
+	defer c.core.SetActiveContact(nil, nil)
+
+	g, err := gui.CreateGUI()
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	recipientPicker := c.newRecipientPicker(sessionHalt)
+	defer recipientPicker.Stop()
+	if err := recipientPicker.Show(g); err != nil {
+		return err
+	}
+	c.session = nil
+
+	stopDebugOutput := c.core.Logger().Recent().Listen(func(line string) {
+		gui.WriteDebug(g, line)
+	})
+	defer stopDebugOutput()
 
 	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
+		c.log.WithError(err).Error("Chat session failed")
 		return err
 	}
 
+	// quitting the picker itself means the user does not want to talk to anybody
+	if c.session == nil {
+		return ErrNoRecipient
+	}
 	return nil
 }
 
 func (c *ChatClient) Run() error {
-	if err := c.mixClient.Start(); err != nil {
+	// subscribe for the whole lifetime of the client so that nothing received between sessions is lost,
+	// including whatever arrives as soon as it is started
+	c.received, c.unsubscribe = c.core.Subscribe()
+	go c.routeReceived()
+	if err := c.core.Start(); err != nil {
+		c.unsubscribe()
 		return err
 	}
 
@@ -305,19 +475,13 @@ func (c *ChatClient) Run() error {
 
 // Wait waits till the client is terminated for any reason.
 func (c *ChatClient) Wait() {
-	<-c.haltedCh
+	c.core.Wait()
 }
 
 // Shutdown cleanly shuts down a given client instance.
 func (c *ChatClient) Shutdown() {
-	c.haltOnce.Do(func() { c.halt() })
-}
-
-// calls any required cleanup code
-func (c *ChatClient) halt() {
-	//c.log.Infof("Starting graceful shutdown")
-	// close any listeners, free resources, etc
-	c.mixClient.Shutdown()
-
-	close(c.haltedCh)
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+	}
+	c.core.Shutdown()
 }
@@ -11,6 +11,8 @@ import (
 	"github.com/nymtech/demo-mixnet-chat-client/types"
 	"github.com/nymtech/demo-mixnet-chat-client/utils"
 	"github.com/nymtech/nym-mixnet/sphinx"
+	"strings"
+	"time"
 )
 
 const (
@@ -19,7 +21,11 @@ const (
 	showSubCommand   = "show"
 	removeSubCommand = "remove"
 	addSubCommand    = "add"
+	noteSubCommand   = "note"
+	favSubCommand    = "favourite"
 	allModifier      = "all"
+
+	lastSeenFormat = "2006-01-02 15:04"
 )
 
 var (
@@ -34,6 +40,8 @@ var (
 		showSubCommand,
 		removeSubCommand,
 		addSubCommand,
+		noteSubCommand,
+		favSubCommand,
 		allModifier,
 	}
 )
@@ -48,6 +56,19 @@ type AliasStore interface {
 	RemoveAllAliases()
 }
 
+// DetailsStore keeps additional details of the clients that help to find them
+type DetailsStore interface {
+	SetNote(*sphinx.PublicKey, *sphinx.PublicKey, string)
+	GetNote(*sphinx.PublicKey, *sphinx.PublicKey) string
+	SetFavourite(*sphinx.PublicKey, *sphinx.PublicKey, bool)
+	IsFavourite(*sphinx.PublicKey, *sphinx.PublicKey) bool
+}
+
+// PresenceSource tells whether the clients are present in the network and when they were last seen
+type PresenceSource interface {
+	Presence(publicKey, providerPublicKey []byte) *types.Presence
+}
+
 type Alias struct {
 	AssignedName      string
 	PublicKey         *sphinx.PublicKey
@@ -64,10 +85,24 @@ func (a *Alias) String() string {
 	return fmt.Sprintf("Alias: %s - Public Key: %s Provider's Public Key: %s", assignedName, b64Key, b64ProvKey)
 }
 
+// PresenceString describes the presence of the client in a human readable form
+func PresenceString(presence *types.Presence) string {
+	switch {
+	case presence.Online:
+		return "online"
+	case presence.LastSeen.IsZero():
+		return "offline, never seen"
+	default:
+		return fmt.Sprintf("offline, last seen %s", presence.LastSeen.Format(lastSeenFormat))
+	}
+}
+
 type AliasCmd struct {
-	g       *gocui.Gui
-	store   AliasStore
-	session *types.Session
+	g        *gocui.Gui
+	store    AliasStore
+	details  DetailsStore
+	presence PresenceSource
+	session  *types.Session
 
 	// TODO: possible set of subcommands? so separate explicit handlers for remove, add, show, etc
 	//subCommands []commands.Command
@@ -78,19 +113,11 @@ func (a *AliasCmd) getCurrentRecipientKeys() (*sphinx.PublicKey, *sphinx.PublicK
 }
 
 func (a *AliasCmd) getTargetKeysFromStrings(targetKey, targetProvKey string) (*sphinx.PublicKey, *sphinx.PublicKey) {
-	targetKeyB, err := base64.URLEncoding.DecodeString(targetKey)
-	if err != nil {
-		return nil, nil
-	}
-	targetProvKeyB, err := base64.URLEncoding.DecodeString(targetProvKey)
-	if err != nil {
-		return nil, nil
-	}
-
-	return utils.KeysFromBytes(targetKeyB, targetProvKeyB)
+	return utils.KeysFromB64Strings(targetKey, targetProvKey)
 }
 
-func checkIfValidName(name string) bool {
+// IsValidName checks whether the name can be assigned as an alias
+func IsValidName(name string) bool {
 	for _, invalidAlias := range forbiddenAliases {
 		if name == invalidAlias {
 			return false
@@ -99,6 +126,27 @@ func checkIfValidName(name string) bool {
 	return true
 }
 
+// SuggestedName turns the name a client suggests for itself into something that can be used as an alias
+// in the commands, i.e. without any whitespace. It is empty if no name was suggested.
+func SuggestedName(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+func (a *AliasCmd) describe(alias *Alias) string {
+	presence := a.presence.Presence(alias.PublicKey.Bytes(), alias.ProviderPublicKey.Bytes())
+	description := fmt.Sprintf("%s - %s", alias.String(), PresenceString(presence))
+	if presence.Online && !presence.Since.IsZero() {
+		description += fmt.Sprintf(" (for %s)", time.Since(presence.Since).Round(time.Second))
+	}
+	if a.details.IsFavourite(alias.PublicKey, alias.ProviderPublicKey) {
+		description += " - favourite"
+	}
+	if note := a.details.GetNote(alias.PublicKey, alias.ProviderPublicKey); note != "" {
+		description += fmt.Sprintf(" - Note: %s", note)
+	}
+	return description + "\n"
+}
+
 func (a *AliasCmd) Name() string {
 	return commandName
 }
@@ -114,6 +162,8 @@ func (a *AliasCmd) Usage() string {
 	usageString += fmt.Sprintf("\t\t - /%s %s\n", commandName, showSubCommand)
 	usageString += fmt.Sprintf("\t\t - /%s %s <aliased_name>\n", commandName, showSubCommand)
 	usageString += fmt.Sprintf("\t\t - /%s %s all\n", commandName, showSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <text>\n", commandName, noteSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", commandName, favSubCommand)
 	return usageString
 }
 
@@ -171,7 +221,7 @@ func (a *AliasCmd) handleAdd(args []string) error {
 	case 1:
 		return ErrNotEnoughArguments
 	case 2:
-		if !checkIfValidName(args[1]) {
+		if !IsValidName(args[1]) {
 			return ErrInvalidArguments
 		}
 		currentPub, currentProvPub := a.getCurrentRecipientKeys()
@@ -189,7 +239,7 @@ func (a *AliasCmd) handleAdd(args []string) error {
 			return errors.New("malformed recipient data")
 		}
 	case 4:
-		if !checkIfValidName(args[3]) {
+		if !IsValidName(args[3]) {
 			return ErrInvalidArguments
 		}
 		targetKey, targetProvKey := a.getTargetKeysFromStrings(args[1], args[2])
@@ -225,7 +275,7 @@ func (a *AliasCmd) handleShow(args []string) error {
 		currentPub, currentProvPub := a.getCurrentRecipientKeys()
 		if currentPub != nil && currentProvPub != nil {
 			currentAlias := a.store.GetAlias(currentPub, currentProvPub)
-			gui.WriteInfo(currentAlias.String()+"\n", a.g, "alias_info")
+			gui.WriteInfo(a.describe(currentAlias), a.g, "alias_info")
 			return nil
 		}
 		return ErrMalformedRecipient
@@ -244,7 +294,7 @@ func (a *AliasCmd) handleShow(args []string) error {
 		}
 
 		for _, alias := range aliases {
-			gui.WriteInfo(alias.String()+"\n", a.g, "alias_info")
+			gui.WriteInfo(a.describe(alias), a.g, "alias_info")
 		}
 		return nil
 
@@ -253,6 +303,43 @@ func (a *AliasCmd) handleShow(args []string) error {
 	}
 }
 
+// we expect the following:
+// `note <text>` which will attach the note to the current recipient, so that it could be searched for
+// just `note` which will remove it
+func (a *AliasCmd) handleNote(args []string) error {
+	currentPub, currentProvPub := a.getCurrentRecipientKeys()
+	if currentPub == nil || currentProvPub == nil {
+		return ErrMalformedRecipient
+	}
+	note := strings.TrimSpace(strings.Join(args[1:], " "))
+	a.details.SetNote(currentPub, currentProvPub, note)
+	if note == "" {
+		gui.WriteNotice("removed the note of current recipient\n", a.g)
+	} else {
+		gui.WriteNotice(fmt.Sprintf("attached note to current recipient: %s\n", note), a.g)
+	}
+	return nil
+}
+
+// we expect just `favourite` which will toggle whether the current recipient is listed amongst favourites
+func (a *AliasCmd) handleFavourite(args []string) error {
+	if len(args) != 1 {
+		return ErrInvalidArguments
+	}
+	currentPub, currentProvPub := a.getCurrentRecipientKeys()
+	if currentPub == nil || currentProvPub == nil {
+		return ErrMalformedRecipient
+	}
+	favourite := !a.details.IsFavourite(currentPub, currentProvPub)
+	a.details.SetFavourite(currentPub, currentProvPub, favourite)
+	if favourite {
+		gui.WriteNotice("added current recipient to favourites\n", a.g)
+	} else {
+		gui.WriteNotice("removed current recipient from favourites\n", a.g)
+	}
+	return nil
+}
+
 func (a *AliasCmd) Handle(args []string) error {
 	// first element in the slice is the name of the command itself and always exists
 	if len(args) == 1 {
@@ -269,6 +356,10 @@ func (a *AliasCmd) Handle(args []string) error {
 		return a.handleRemove(args[1:])
 	case showSubCommand:
 		return a.handleShow(args[1:])
+	case noteSubCommand:
+		return a.handleNote(args[1:])
+	case favSubCommand:
+		return a.handleFavourite(args[1:])
 	default:
 		fmt.Println(args[1])
 		return ErrInvalidArguments
@@ -277,10 +368,12 @@ func (a *AliasCmd) Handle(args []string) error {
 
 // AliasCommand creates new instance of an AliasCommand
 // Each equivalent function for each command will take required context to resolve the command
-func AliasCommand(g *gocui.Gui, store AliasStore, session *types.Session) commands.Command {
+func AliasCommand(g *gocui.Gui, store AliasStore, details DetailsStore, presence PresenceSource, session *types.Session) commands.Command {
 	return &AliasCmd{
-		g:       g,
-		store:   store,
-		session: session,
+		g:        g,
+		store:    store,
+		details:  details,
+		presence: presence,
+		session:  session,
 	}
 }
@@ -0,0 +1,272 @@
+package block
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"strconv"
+)
+
+const (
+	blockCommandName   = "block"
+	muteCommandName    = "mute"
+	blockedCommandName = "blocked"
+	unblockSubCommand  = "unblock"
+	unmuteSubCommand   = "unmute"
+	allModifier        = "all"
+)
+
+var (
+	ErrNotEnoughArguments = errors.New("block command did not receive enough arguments")
+	ErrInvalidArguments   = errors.New("block command received invalid arguments")
+	ErrMalformedRecipient = errors.New("malformed recipient data")
+	ErrNoSuchAlias        = errors.New("there are no clients with given alias")
+	ErrNoSuchEntry        = errors.New("there is no entry with given number")
+)
+
+// BlockStore keeps track of the clients whose messages are dropped (blocked)
+// or stored without being displayed (muted)
+type BlockStore interface {
+	Block(*sphinx.PublicKey, *sphinx.PublicKey)
+	Unblock(*sphinx.PublicKey, *sphinx.PublicKey)
+	IsBlocked(*sphinx.PublicKey, *sphinx.PublicKey) bool
+	GetAllBlocked() []*Entry
+	UnblockAll()
+
+	Mute(*sphinx.PublicKey, *sphinx.PublicKey)
+	Unmute(*sphinx.PublicKey, *sphinx.PublicKey)
+	IsMuted(*sphinx.PublicKey, *sphinx.PublicKey) bool
+	GetAllMuted() []*Entry
+	UnmuteAll()
+}
+
+// Entry represents a single blocked or muted client
+type Entry struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+}
+
+func entryString(entry *Entry, aliasStore alias.AliasStore) string {
+	b64Key := base64.URLEncoding.EncodeToString(entry.PublicKey.Bytes())
+	b64ProvKey := base64.URLEncoding.EncodeToString(entry.ProviderPublicKey.Bytes())
+	assignedName := "<none>"
+	if storedAlias := aliasStore.GetAlias(entry.PublicKey, entry.ProviderPublicKey); storedAlias != nil && storedAlias.AssignedName != "" {
+		assignedName = storedAlias.AssignedName
+	}
+	return fmt.Sprintf("Alias: %s - Public Key: %s Provider's Public Key: %s", assignedName, b64Key, b64ProvKey)
+}
+
+// BlockCmd handles both `block` and `mute` commands as they only differ in the store method being called
+type BlockCmd struct {
+	g          *gocui.Gui
+	store      BlockStore
+	aliasStore alias.AliasStore
+	session    *types.Session
+	name       string
+}
+
+func (b *BlockCmd) Name() string {
+	return b.name
+}
+
+func (b *BlockCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", b.name)
+	usageString += fmt.Sprintf("\t\t - /%s\n", b.name)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", b.name)
+	usageString += fmt.Sprintf("\t\t - /%s <b64_public_key> <b64_provider_public_key>\n", b.name)
+	return usageString
+}
+
+// we expect the following:
+// just `block` which will block the current recipient
+// `block <alias>` which will block all clients with the specified alias
+// `block <pubkey> <provider_pubkey>` which will block the specified client. note: both keys have to be provided in base64
+func (b *BlockCmd) resolveTargets(args []string) ([]*Entry, error) {
+	switch len(args) {
+	case 1:
+		currentPub, currentProvPub := utils.KeysFromBytes(b.session.Recipient().PubKey, b.session.Recipient().Provider.PubKey)
+		if currentPub == nil || currentProvPub == nil {
+			return nil, ErrMalformedRecipient
+		}
+		return []*Entry{{PublicKey: currentPub, ProviderPublicKey: currentProvPub}}, nil
+	case 2:
+		aliases := b.aliasStore.GetAllAliasesByName(args[1])
+		if len(aliases) == 0 {
+			return nil, ErrNoSuchAlias
+		}
+		targets := make([]*Entry, len(aliases))
+		for i, storedAlias := range aliases {
+			targets[i] = &Entry{PublicKey: storedAlias.PublicKey, ProviderPublicKey: storedAlias.ProviderPublicKey}
+		}
+		return targets, nil
+	case 3:
+		targetKey, targetProvKey := utils.KeysFromB64Strings(args[1], args[2])
+		if targetKey == nil || targetProvKey == nil {
+			return nil, ErrInvalidArguments
+		}
+		return []*Entry{{PublicKey: targetKey, ProviderPublicKey: targetProvKey}}, nil
+	default:
+		return nil, ErrInvalidArguments
+	}
+}
+
+func (b *BlockCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != b.name {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", b.Name(), args[0])
+	}
+	targets, err := b.resolveTargets(args)
+	if err != nil {
+		if err == ErrNoSuchAlias {
+			gui.WriteInfo(fmt.Sprintf("no clients with alias: %s\n", args[1]), b.g, b.name)
+		}
+		return err
+	}
+
+	for _, target := range targets {
+		if b.name == blockCommandName {
+			gui.WriteNotice(fmt.Sprintf("blocking: %s\n", entryString(target, b.aliasStore)), b.g)
+			b.store.Block(target.PublicKey, target.ProviderPublicKey)
+		} else {
+			gui.WriteNotice(fmt.Sprintf("muting: %s\n", entryString(target, b.aliasStore)), b.g)
+			b.store.Mute(target.PublicKey, target.ProviderPublicKey)
+		}
+	}
+	return nil
+}
+
+type BlockedCmd struct {
+	g          *gocui.Gui
+	store      BlockStore
+	aliasStore alias.AliasStore
+}
+
+func (b *BlockedCmd) Name() string {
+	return blockedCommandName
+}
+
+func (b *BlockedCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", blockedCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", blockedCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s %s <number>\n", blockedCommandName, unblockSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s all\n", blockedCommandName, unblockSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <number>\n", blockedCommandName, unmuteSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s all\n", blockedCommandName, unmuteSubCommand)
+	return usageString
+}
+
+func (b *BlockedCmd) showEntries() {
+	blocked := b.store.GetAllBlocked()
+	muted := b.store.GetAllMuted()
+	if len(blocked) == 0 && len(muted) == 0 {
+		gui.WriteInfo("no blocked or muted clients\n", b.g, blockedCommandName)
+		return
+	}
+	for i, entry := range blocked {
+		gui.WriteInfo(fmt.Sprintf("[%d] %s\n", i+1, entryString(entry, b.aliasStore)), b.g, "blocked")
+	}
+	for i, entry := range muted {
+		gui.WriteInfo(fmt.Sprintf("[%d] %s\n", i+1, entryString(entry, b.aliasStore)), b.g, "muted")
+	}
+}
+
+// entries are referred to by their position on the list printed by `/blocked`, starting from 1
+func getEntryByNumber(entries []*Entry, number string) (*Entry, error) {
+	i, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, ErrInvalidArguments
+	}
+	if i < 1 || i > len(entries) {
+		return nil, ErrNoSuchEntry
+	}
+	return entries[i-1], nil
+}
+
+// we expect the following:
+// just `blocked` which will list all blocked and muted clients
+// `blocked unblock <number>` or `blocked unmute <number>` which will lift the restriction for the specified entry
+// `blocked unblock all` or `blocked unmute all` which will lift the restriction for everybody
+func (b *BlockedCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != blockedCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", b.Name(), args[0])
+	}
+	switch len(args) {
+	case 1:
+		b.showEntries()
+		return nil
+	case 3:
+		switch args[1] {
+		case unblockSubCommand:
+			if args[2] == allModifier {
+				gui.WriteNotice("unblocking ALL clients\n", b.g)
+				b.store.UnblockAll()
+				return nil
+			}
+			entry, err := getEntryByNumber(b.store.GetAllBlocked(), args[2])
+			if err != nil {
+				return err
+			}
+			gui.WriteNotice(fmt.Sprintf("unblocking: %s\n", entryString(entry, b.aliasStore)), b.g)
+			b.store.Unblock(entry.PublicKey, entry.ProviderPublicKey)
+			return nil
+		case unmuteSubCommand:
+			if args[2] == allModifier {
+				gui.WriteNotice("unmuting ALL clients\n", b.g)
+				b.store.UnmuteAll()
+				return nil
+			}
+			entry, err := getEntryByNumber(b.store.GetAllMuted(), args[2])
+			if err != nil {
+				return err
+			}
+			gui.WriteNotice(fmt.Sprintf("unmuting: %s\n", entryString(entry, b.aliasStore)), b.g)
+			b.store.Unmute(entry.PublicKey, entry.ProviderPublicKey)
+			return nil
+		default:
+			return ErrInvalidArguments
+		}
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+// BlockCommand creates new instance of a BlockCommand
+func BlockCommand(g *gocui.Gui, store BlockStore, aliasStore alias.AliasStore, session *types.Session) commands.Command {
+	return &BlockCmd{
+		g:          g,
+		store:      store,
+		aliasStore: aliasStore,
+		session:    session,
+		name:       blockCommandName,
+	}
+}
+
+// MuteCommand creates new instance of a MuteCommand
+func MuteCommand(g *gocui.Gui, store BlockStore, aliasStore alias.AliasStore, session *types.Session) commands.Command {
+	return &BlockCmd{
+		g:          g,
+		store:      store,
+		aliasStore: aliasStore,
+		session:    session,
+		name:       muteCommandName,
+	}
+}
+
+// BlockedCommand creates new instance of a BlockedCommand
+func BlockedCommand(g *gocui.Gui, store BlockStore, aliasStore alias.AliasStore) commands.Command {
+	return &BlockedCmd{
+		g:          g,
+		store:      store,
+		aliasStore: aliasStore,
+	}
+}
@@ -0,0 +1,224 @@
+package endpoints
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"strconv"
+)
+
+const (
+	endpointsCommandName  = "endpoints"
+	mergeCommandName      = "merge"
+	preferSubCommand      = "prefer"
+	removeSubCommand      = "remove"
+	endpointsInfoCategory = "endpoints"
+)
+
+var (
+	ErrInvalidArguments = errors.New("endpoints command received invalid arguments")
+	ErrNoSuchEndpoint   = errors.New("the contact has no endpoint with given number")
+	ErrPreferredRemoval = errors.New("the preferred endpoint can't be removed, prefer another one first")
+)
+
+// Contacts manages the endpoints of the contacts
+type Contacts interface {
+	Endpoints(target types.Contact) ([]*types.Endpoint, error)
+	MergeContacts(into, from types.Contact) error
+	PreferEndpoint(endpoint types.Contact) error
+	SplitEndpoint(endpoint types.Contact) error
+}
+
+func currentRecipient(session *types.Session) types.Contact {
+	return types.Contact{
+		PublicKey:         base64.URLEncoding.EncodeToString(session.Recipient().PubKey),
+		ProviderPublicKey: base64.URLEncoding.EncodeToString(session.Recipient().Provider.PubKey),
+	}
+}
+
+type EndpointsCmd struct {
+	g        *gocui.Gui
+	contacts Contacts
+	session  *types.Session
+}
+
+func (e *EndpointsCmd) Name() string {
+	return endpointsCommandName
+}
+
+func (e *EndpointsCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", endpointsCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", endpointsCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", endpointsCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s %s <endpoint_number>\n", endpointsCommandName, preferSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <endpoint_number>\n", endpointsCommandName, removeSubCommand)
+	return usageString
+}
+
+func (e *EndpointsCmd) list(name string, target types.Contact) {
+	endpoints, err := e.contacts.Endpoints(target)
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not list the endpoints of %s: %v\n", name, err), e.g, "error")
+		return
+	}
+	for i, endpoint := range endpoints {
+		preferred := ""
+		if endpoint.Preferred {
+			preferred = " (preferred)"
+		}
+		gui.WriteInfo(fmt.Sprintf("[%d] Public Key: %s Provider's Public Key: %s%s\n",
+			i+1,
+			endpoint.PublicKey,
+			endpoint.ProviderPublicKey,
+			preferred,
+		), e.g, endpointsInfoCategory)
+	}
+}
+
+// endpoints are referred to by their position on the list printed by `/endpoints` for the current recipient,
+// starting from 1
+func (e *EndpointsCmd) getEndpointByNumber(number string) (*types.Endpoint, error) {
+	i, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, ErrInvalidArguments
+	}
+	endpoints, err := e.contacts.Endpoints(currentRecipient(e.session))
+	if err != nil {
+		return nil, err
+	}
+	if i < 1 || i > len(endpoints) {
+		return nil, ErrNoSuchEndpoint
+	}
+	return endpoints[i-1], nil
+}
+
+func (e *EndpointsCmd) handleEndpoint(subCommand, number string) {
+	endpoint, err := e.getEndpointByNumber(number)
+	if err == nil {
+		target := types.Contact{
+			PublicKey:         endpoint.PublicKey,
+			ProviderPublicKey: endpoint.ProviderPublicKey,
+		}
+		switch {
+		case endpoint.Preferred && subCommand == preferSubCommand:
+			// nothing to do
+		case endpoint.Preferred:
+			err = ErrPreferredRemoval
+		case subCommand == preferSubCommand:
+			err = e.contacts.PreferEndpoint(target)
+		default:
+			err = e.contacts.SplitEndpoint(target)
+		}
+	}
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not %s the endpoint: %v\n", subCommand, err), e.g, "error")
+		return
+	}
+
+	if subCommand == preferSubCommand {
+		gui.WriteNotice(fmt.Sprintf("messages to %s are now sent to %s...\n", e.session.RecipientAlias(), endpoint.PublicKey[:8]), e.g)
+	} else {
+		gui.WriteNotice(fmt.Sprintf("%s... is no longer an endpoint of %s\n", endpoint.PublicKey[:8], e.session.RecipientAlias()), e.g)
+	}
+}
+
+// we expect the following:
+// just `endpoints` which will list the endpoints of the current recipient
+// `endpoints <alias>` which will list the endpoints of the specified contact
+// `endpoints prefer <number>` which will send the messages to the current recipient to the given endpoint
+// `endpoints remove <number>` which will turn the given endpoint of the current recipient into a separate client
+func (e *EndpointsCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != endpointsCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", e.Name(), args[0])
+	}
+
+	switch {
+	case len(args) == 1:
+		e.list(e.session.RecipientAlias(), currentRecipient(e.session))
+		return nil
+	case len(args) == 2:
+		e.list(args[1], types.Contact{Alias: args[1]})
+		return nil
+	case len(args) == 3 && (args[1] == preferSubCommand || args[1] == removeSubCommand):
+		e.handleEndpoint(args[1], args[2])
+		return nil
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+type MergeCmd struct {
+	g        *gocui.Gui
+	contacts Contacts
+	session  *types.Session
+}
+
+func (m *MergeCmd) Name() string {
+	return mergeCommandName
+}
+
+func (m *MergeCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", mergeCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", mergeCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name_to_keep> <aliased_name_to_merge>\n", mergeCommandName)
+	return usageString
+}
+
+// we expect the following:
+// `merge <alias>` which will make the specified contact part of the current recipient
+// `merge <alias_to_keep> <alias_to_merge>` which will make the second contact part of the first one
+func (m *MergeCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != mergeCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", m.Name(), args[0])
+	}
+
+	var into, from types.Contact
+	intoName, fromName := "", ""
+	switch len(args) {
+	case 2:
+		into, intoName = currentRecipient(m.session), m.session.RecipientAlias()
+		from, fromName = types.Contact{Alias: args[1]}, args[1]
+	case 3:
+		into, intoName = types.Contact{Alias: args[1]}, args[1]
+		from, fromName = types.Contact{Alias: args[2]}, args[2]
+	default:
+		return ErrInvalidArguments
+	}
+
+	if err := m.contacts.MergeContacts(into, from); err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not merge %s into %s: %v\n", fromName, intoName, err), m.g, "error")
+		return nil
+	}
+	gui.WriteNotice(fmt.Sprintf("merged %s into %s, messages from any of its endpoints belong to the conversation with %s now\n",
+		fromName,
+		intoName,
+		intoName,
+	), m.g)
+	return nil
+}
+
+// EndpointsCommand creates new instance of an EndpointsCommand
+func EndpointsCommand(g *gocui.Gui, contacts Contacts, session *types.Session) commands.Command {
+	return &EndpointsCmd{
+		g:        g,
+		contacts: contacts,
+		session:  session,
+	}
+}
+
+// MergeCommand creates new instance of a MergeCommand
+func MergeCommand(g *gocui.Gui, contacts Contacts, session *types.Session) commands.Command {
+	return &MergeCmd{
+		g:        g,
+		contacts: contacts,
+		session:  session,
+	}
+}
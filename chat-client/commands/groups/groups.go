@@ -0,0 +1,388 @@
+package groups
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"strings"
+)
+
+const (
+	groupCommandName   = "group"
+	createSubCommand   = "create"
+	openSubCommand     = "open"
+	closeSubCommand    = "close"
+	addSubCommand      = "add"
+	membersSubCommand  = "members"
+	leaveSubCommand    = "leave"
+	kickSubCommand     = "kick"
+	banSubCommand      = "ban"
+	promoteSubCommand  = "promote"
+	renameSubCommand   = "rename"
+	groupInfoCategory  = "group"
+	shortGroupIDLength = 8
+)
+
+var (
+	ErrInvalidArguments = errors.New("group command received invalid arguments")
+	ErrUnknownGroup     = errors.New("you are not a member of any group with given name")
+	ErrAmbiguousGroup   = errors.New("more than a single group has given name, use the beginning of its ID instead")
+	ErrNoOpenGroup      = errors.New("no group is open, use /group open <name> first")
+	ErrUnknownMember    = errors.New("the group has no member with given alias or key")
+	ErrAmbiguousMember  = errors.New("more than a single member matches, use more of its key")
+)
+
+// Groups manages the groups we are a member of
+type Groups interface {
+	Groups() []*types.Group
+	Group(id string) (*types.Group, error)
+	CreateGroup(name string) (*types.Group, error)
+	AddGroupMember(groupID string, target types.Contact) (bool, error)
+	LeaveGroup(groupID string) error
+	KickGroupMember(groupID string, target types.Contact) error
+	BanGroupMember(groupID string, target types.Contact) error
+	PromoteGroupMember(groupID string, target types.Contact) error
+	RenameGroup(groupID string, name string) error
+}
+
+// Conversations switches the chat session between the conversation with the recipient and the groups
+type Conversations interface {
+	OpenGroup(group *types.Group)
+	CloseGroup()
+}
+
+// MemberName returns the alias of the member or the beginning of its key if it has not got one
+func MemberName(member *types.GroupMember) string {
+	if member.Alias != "" {
+		return member.Alias
+	}
+	return "??? - " + member.PublicKey[:8] + "..."
+}
+
+func contactName(contact types.Contact) string {
+	return MemberName(&types.GroupMember{Contact: contact})
+}
+
+// DescribeChange explains the change of the membership of the group to the user
+func DescribeChange(change *types.GroupChange, ourPublicKey string) string {
+	switch {
+	case change.Kind == types.GroupMemberAdded && change.Member.PublicKey == ourPublicKey:
+		return fmt.Sprintf("%s added you to group %s. Type /%s %s %s to talk there.\n",
+			contactName(change.By),
+			change.Group.Name,
+			groupCommandName,
+			openSubCommand,
+			change.Group.Name,
+		)
+	case change.Kind == types.GroupMemberAdded && change.By.PublicKey == ourPublicKey:
+		return fmt.Sprintf("%s has sent its signing key and was added to group %s\n", contactName(change.Member), change.Group.Name)
+	case change.Kind == types.GroupMemberAdded:
+		return fmt.Sprintf("%s added %s to group %s\n", contactName(change.By), contactName(change.Member), change.Group.Name)
+	case change.Kind == types.GroupMemberLeft:
+		return fmt.Sprintf("%s left group %s\n", contactName(change.Member), change.Group.Name)
+	case (change.Kind == types.GroupMemberRemoved || change.Kind == types.GroupMemberBanned) && change.Member.PublicKey == ourPublicKey:
+		return fmt.Sprintf("%s %s you from group %s\n", contactName(change.By), removalVerb(change.Kind), change.Group.Name)
+	case change.Kind == types.GroupMemberRemoved || change.Kind == types.GroupMemberBanned:
+		return fmt.Sprintf("%s %s %s from group %s\n", contactName(change.By), removalVerb(change.Kind), contactName(change.Member), change.Group.Name)
+	case change.Kind == types.GroupMemberPromoted && change.Member.PublicKey == ourPublicKey:
+		return fmt.Sprintf("%s made you an admin of group %s\n", contactName(change.By), change.Group.Name)
+	case change.Kind == types.GroupMemberPromoted:
+		return fmt.Sprintf("%s made %s an admin of group %s\n", contactName(change.By), contactName(change.Member), change.Group.Name)
+	case change.Kind == types.GroupRenamed:
+		return fmt.Sprintf("%s renamed group %s to %s\n", contactName(change.By), change.PreviousName, change.Group.Name)
+	case change.Kind == types.GroupSynced:
+		return fmt.Sprintf("group %s was replaced by the one of its owner %s after conflicting changes\n", change.Group.Name, contactName(change.By))
+	default:
+		return fmt.Sprintf("the membership of group %s has changed\n", change.Group.Name)
+	}
+}
+
+func removalVerb(kind types.GroupChangeKind) string {
+	if kind == types.GroupMemberBanned {
+		return "banned"
+	}
+	return "removed"
+}
+
+type GroupCmd struct {
+	g             *gocui.Gui
+	groups        Groups
+	conversations Conversations
+	session       *types.Session
+}
+
+func (gc *GroupCmd) Name() string {
+	return groupCommandName
+}
+
+func (gc *GroupCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", groupCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", groupCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s %s <group_name>\n", groupCommandName, createSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <group_name>\n", groupCommandName, openSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", groupCommandName, closeSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <aliased_name>\n", groupCommandName, addSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", groupCommandName, membersSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", groupCommandName, leaveSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <member>\n", groupCommandName, kickSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <member>\n", groupCommandName, banSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <member>\n", groupCommandName, promoteSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s <group_name>\n", groupCommandName, renameSubCommand)
+	return usageString
+}
+
+// findGroup finds the group either by its name or by the beginning of its ID
+func (gc *GroupCmd) findGroup(nameOrID string) (*types.Group, error) {
+	var found *types.Group
+	for _, group := range gc.groups.Groups() {
+		if group.Name != nameOrID && !(len(nameOrID) >= shortGroupIDLength && strings.HasPrefix(group.ID, nameOrID)) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousGroup
+		}
+		found = group
+	}
+	if found == nil {
+		return nil, ErrUnknownGroup
+	}
+	return found, nil
+}
+
+// findMember finds the member of the group either by its alias or by the beginning of its public key,
+// as the members added by somebody else might not be our contacts
+func findMember(group *types.Group, aliasOrKey string) (*types.GroupMember, error) {
+	var found *types.GroupMember
+	for _, member := range group.Members {
+		if member.Alias != aliasOrKey && !(len(aliasOrKey) >= shortGroupIDLength && strings.HasPrefix(member.PublicKey, aliasOrKey)) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousMember
+		}
+		found = member
+	}
+	if found == nil {
+		return nil, ErrUnknownMember
+	}
+	return found, nil
+}
+
+func (gc *GroupCmd) openGroup() (*types.Group, error) {
+	if gc.session.Group() == "" {
+		return nil, ErrNoOpenGroup
+	}
+	return gc.groups.Group(gc.session.Group())
+}
+
+func (gc *GroupCmd) list() {
+	groups := gc.groups.Groups()
+	if len(groups) == 0 {
+		gui.WriteInfo(fmt.Sprintf("you are not a member of any group, create one with /%s %s <group_name>\n",
+			groupCommandName,
+			createSubCommand,
+		), gc.g, groupInfoCategory)
+		return
+	}
+	for _, group := range groups {
+		gui.WriteInfo(fmt.Sprintf("%s (ID: %s...): %d other member(s), you are its %s\n",
+			group.Name,
+			group.ID[:shortGroupIDLength],
+			len(group.Members),
+			group.Role,
+		), gc.g, groupInfoCategory)
+	}
+}
+
+func (gc *GroupCmd) create(name string) {
+	group, err := gc.groups.CreateGroup(name)
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not create the group: %v\n", err), gc.g, "error")
+		return
+	}
+	gc.conversations.OpenGroup(group)
+	gui.WriteNotice(fmt.Sprintf("created group %s, add your contacts to it with /%s %s <aliased_name>\n",
+		group.Name,
+		groupCommandName,
+		addSubCommand,
+	), gc.g)
+}
+
+func (gc *GroupCmd) open(nameOrID string) {
+	group, err := gc.findGroup(nameOrID)
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not open the group: %v\n", err), gc.g, "error")
+		return
+	}
+	gc.conversations.OpenGroup(group)
+	gui.WriteNotice(fmt.Sprintf("You're currently sending messages to group %s, type /%s %s to get back to %s\n",
+		group.Name,
+		groupCommandName,
+		closeSubCommand,
+		gc.session.RecipientAlias(),
+	), gc.g)
+}
+
+func (gc *GroupCmd) close() {
+	if gc.session.Group() == "" {
+		gui.WriteNotice(fmt.Sprintf("Could not close the group: %v\n", ErrNoOpenGroup), gc.g, "error")
+		return
+	}
+	gc.conversations.CloseGroup()
+	gui.WriteNotice(fmt.Sprintf("You're currently sending messages to: %s\n", gc.session.RecipientAlias()), gc.g)
+}
+
+func (gc *GroupCmd) add(aliasName string) {
+	group, err := gc.openGroup()
+	added := false
+	if err == nil {
+		added, err = gc.groups.AddGroupMember(group.ID, types.Contact{Alias: aliasName})
+	}
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not add %s to the group: %v\n", aliasName, err), gc.g, "error")
+		return
+	}
+	if !added {
+		gui.WriteNotice(fmt.Sprintf("asked %s for its signing key, it is going to be added to group %s once the key arrives\n",
+			aliasName,
+			group.Name,
+		), gc.g)
+		return
+	}
+	gui.WriteNotice(fmt.Sprintf("added %s to group %s, all of its members are going to be told about it\n",
+		aliasName,
+		group.Name,
+	), gc.g)
+}
+
+func (gc *GroupCmd) members() {
+	group, err := gc.openGroup()
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not list the members: %v\n", err), gc.g, "error")
+		return
+	}
+	gui.WriteInfo(fmt.Sprintf("you (%s)\n", group.Role), gc.g, groupInfoCategory)
+	for _, member := range group.Members {
+		verification := "not verified"
+		if member.Verified {
+			verification = "verified"
+		}
+		gui.WriteInfo(fmt.Sprintf("%s (%s, %s)\n", MemberName(member), member.Role, verification), gc.g, groupInfoCategory)
+	}
+}
+
+// manageMember kicks, bans or promotes the member of the open group
+func (gc *GroupCmd) manageMember(subCommand, aliasOrKey string) {
+	action := map[string]struct {
+		change func(groupID string, target types.Contact) error
+		done   string
+	}{
+		kickSubCommand:    {gc.groups.KickGroupMember, "removed %s from group %s"},
+		banSubCommand:     {gc.groups.BanGroupMember, "banned %s from group %s"},
+		promoteSubCommand: {gc.groups.PromoteGroupMember, "made %s an admin of group %s"},
+	}[subCommand]
+
+	group, err := gc.openGroup()
+	var member *types.GroupMember
+	if err == nil {
+		member, err = findMember(group, aliasOrKey)
+	}
+	if err == nil {
+		err = action.change(group.ID, member.Contact)
+	}
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not %s %s: %v\n", subCommand, aliasOrKey, err), gc.g, "error")
+		return
+	}
+	gui.WriteNotice(fmt.Sprintf(action.done+", all of its members are going to be told about it\n",
+		MemberName(member),
+		group.Name,
+	), gc.g)
+}
+
+func (gc *GroupCmd) rename(name string) {
+	group, err := gc.openGroup()
+	if err == nil {
+		err = gc.groups.RenameGroup(group.ID, name)
+	}
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not rename the group: %v\n", err), gc.g, "error")
+		return
+	}
+	gc.session.OpenGroup(group.ID, name)
+	gui.WriteNotice(fmt.Sprintf("renamed group %s to %s, all of its members are going to be told about it\n",
+		group.Name,
+		name,
+	), gc.g)
+}
+
+func (gc *GroupCmd) leave() {
+	group, err := gc.openGroup()
+	if err == nil {
+		err = gc.groups.LeaveGroup(group.ID)
+	}
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not leave the group: %v\n", err), gc.g, "error")
+		return
+	}
+	gc.conversations.CloseGroup()
+	gui.WriteNotice(fmt.Sprintf("left group %s, you're currently sending messages to: %s\n",
+		group.Name,
+		gc.session.RecipientAlias(),
+	), gc.g)
+}
+
+// we expect the following:
+// just `group` which will list all of the groups we are a member of
+// `group create <name>` which will create new group and open it
+// `group open <name>` which will send the messages to the group instead of the recipient
+// `group close` which will send the messages to the recipient again
+// `group add <alias>` which will add the contact to the open group
+// `group members` which will list all members of the open group with their roles and verification states
+// `group leave` which will leave the open group
+// `group kick|ban|promote <member>` which will change the member of the open group, given by its alias or the beginning of its key
+// `group rename <name>` which will rename the open group
+func (gc *GroupCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != groupCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", gc.Name(), args[0])
+	}
+
+	switch {
+	case len(args) == 1:
+		gc.list()
+	case len(args) > 2 && args[1] == createSubCommand:
+		gc.create(strings.Join(args[2:], " "))
+	case len(args) > 2 && args[1] == openSubCommand:
+		gc.open(strings.Join(args[2:], " "))
+	case len(args) == 2 && args[1] == closeSubCommand:
+		gc.close()
+	case len(args) == 3 && args[1] == addSubCommand:
+		gc.add(args[2])
+	case len(args) == 2 && args[1] == membersSubCommand:
+		gc.members()
+	case len(args) == 2 && args[1] == leaveSubCommand:
+		gc.leave()
+	case len(args) == 3 && (args[1] == kickSubCommand || args[1] == banSubCommand || args[1] == promoteSubCommand):
+		gc.manageMember(args[1], args[2])
+	case len(args) > 2 && args[1] == renameSubCommand:
+		gc.rename(strings.Join(args[2:], " "))
+	default:
+		return ErrInvalidArguments
+	}
+	return nil
+}
+
+// GroupCommand creates new instance of a GroupCommand
+func GroupCommand(g *gocui.Gui, groups Groups, conversations Conversations, session *types.Session) commands.Command {
+	return &GroupCmd{
+		g:             g,
+		groups:        groups,
+		conversations: conversations,
+		session:       session,
+	}
+}
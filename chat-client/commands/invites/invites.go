@@ -0,0 +1,291 @@
+package invites
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/gui/layout"
+	"github.com/nymtech/demo-mixnet-chat-client/gui/qr"
+	"github.com/nymtech/demo-mixnet-chat-client/invite"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	inviteCommandName     = "invite"
+	addContactCommandName = "addcontact"
+	qrCommandName         = "qr"
+)
+
+var (
+	ErrNotEnoughArguments = errors.New("addcontact command did not receive enough arguments")
+	ErrInvalidArguments   = errors.New("invite command received invalid arguments")
+	ErrMalformedIdentity  = errors.New("malformed keys of our own client")
+)
+
+// Identity provides the keys of our own client
+type Identity interface {
+	PublicKey() []byte
+	ProviderPublicKey() []byte
+}
+
+// NameClaims compares the keys of a client claiming a name against the keys pinned for our contacts
+type NameClaims interface {
+	CheckClaimedName(name string, publicKey, providerPublicKey []byte, source string) *types.KeyChange
+}
+
+type InviteCmd struct {
+	g        *gocui.Gui
+	identity Identity
+}
+
+func (i *InviteCmd) Name() string {
+	return inviteCommandName
+}
+
+func (i *InviteCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", inviteCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", inviteCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <suggested_name>\n", inviteCommandName)
+	return usageString
+}
+
+// we expect `invite` which will print our invite, optionally followed by the name we suggest to be known by
+func (i *InviteCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != inviteCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", i.Name(), args[0])
+	}
+
+	encoded, err := encodeOurInvite(i.g, i.identity, args[1:])
+	if err != nil || encoded == "" {
+		return err
+	}
+	writeInvite(i.g, encoded)
+	return nil
+}
+
+// encodeOurInvite creates our invite with the name made of the arguments of the command.
+// If the invite can't be created because of the name, the reason is written and the invite is empty.
+func encodeOurInvite(g *gocui.Gui, identity Identity, nameArgs []string) (string, error) {
+	ourPub, ourProvPub := utils.KeysFromBytes(identity.PublicKey(), identity.ProviderPublicKey())
+	if ourPub == nil || ourProvPub == nil {
+		return "", ErrMalformedIdentity
+	}
+	ourInvite := &invite.Invite{
+		PublicKey:         ourPub,
+		ProviderPublicKey: ourProvPub,
+		Name:              strings.TrimSpace(strings.Join(nameArgs, " ")),
+	}
+	encoded, err := ourInvite.Encode()
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not create the invite: %v\n", err), g, "error")
+		return "", nil
+	}
+	return encoded, nil
+}
+
+func writeInvite(g *gocui.Gui, encoded string) {
+	gui.WriteInfo(fmt.Sprintf("%s\nShare it with anyone you wish to communicate with, they can add you with /%s <invite>\n",
+		encoded,
+		addContactCommandName,
+	), g, "Your invite")
+}
+
+type QRCmd struct {
+	g        *gocui.Gui
+	identity Identity
+}
+
+func (q *QRCmd) Name() string {
+	return qrCommandName
+}
+
+func (q *QRCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", qrCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", qrCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <suggested_name>\n", qrCommandName)
+	return usageString
+}
+
+// we expect `qr` which will display our invite as a QR code, optionally followed by the name we suggest to be known by
+func (q *QRCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != qrCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", q.Name(), args[0])
+	}
+
+	encoded, err := encodeOurInvite(q.g, q.identity, args[1:])
+	if err != nil || encoded == "" {
+		return err
+	}
+	lines, err := qr.Render(encoded)
+	if err != nil {
+		return err
+	}
+
+	writeInvite(q.g, encoded)
+	if !layout.OverlayFits(q.g, lines) {
+		gui.WriteNotice(fmt.Sprintf("The terminal is too small to display the QR code, it needs at least %dx%d characters\n",
+			utf8.RuneCountInString(lines[0])+2,
+			len(lines)+2,
+		), q.g, "error")
+		return nil
+	}
+	gui.ShowOverlay(q.g, "scan to add me", lines)
+	return nil
+}
+
+type AddContactCmd struct {
+	g          *gocui.Gui
+	aliasStore alias.AliasStore
+	identity   Identity
+	claims     NameClaims
+	session    *types.Session
+
+	// the verified invite waiting for the user to choose its alias
+	pending *invite.Invite
+}
+
+func (a *AddContactCmd) Name() string {
+	return addContactCommandName
+}
+
+func (a *AddContactCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", addContactCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <invite>\n", addContactCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <invite> <aliased_name>\n", addContactCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", addContactCommandName)
+	return usageString
+}
+
+func (a *AddContactCmd) describe(inv *invite.Invite) string {
+	description := (&alias.Alias{PublicKey: inv.PublicKey, ProviderPublicKey: inv.ProviderPublicKey}).String()
+	if inv.Name != "" {
+		description += fmt.Sprintf(" - Suggested name: %s", inv.Name)
+	}
+	return description
+}
+
+// verify parses the invite, writing the reason why it is not acceptable if that is the case
+func (a *AddContactCmd) verify(encoded string) *invite.Invite {
+	inv, err := invite.Parse(encoded)
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Invalid invite: %v\n", err), a.g, "error")
+		return nil
+	}
+	if bytes.Equal(inv.PublicKey.Bytes(), a.identity.PublicKey()) {
+		gui.WriteNotice("This is your own invite\n", a.g, "error")
+		return nil
+	}
+	return inv
+}
+
+func (a *AddContactCmd) store(inv *invite.Invite, name string) error {
+	if name == "" || !alias.IsValidName(name) || invite.IsInvite(name) {
+		return ErrInvalidArguments
+	}
+	newAlias := &alias.Alias{
+		AssignedName:      name,
+		PublicKey:         inv.PublicKey,
+		ProviderPublicKey: inv.ProviderPublicKey,
+	}
+	gui.WriteNotice(fmt.Sprintf("Adding new contact. Creating new alias: %s\n", newAlias.String()), a.g)
+	a.aliasStore.StoreAlias(newAlias)
+	a.pending = nil
+
+	// check if the target is not the same as current session recipient
+	if bytes.Equal(inv.PublicKey.Bytes(), a.session.Recipient().PubKey) {
+		a.session.UpdateAlias(name)
+	}
+	return nil
+}
+
+// promptForAlias keeps the verified invite until the user chooses the alias of the contact
+func (a *AddContactCmd) promptForAlias(inv *invite.Invite) {
+	a.pending = inv
+	prompt := fmt.Sprintf("Verified invite: %s\n", a.describe(inv))
+	if existing := a.aliasStore.GetAlias(inv.PublicKey, inv.ProviderPublicKey); existing.AssignedName != "" {
+		prompt += fmt.Sprintf("The client is already known as %s.\n", existing.AssignedName)
+	}
+	if change := a.claims.CheckClaimedName(inv.Name, inv.PublicKey.Bytes(), inv.ProviderPublicKey.Bytes(), types.KeySourceInvite); change != nil {
+		prompt += "These keys differ from the ones pinned for your contact, type /trust to see the details.\n"
+	}
+	prompt += fmt.Sprintf("Choose the alias of the contact with /%s <aliased_name>", addContactCommandName)
+	if suggested := inv.SuggestedAlias(); suggested != "" && alias.IsValidName(suggested) {
+		prompt += fmt.Sprintf(" or just type /%s to use '%s'", addContactCommandName, suggested)
+	}
+	gui.WriteInfo(prompt+"\n", a.g, "addcontact")
+}
+
+// we expect the following:
+// `addcontact <invite>` which will verify the invite and ask for the alias of the contact
+// `addcontact <invite> <alias>` which will verify the invite and store the contact straight away
+// `addcontact <alias>` which will store the contact of the previously verified invite
+// just `addcontact` which will store the contact of the previously verified invite under its suggested name
+func (a *AddContactCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != addContactCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", a.Name(), args[0])
+	}
+
+	switch {
+	case len(args) == 1:
+		if a.pending == nil {
+			return ErrNotEnoughArguments
+		}
+		return a.store(a.pending, a.pending.SuggestedAlias())
+	case len(args) > 3:
+		return ErrInvalidArguments
+	case invite.IsInvite(args[1]):
+		inv := a.verify(args[1])
+		if inv == nil {
+			return nil
+		}
+		if len(args) == 3 {
+			return a.store(inv, args[2])
+		}
+		a.promptForAlias(inv)
+		return nil
+	case len(args) == 2 && a.pending != nil:
+		return a.store(a.pending, args[1])
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+// InviteCommand creates new instance of an InviteCommand
+func InviteCommand(g *gocui.Gui, identity Identity) commands.Command {
+	return &InviteCmd{
+		g:        g,
+		identity: identity,
+	}
+}
+
+// QRCommand creates new instance of a QRCommand
+func QRCommand(g *gocui.Gui, identity Identity) commands.Command {
+	return &QRCmd{
+		g:        g,
+		identity: identity,
+	}
+}
+
+// AddContactCommand creates new instance of an AddContactCommand
+func AddContactCommand(g *gocui.Gui, aliasStore alias.AliasStore, identity Identity, claims NameClaims, session *types.Session) commands.Command {
+	return &AddContactCmd{
+		g:          g,
+		aliasStore: aliasStore,
+		identity:   identity,
+		claims:     claims,
+		session:    session,
+	}
+}
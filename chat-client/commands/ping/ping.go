@@ -0,0 +1,154 @@
+package ping
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"sync"
+	"time"
+)
+
+const (
+	pingCommandName = "ping"
+
+	probesCount   = 4
+	probeInterval = time.Second
+	// the mixnet delays the packets on purpose, so be patient
+	probeTimeout = 30 * time.Second
+)
+
+var (
+	ErrInvalidArguments = errors.New("ping command received invalid arguments")
+)
+
+// Pinger sends probes that are automatically answered by the other clients
+type Pinger interface {
+	Ping(ctx context.Context, target types.Contact) (time.Duration, error)
+}
+
+type PingCmd struct {
+	g       *gocui.Gui
+	pinger  Pinger
+	session *types.Session
+}
+
+func (p *PingCmd) Name() string {
+	return pingCommandName
+}
+
+func (p *PingCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", pingCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", pingCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", pingCommandName)
+	return usageString
+}
+
+type probeResult struct {
+	rtt time.Duration
+	err error
+}
+
+func (p *PingCmd) summarise(targetName string, results []probeResult) {
+	received := 0
+	var min, max, sum time.Duration
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		if received == 0 || result.rtt < min {
+			min = result.rtt
+		}
+		if result.rtt > max {
+			max = result.rtt
+		}
+		sum += result.rtt
+		received++
+	}
+
+	summary := fmt.Sprintf("--- %s ping statistics ---\n%d probes transmitted, %d received, %d%% loss\n",
+		targetName,
+		len(results),
+		received,
+		(len(results)-received)*100/len(results),
+	)
+	if received > 0 {
+		summary += fmt.Sprintf("rtt min/avg/max = %v/%v/%v\n",
+			min.Round(time.Millisecond),
+			(sum / time.Duration(received)).Round(time.Millisecond),
+			max.Round(time.Millisecond),
+		)
+	}
+	gui.WriteInfo(summary, p.g, "ping")
+}
+
+// probe sends all probes in the background, one every probeInterval, without waiting for the previous answers
+func (p *PingCmd) probe(target types.Contact, targetName string) {
+	results := make([]probeResult, probesCount)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+			rtt, err := p.pinger.Ping(ctx, target)
+			results[seq] = probeResult{rtt: rtt, err: err}
+			switch {
+			case err == context.DeadlineExceeded:
+				gui.WriteInfo(fmt.Sprintf("probe seq=%d to %s timed out\n", seq+1, targetName), p.g, "ping")
+			case err != nil:
+				gui.WriteInfo(fmt.Sprintf("probe seq=%d to %s failed: %v\n", seq+1, targetName, err), p.g, "ping")
+			default:
+				gui.WriteInfo(fmt.Sprintf("reply from %s: seq=%d time=%v\n", targetName, seq+1, rtt.Round(time.Millisecond)), p.g, "ping")
+			}
+		}(i)
+		if i < len(results)-1 {
+			time.Sleep(probeInterval)
+		}
+	}
+	wg.Wait()
+	p.summarise(targetName, results)
+}
+
+// we expect either `ping` which will probe the current recipient or `ping <alias>`
+func (p *PingCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != pingCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", p.Name(), args[0])
+	}
+
+	var target types.Contact
+	var targetName string
+	switch len(args) {
+	case 1:
+		recipient := p.session.Recipient()
+		target = types.Contact{
+			PublicKey:         base64.URLEncoding.EncodeToString(recipient.PubKey),
+			ProviderPublicKey: base64.URLEncoding.EncodeToString(recipient.Provider.PubKey),
+		}
+		targetName = p.session.RecipientAlias()
+	case 2:
+		target = types.Contact{Alias: args[1]}
+		targetName = args[1]
+	default:
+		return ErrInvalidArguments
+	}
+
+	gui.WriteInfo(fmt.Sprintf("sending %d probes to %s\n", probesCount, targetName), p.g, "ping")
+	go p.probe(target, targetName)
+	return nil
+}
+
+func PingCommand(g *gocui.Gui, pinger Pinger, session *types.Session) commands.Command {
+	return &PingCmd{
+		g:       g,
+		pinger:  pinger,
+		session: session,
+	}
+}
@@ -0,0 +1,191 @@
+package profile
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"strings"
+)
+
+const (
+	nickCommandName       = "nick"
+	statusCommandName     = "status"
+	acceptNickCommandName = "acceptnick"
+	clearSubCommand       = "clear"
+	profileInfoCategory   = "profile"
+)
+
+var (
+	ErrInvalidArguments = errors.New("profile command received invalid arguments")
+)
+
+// Profiles manages our own profile and the names suggested by the contacts in theirs
+type Profiles interface {
+	Profile() *types.Profile
+	SetProfile(name, status string) error
+	AcceptSuggestedName(target types.Contact) (string, error)
+}
+
+// AcceptNickUsage returns the command accepting the name suggested by the contact,
+// with the contact omitted if it is the current recipient
+func AcceptNickUsage(contact types.Contact, isRecipient bool) string {
+	switch {
+	case isRecipient:
+		return "/" + acceptNickCommandName
+	case contact.Alias != "":
+		return fmt.Sprintf("/%s %s", acceptNickCommandName, contact.Alias)
+	default:
+		return fmt.Sprintf("/%s %s %s", acceptNickCommandName, contact.PublicKey, contact.ProviderPublicKey)
+	}
+}
+
+// profileField is either the name or the status of our profile, as both are set the same way
+type profileField struct {
+	g        *gocui.Gui
+	profiles Profiles
+	name     string
+	// what the field is called when talking to the user
+	description string
+	get         func(profile *types.Profile) string
+	set         func(profile *types.Profile, value string)
+}
+
+func (p *profileField) Name() string {
+	return p.name
+}
+
+func (p *profileField) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", p.name)
+	usageString += fmt.Sprintf("\t\t - /%s\n", p.name)
+	usageString += fmt.Sprintf("\t\t - /%s <new_%s>\n", p.name, p.description)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", p.name, clearSubCommand)
+	return usageString
+}
+
+// we expect the following:
+// just `<field>` which will show the current value
+// `<field> <value...>` which will set the value, possibly consisting of multiple words, and send it to the contacts
+// `<field> clear` which will remove the value from our profile
+func (p *profileField) Handle(args []string) error {
+	// sanity check
+	if args[0] != p.name {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", p.Name(), args[0])
+	}
+
+	profile := p.profiles.Profile()
+	if len(args) == 1 {
+		if value := p.get(profile); value != "" {
+			gui.WriteInfo(fmt.Sprintf("your %s is: %s\n", p.description, value), p.g, profileInfoCategory)
+		} else {
+			gui.WriteInfo(fmt.Sprintf("you have not set your %s\n", p.description), p.g, profileInfoCategory)
+		}
+		return nil
+	}
+
+	value := strings.Join(args[1:], " ")
+	if len(args) == 2 && args[1] == clearSubCommand {
+		value = ""
+	}
+	p.set(profile, value)
+	if err := p.profiles.SetProfile(profile.Name, profile.Status); err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not set your %s: %v\n", p.description, err), p.g, "error")
+		return nil
+	}
+	if value == "" {
+		gui.WriteNotice(fmt.Sprintf("cleared your %s, your contacts are going to be told about it\n", p.description), p.g)
+	} else {
+		gui.WriteNotice(fmt.Sprintf("your %s is now: %s, your contacts are going to be told about it\n", p.description, value), p.g)
+	}
+	return nil
+}
+
+type AcceptNickCmd struct {
+	g        *gocui.Gui
+	profiles Profiles
+	session  *types.Session
+}
+
+func (a *AcceptNickCmd) Name() string {
+	return acceptNickCommandName
+}
+
+func (a *AcceptNickCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", acceptNickCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", acceptNickCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", acceptNickCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <target_public_key> <target_provider_public_key>\n", acceptNickCommandName)
+	return usageString
+}
+
+// we expect the following:
+// just `acceptnick` which will use the name suggested by the current recipient as its alias
+// `acceptnick <alias>` which will replace the alias of the specified contact with the name it suggests
+// `acceptnick <pub> <prov>` which will use the name suggested by the specified client as its alias
+func (a *AcceptNickCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != acceptNickCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", a.Name(), args[0])
+	}
+
+	var target types.Contact
+	switch len(args) {
+	case 1:
+		target = types.Contact{
+			PublicKey:         base64.URLEncoding.EncodeToString(a.session.Recipient().PubKey),
+			ProviderPublicKey: base64.URLEncoding.EncodeToString(a.session.Recipient().Provider.PubKey),
+		}
+	case 2:
+		target = types.Contact{Alias: args[1]}
+	case 3:
+		target = types.Contact{PublicKey: args[1], ProviderPublicKey: args[2]}
+	default:
+		return ErrInvalidArguments
+	}
+
+	name, err := a.profiles.AcceptSuggestedName(target)
+	if err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not accept the suggested name: %v\n", err), a.g, "error")
+		return nil
+	}
+	gui.WriteNotice(fmt.Sprintf("the contact is now known as %s\n", name), a.g)
+	return nil
+}
+
+// NickCommand creates new instance of a NickCommand
+func NickCommand(g *gocui.Gui, profiles Profiles) commands.Command {
+	return &profileField{
+		g:           g,
+		profiles:    profiles,
+		name:        nickCommandName,
+		description: "name",
+		get:         func(profile *types.Profile) string { return profile.Name },
+		set:         func(profile *types.Profile, value string) { profile.Name = value },
+	}
+}
+
+// StatusCommand creates new instance of a StatusCommand
+func StatusCommand(g *gocui.Gui, profiles Profiles) commands.Command {
+	return &profileField{
+		g:           g,
+		profiles:    profiles,
+		name:        statusCommandName,
+		description: "status",
+		get:         func(profile *types.Profile) string { return profile.Status },
+		set:         func(profile *types.Profile, value string) { profile.Status = value },
+	}
+}
+
+// AcceptNickCommand creates new instance of an AcceptNickCommand
+func AcceptNickCommand(g *gocui.Gui, profiles Profiles, session *types.Session) commands.Command {
+	return &AcceptNickCmd{
+		g:        g,
+		profiles: profiles,
+		session:  session,
+	}
+}
@@ -0,0 +1,250 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	requestsCommandName = "requests"
+	acceptCommandName   = "accept"
+	ignoreCommandName   = "ignore"
+	allModifier         = "all"
+)
+
+var (
+	ErrNotEnoughArguments = errors.New("requests command did not receive enough arguments")
+	ErrInvalidArguments   = errors.New("requests command received invalid arguments")
+	ErrNoSuchRequest      = errors.New("there is no pending request with given number")
+)
+
+// RequestStore holds messages received from senders we have not assigned an alias to yet
+type RequestStore interface {
+	StoreRequestMessage(msg *message.ChatMessage)
+	HasRequest(*sphinx.PublicKey, *sphinx.PublicKey) bool
+	GetAllRequests() []*Request
+	ArchiveRequest(*sphinx.PublicKey, *sphinx.PublicKey)
+	RemoveRequest(*sphinx.PublicKey, *sphinx.PublicKey)
+	RemoveAllRequests()
+}
+
+// Request groups all held messages of a single unknown sender
+type Request struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+	Messages          []*message.ChatMessage
+}
+
+func (r *Request) String() string {
+	b64Key := base64.URLEncoding.EncodeToString(r.PublicKey.Bytes())
+	b64ProvKey := base64.URLEncoding.EncodeToString(r.ProviderPublicKey.Bytes())
+	return fmt.Sprintf("Public Key: %s Provider's Public Key: %s - %d held message(s)", b64Key, b64ProvKey, len(r.Messages))
+}
+
+// requests are referred to by their position on the list printed by `/requests`, starting from 1
+func getRequestByNumber(store RequestStore, number string) (*Request, error) {
+	i, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, ErrInvalidArguments
+	}
+	allRequests := store.GetAllRequests()
+	if i < 1 || i > len(allRequests) {
+		return nil, ErrNoSuchRequest
+	}
+	return allRequests[i-1], nil
+}
+
+func writeHeldMessages(g *gocui.Gui, req *Request, senderName string) {
+	for _, msg := range req.Messages {
+		content := string(msg.Content)
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		sentAt := time.Unix(0, msg.SenderTimestamp).Format("2006-01-02 15:04:05")
+		gui.WriteInfo(content, g, fmt.Sprintf("%s [held, sent %s]", senderName, sentAt))
+	}
+}
+
+type RequestsCmd struct {
+	g     *gocui.Gui
+	store RequestStore
+}
+
+func (r *RequestsCmd) Name() string {
+	return requestsCommandName
+}
+
+func (r *RequestsCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", requestsCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", requestsCommandName)
+	return usageString
+}
+
+// we expect just `requests` which will list all senders currently held in the inbox
+func (r *RequestsCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != requestsCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", r.Name(), args[0])
+	}
+	if len(args) != 1 {
+		return ErrInvalidArguments
+	}
+
+	allRequests := r.store.GetAllRequests()
+	if len(allRequests) == 0 {
+		gui.WriteInfo("no pending contact requests\n", r.g, "requests")
+		return nil
+	}
+
+	for i, req := range allRequests {
+		gui.WriteInfo(fmt.Sprintf("[%d] %s\n", i+1, req.String()), r.g, "requests")
+	}
+	gui.WriteInfo(fmt.Sprintf("use /%s <number> <alias_name> or /%s <number> to handle them\n",
+		acceptCommandName,
+		ignoreCommandName,
+	), r.g, "requests")
+	return nil
+}
+
+type AcceptCmd struct {
+	g          *gocui.Gui
+	store      RequestStore
+	aliasStore alias.AliasStore
+	session    *types.Session
+}
+
+func (a *AcceptCmd) Name() string {
+	return acceptCommandName
+}
+
+func (a *AcceptCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", acceptCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <request_number> <aliased_name>\n", acceptCommandName)
+	return usageString
+}
+
+// we expect `accept <number> <alias>` which will store the alias for the sender and show its held messages
+func (a *AcceptCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != acceptCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", a.Name(), args[0])
+	}
+	if len(args) < 3 {
+		return ErrNotEnoughArguments
+	}
+	if len(args) > 3 || !alias.IsValidName(args[2]) {
+		return ErrInvalidArguments
+	}
+
+	req, err := getRequestByNumber(a.store, args[1])
+	if err != nil {
+		return err
+	}
+
+	newAlias := &alias.Alias{
+		AssignedName:      args[2],
+		PublicKey:         req.PublicKey,
+		ProviderPublicKey: req.ProviderPublicKey,
+	}
+	gui.WriteNotice(fmt.Sprintf("Accepting contact request. Creating new alias: %s\n", newAlias.String()), a.g)
+	a.aliasStore.StoreAlias(newAlias)
+	// the held messages become part of the conversation with the new contact
+	a.store.ArchiveRequest(req.PublicKey, req.ProviderPublicKey)
+	a.store.RemoveRequest(req.PublicKey, req.ProviderPublicKey)
+
+	// check if the target is not the same as current session recipient
+	recipient := a.session.Recipient()
+	if bytes.Equal(req.PublicKey.Bytes(), recipient.PubKey) &&
+		bytes.Equal(req.ProviderPublicKey.Bytes(), recipient.GetProvider().GetPubKey()) {
+		a.session.UpdateAlias(newAlias.AssignedName)
+	}
+
+	writeHeldMessages(a.g, req, newAlias.AssignedName)
+	return nil
+}
+
+type IgnoreCmd struct {
+	g     *gocui.Gui
+	store RequestStore
+}
+
+func (i *IgnoreCmd) Name() string {
+	return ignoreCommandName
+}
+
+func (i *IgnoreCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", ignoreCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <request_number>\n", ignoreCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s all\n", ignoreCommandName)
+	return usageString
+}
+
+// we expect the following:
+// `ignore <number>` which will discard the request and all of its held messages
+// `ignore all` which will empty the whole inbox
+func (i *IgnoreCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != ignoreCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", i.Name(), args[0])
+	}
+	switch len(args) {
+	case 1:
+		return ErrNotEnoughArguments
+	case 2:
+		if args[1] == allModifier {
+			gui.WriteNotice("discarding ALL pending contact requests\n", i.g)
+			i.store.RemoveAllRequests()
+			return nil
+		}
+		req, err := getRequestByNumber(i.store, args[1])
+		if err != nil {
+			return err
+		}
+		gui.WriteNotice(fmt.Sprintf("discarding contact request from: %s\n", req.String()), i.g)
+		i.store.RemoveRequest(req.PublicKey, req.ProviderPublicKey)
+		return nil
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+// RequestsCommand creates new instance of a RequestsCommand
+func RequestsCommand(g *gocui.Gui, store RequestStore) commands.Command {
+	return &RequestsCmd{
+		g:     g,
+		store: store,
+	}
+}
+
+// AcceptCommand creates new instance of an AcceptCommand
+func AcceptCommand(g *gocui.Gui, store RequestStore, aliasStore alias.AliasStore, session *types.Session) commands.Command {
+	return &AcceptCmd{
+		g:          g,
+		store:      store,
+		aliasStore: aliasStore,
+		session:    session,
+	}
+}
+
+// IgnoreCommand creates new instance of an IgnoreCommand
+func IgnoreCommand(g *gocui.Gui, store RequestStore) commands.Command {
+	return &IgnoreCmd{
+		g:     g,
+		store: store,
+	}
+}
@@ -0,0 +1,120 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"time"
+)
+
+const (
+	statsCommandName = "stats"
+)
+
+var (
+	ErrInvalidArguments = errors.New("stats command received invalid arguments")
+)
+
+// StatsSource provides the traffic statistics of the client
+type StatsSource interface {
+	Stats() *types.Stats
+}
+
+type StatsCmd struct {
+	g      *gocui.Gui
+	source StatsSource
+}
+
+func (s *StatsCmd) Name() string {
+	return statsCommandName
+}
+
+func (s *StatsCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", statsCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", statsCommandName)
+	return usageString
+}
+
+func formatBytes(n float64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", n/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1fkB", n/1024)
+	default:
+		return fmt.Sprintf("%.0fB", n)
+	}
+}
+
+func formatContactStats(contactStats *types.ContactStats) string {
+	statsString := fmt.Sprintf("%s: sent %d (%s, %s/s), received %d (%s, %s/s), failed %d",
+		contactStats.Alias,
+		contactStats.MessagesSent,
+		formatBytes(float64(contactStats.BytesSent)),
+		formatBytes(contactStats.SentBytesPerSecond),
+		contactStats.MessagesReceived,
+		formatBytes(float64(contactStats.BytesReceived)),
+		formatBytes(contactStats.ReceivedBytesPerSecond),
+		contactStats.FailedSends,
+	)
+	if contactStats.MessagesSent > 0 {
+		statsString += fmt.Sprintf("\n\t\tsending took %v on average", contactStats.AvgSendDuration.Round(time.Millisecond))
+	}
+	if contactStats.DelaySamples > 0 {
+		statsString += fmt.Sprintf("\n\t\tone-way delay (clock-dependent): min %v, avg %v (%v over the fastest, regardless of clock offset)",
+			contactStats.MinDelay.Round(time.Millisecond),
+			contactStats.AvgDelay.Round(time.Millisecond),
+			contactStats.AvgExtraDelay.Round(time.Millisecond),
+		)
+	}
+	return statsString + "\n"
+}
+
+// we expect just `stats` which will show the statistics of all contacts we exchanged messages with
+func (s *StatsCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != statsCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", s.Name(), args[0])
+	}
+	if len(args) != 1 {
+		return ErrInvalidArguments
+	}
+
+	stats := s.source.Stats()
+	gui.WriteInfo(fmt.Sprintf("since %s (%v ago)\n",
+		stats.Since.Format("2006-01-02 15:04:05"),
+		stats.Uptime.Round(time.Second),
+	), s.g, "stats")
+
+	if len(stats.Contacts) == 0 {
+		gui.WriteInfo("no messages were exchanged yet\n", s.g, "stats")
+		return nil
+	}
+
+	totals := &types.ContactStats{Contact: types.Contact{Alias: "total"}}
+	for _, contactStats := range stats.Contacts {
+		gui.WriteInfo(formatContactStats(contactStats), s.g, "stats")
+		totals.MessagesSent += contactStats.MessagesSent
+		totals.MessagesReceived += contactStats.MessagesReceived
+		totals.FailedSends += contactStats.FailedSends
+		totals.BytesSent += contactStats.BytesSent
+		totals.BytesReceived += contactStats.BytesReceived
+		totals.SentBytesPerSecond += contactStats.SentBytesPerSecond
+		totals.ReceivedBytesPerSecond += contactStats.ReceivedBytesPerSecond
+	}
+	if len(stats.Contacts) > 1 {
+		gui.WriteInfo(formatContactStats(totals), s.g, "stats")
+	}
+	return nil
+}
+
+func StatsCommand(g *gocui.Gui, source StatsSource) commands.Command {
+	return &StatsCmd{
+		g:      g,
+		source: source,
+	}
+}
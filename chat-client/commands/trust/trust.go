@@ -0,0 +1,168 @@
+package trust
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	trustCommandName  = "trust"
+	acceptSubCommand  = "accept"
+	trustInfoCategory = "trust"
+)
+
+var (
+	ErrInvalidArguments = errors.New("trust command received invalid arguments")
+	ErrNoSuchKeyChange  = errors.New("there is no key change with given number")
+)
+
+// KeyChanges gives access to the audit log of keys that differed from the pinned ones
+type KeyChanges interface {
+	KeyChanges() []*types.KeyChange
+	AcceptKeyChange(change *types.KeyChange) error
+}
+
+// shortKey is enough of the key to tell it apart from the other one when reading it out
+func shortKey(key string) string {
+	if len(key) > 8 {
+		return key[:8] + "..."
+	}
+	return key
+}
+
+// withArticle prepends the indefinite article to the source of the keys, such as "message" or "invite"
+func withArticle(source string) string {
+	if source != "" && strings.ContainsRune("aeiou", rune(source[0])) {
+		return "an " + source
+	}
+	return "a " + source
+}
+
+// Describe explains the key change to the user
+func Describe(change *types.KeyChange) string {
+	switch change.Kind {
+	case types.KeyChangeProvider:
+		return fmt.Sprintf("the key %s of %s was pinned with provider %s, but it was seen in %s with provider %s. "+
+			"They might have moved to another provider, or somebody might be trying to impersonate them.",
+			shortKey(change.Pinned.PublicKey),
+			change.Pinned.Alias,
+			shortKey(change.Pinned.ProviderPublicKey),
+			withArticle(change.Source),
+			shortKey(change.Seen.ProviderPublicKey),
+		)
+	case types.KeyChangeName:
+		return fmt.Sprintf("%s was pinned with key %s, but %s with a different key %s claims that name. "+
+			"They might have new keys, or somebody might be trying to impersonate them.",
+			change.Pinned.Alias,
+			shortKey(change.Pinned.PublicKey),
+			withArticle(change.Source),
+			shortKey(change.Seen.PublicKey),
+		)
+	default:
+		return fmt.Sprintf("keys different from the pinned ones were seen in %s", withArticle(change.Source))
+	}
+}
+
+type TrustCmd struct {
+	g          *gocui.Gui
+	keyChanges KeyChanges
+}
+
+func (t *TrustCmd) Name() string {
+	return trustCommandName
+}
+
+func (t *TrustCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", trustCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", trustCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s %s <key_change_number>\n", trustCommandName, acceptSubCommand)
+	return usageString
+}
+
+func (t *TrustCmd) list() {
+	changes := t.keyChanges.KeyChanges()
+	if len(changes) == 0 {
+		gui.WriteInfo("no key changes were seen, all of your contacts use the keys they were first seen with\n",
+			t.g,
+			trustInfoCategory,
+		)
+		return
+	}
+
+	for i, change := range changes {
+		status := "pending"
+		if change.Accepted {
+			status = "accepted"
+		}
+		gui.WriteInfo(fmt.Sprintf("[%d] %s (%s): %s\n",
+			i+1,
+			change.At.Format("2006-01-02 15:04:05"),
+			status,
+			Describe(change),
+		), t.g, trustInfoCategory)
+	}
+	gui.WriteInfo(fmt.Sprintf("make sure the new keys really belong to your contact, ideally by asking them in person, "+
+		"then use /%s %s <number> to pin them instead\n",
+		trustCommandName,
+		acceptSubCommand,
+	), t.g, trustInfoCategory)
+}
+
+// key changes are referred to by their position on the list printed by `/trust`, starting from 1
+func (t *TrustCmd) accept(number string) error {
+	i, err := strconv.Atoi(number)
+	if err != nil {
+		return ErrInvalidArguments
+	}
+	changes := t.keyChanges.KeyChanges()
+	if i < 1 || i > len(changes) {
+		gui.WriteNotice(fmt.Sprintf("Could not accept the key change: %v\n", ErrNoSuchKeyChange), t.g, "error")
+		return nil
+	}
+	change := changes[i-1]
+	if change.Accepted {
+		gui.WriteNotice("the key change has already been accepted\n", t.g)
+		return nil
+	}
+	if err := t.keyChanges.AcceptKeyChange(change); err != nil {
+		gui.WriteNotice(fmt.Sprintf("Could not accept the key change: %v\n", err), t.g, "error")
+		return nil
+	}
+	gui.WriteNotice(fmt.Sprintf("pinned the new keys of %s\n", change.Pinned.Alias), t.g)
+	return nil
+}
+
+// we expect the following:
+// just `trust` which will list all of the key changes seen so far
+// `trust accept <number>` which will pin the keys of the given key change instead of the original ones
+func (t *TrustCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != trustCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", t.Name(), args[0])
+	}
+
+	switch {
+	case len(args) == 1:
+		t.list()
+		return nil
+	case len(args) == 3 && args[1] == acceptSubCommand:
+		return t.accept(args[2])
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+// TrustCommand creates new instance of a TrustCommand
+func TrustCommand(g *gocui.Gui, keyChanges KeyChanges) commands.Command {
+	return &TrustCmd{
+		g:          g,
+		keyChanges: keyChanges,
+	}
+}
@@ -0,0 +1,155 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/fingerprint"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+)
+
+const (
+	verifyCommandName  = "verify"
+	confirmSubCommand  = "confirm"
+	clearSubCommand    = "clear"
+	verifyInfoCategory = "verify"
+)
+
+var (
+	ErrInvalidArguments   = errors.New("verify command received invalid arguments")
+	ErrMalformedRecipient = errors.New("malformed recipient data")
+	ErrUnknownAlias       = errors.New("no client with given alias exists")
+	ErrAmbiguousAlias     = errors.New("more than a single client has given alias")
+)
+
+// VerificationStore remembers which clients the user has confirmed the safety numbers of
+type VerificationStore interface {
+	SetVerified(*sphinx.PublicKey, *sphinx.PublicKey, bool)
+	IsVerified(*sphinx.PublicKey, *sphinx.PublicKey) bool
+}
+
+// Identity provides the public key of our own client
+type Identity interface {
+	PublicKey() []byte
+}
+
+type VerifyCmd struct {
+	g          *gocui.Gui
+	store      VerificationStore
+	aliasStore alias.AliasStore
+	identity   Identity
+	session    *types.Session
+}
+
+func (v *VerifyCmd) Name() string {
+	return verifyCommandName
+}
+
+func (v *VerifyCmd) Usage() string {
+	usageString := "\n"
+	usageString += fmt.Sprintf("\t/%s: \n", verifyCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s\n", verifyCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s <aliased_name>\n", verifyCommandName)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", verifyCommandName, confirmSubCommand)
+	usageString += fmt.Sprintf("\t\t - /%s %s\n", verifyCommandName, clearSubCommand)
+	return usageString
+}
+
+func (v *VerifyCmd) getCurrentRecipientKeys() (*sphinx.PublicKey, *sphinx.PublicKey) {
+	return utils.KeysFromBytes(v.session.Recipient().PubKey, v.session.Recipient().Provider.PubKey)
+}
+
+func (v *VerifyCmd) getKeysByAlias(name string) (*sphinx.PublicKey, *sphinx.PublicKey, error) {
+	aliases := v.aliasStore.GetAllAliasesByName(name)
+	switch len(aliases) {
+	case 0:
+		return nil, nil, ErrUnknownAlias
+	case 1:
+		return aliases[0].PublicKey, aliases[0].ProviderPublicKey, nil
+	default:
+		return nil, nil, ErrAmbiguousAlias
+	}
+}
+
+// showSafetyNumber writes the safety number of our conversation with the client, which the other side should see as well
+func (v *VerifyCmd) showSafetyNumber(name string, targetPub, providerPub *sphinx.PublicKey) {
+	safetyNumber := fingerprint.Compute(v.identity.PublicKey(), targetPub.Bytes())
+	status := "not verified"
+	if v.store.IsVerified(targetPub, providerPub) {
+		status = "verified"
+	}
+
+	info := fmt.Sprintf("Safety number of your conversation with %s (%s):\n%s\nor in words:\n%s\n",
+		name,
+		status,
+		safetyNumber.DigitsString(),
+		safetyNumber.WordsString(),
+	)
+	info += fmt.Sprintf("Compare it with the one %s sees by typing /%s, ideally in person or over a call. ", name, verifyCommandName)
+	info += fmt.Sprintf("If they match, open the conversation with %s and type /%s %s.\n", name, verifyCommandName, confirmSubCommand)
+	gui.WriteInfo(info, v.g, verifyInfoCategory)
+}
+
+// we expect the following:
+// just `verify` which will show the safety number of the conversation with the current recipient
+// `verify <alias>` which will show the safety number of the conversation with the specified contact
+// `verify confirm` which will mark the current recipient as verified
+// `verify clear` which will remove the verified mark of the current recipient
+func (v *VerifyCmd) Handle(args []string) error {
+	// sanity check
+	if args[0] != verifyCommandName {
+		return fmt.Errorf("invalid handler called. Expected: %s. got: %s", v.Name(), args[0])
+	}
+
+	switch len(args) {
+	case 1:
+		currentPub, currentProvPub := v.getCurrentRecipientKeys()
+		if currentPub == nil || currentProvPub == nil {
+			return ErrMalformedRecipient
+		}
+		v.showSafetyNumber(v.session.RecipientAlias(), currentPub, currentProvPub)
+		return nil
+	case 2:
+		switch args[1] {
+		case confirmSubCommand, clearSubCommand:
+			currentPub, currentProvPub := v.getCurrentRecipientKeys()
+			if currentPub == nil || currentProvPub == nil {
+				return ErrMalformedRecipient
+			}
+			verified := args[1] == confirmSubCommand
+			v.store.SetVerified(currentPub, currentProvPub, verified)
+			if verified {
+				gui.WriteNotice(fmt.Sprintf("marked %s as verified\n", v.session.RecipientAlias()), v.g)
+			} else {
+				gui.WriteNotice(fmt.Sprintf("removed the verified mark of %s\n", v.session.RecipientAlias()), v.g)
+			}
+			return nil
+		default:
+			targetPub, providerPub, err := v.getKeysByAlias(args[1])
+			if err != nil {
+				gui.WriteNotice(fmt.Sprintf("Could not verify %s: %v\n", args[1], err), v.g, "error")
+				return nil
+			}
+			v.showSafetyNumber(args[1], targetPub, providerPub)
+			return nil
+		}
+	default:
+		return ErrInvalidArguments
+	}
+}
+
+// VerifyCommand creates new instance of a VerifyCommand
+func VerifyCommand(g *gocui.Gui, store VerificationStore, aliasStore alias.AliasStore, identity Identity, session *types.Session) commands.Command {
+	return &VerifyCmd{
+		g:          g,
+		store:      store,
+		aliasStore: aliasStore,
+		identity:   identity,
+		session:    session,
+	}
+}
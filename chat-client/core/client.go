@@ -0,0 +1,345 @@
+// Package core implements the chat client independently of any user interface.
+// It can be embedded by any Go program wishing to chat over the mixnet,
+// with the terminal UI and the headless daemon being just its consumers.
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/nymtech/demo-mixnet-chat-client/chatconfig"
+	"github.com/nymtech/demo-mixnet-chat-client/logging"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/storage"
+	"github.com/nymtech/demo-mixnet-chat-client/transport"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	clientConfig "github.com/nymtech/nym-mixnet/client/config"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidUnknownSendersPolicy = errors.New("invalid policy for unknown senders")
+	ErrMalformedRecipient          = errors.New("malformed recipient data")
+)
+
+// UnknownSendersPolicy defines what happens to messages from senders without any stored alias
+type UnknownSendersPolicy int
+
+const (
+	// ShowUnknownSenders delivers the messages as if they came from any other contact
+	ShowUnknownSenders UnknownSendersPolicy = iota
+	// HoldUnknownSenders keeps the messages in the requests inbox until the sender is accepted or ignored
+	HoldUnknownSenders
+	// DropUnknownSenders silently discards the messages
+	DropUnknownSenders
+)
+
+// ParseUnknownSendersPolicy converts the textual representation of the policy, i.e. "show", "hold" or "drop"
+func ParseUnknownSendersPolicy(policy string) (UnknownSendersPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "show":
+		return ShowUnknownSenders, nil
+	case "hold":
+		return HoldUnknownSenders, nil
+	case "drop":
+		return DropUnknownSenders, nil
+	default:
+		return ShowUnknownSenders, ErrInvalidUnknownSendersPolicy
+	}
+}
+
+// Client is the UI-independent chat client.
+type Client struct {
+	chatStore storage.ChatStore
+	// so we wouldn't need to load it from file storage on every single received message
+	aliasCache     map[string]string
+	aliasCacheMu   sync.Mutex
+	unknownSenders UnknownSendersPolicy
+	messageNonce   int64
+	appDir         string
+	transport      transport.Transport
+	subscribers    *subscribers
+	stats          *statsCollector
+	probes         *probes
+	logger         *logging.Logger
+	log            *logrus.Entry
+
+	// guards checking the keys against the pinned ones and pinning them, so that the same change is not reported twice
+	pinMu sync.Mutex
+	// key changes reported while holding pinMu that the subscribers are told about once it is released
+	reportedKeyChanges []*types.KeyChange
+	// guards the changes of the groups, which are read, changed and stored again
+	groupMu sync.Mutex
+	// group messages that have arrived before the sender keys they are encrypted with
+	awaitingKey []*message.ChatMessage
+	// updates of the groups that have arrived before the changes they follow or before we have joined the groups
+	awaitingGroup []*message.ChatMessage
+	// groups the contacts are going to be added to once their signing keys arrive, also guarded by groupMu
+	awaitingSigningKey map[string][][]byte
+	// versions of the groups we have asked their owners to send us, also guarded by groupMu
+	syncRequested map[string]uint64
+	// changes of the groups made while holding groupMu that the subscribers are told about once it is released
+	groupChanges []*types.GroupChange
+
+	presence         *presenceTracker
+	presenceUpdateMu sync.Mutex
+	presenceInterval time.Duration
+
+	// messages from the active contact are never treated as coming from an unknown sender,
+	// as the user has explicitly chosen to talk to them
+	activeContactMu          sync.Mutex
+	activeContactKey         []byte
+	activeContactProviderKey []byte
+
+	haltedCh chan struct{}
+	haltOnce sync.Once
+}
+
+// New creates new instance of the chat client on top of the base mixnet client with the provided configurations.
+func New(baseClientCfg *clientConfig.Config, chatCfg *chatconfig.Config) (*Client, error) {
+	unknownSenders, err := ParseUnknownSendersPolicy(chatCfg.Receiving.UnknownSenders)
+	if err != nil {
+		return nil, err
+	}
+
+	mixnet, err := transport.NewMixnet(baseClientCfg,
+		chatCfg.Receiving.MinPollInterval.Duration,
+		chatCfg.Receiving.MaxPollInterval.Duration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	appDir := chatCfg.Storage.FullDirectory(baseClientCfg.Client.FullMixAppsDir(), baseClientCfg.Client.ID)
+	chatStore, err := storage.NewDbStore(chatCfg.Storage.File, appDir)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := logging.New(chatCfg.Logging.FullFile(appDir),
+		chatCfg.Logging.Level,
+		chatCfg.Logging.Disable,
+		chatCfg.Logging.MaxSizeBytes(),
+		chatCfg.Logging.MaxBackups,
+	)
+	if err != nil {
+		chatStore.Close()
+		return nil, err
+	}
+
+	c := NewWithTransport(mixnet, chatStore, appDir, unknownSenders)
+	c.SetLogger(logger)
+	if !chatCfg.Presence.Disable {
+		c.SetPresenceRefreshInterval(chatCfg.Presence.RefreshInterval.Duration)
+	}
+	return c, nil
+}
+
+// NewWithTransport creates new instance of the chat client using arbitrary transport and store,
+// for example to run it on top of an in-memory network.
+func NewWithTransport(t transport.Transport, chatStore storage.ChatStore, appDir string, unknownSenders UnknownSendersPolicy) *Client {
+	c := &Client{
+		haltedCh:           make(chan struct{}),
+		transport:          t,
+		chatStore:          chatStore,
+		aliasCache:         make(map[string]string),
+		unknownSenders:     unknownSenders,
+		appDir:             appDir,
+		subscribers:        newSubscribers(),
+		stats:              newStatsCollector(),
+		probes:             newProbes(),
+		presence:           newPresenceTracker(),
+		awaitingSigningKey: make(map[string][][]byte),
+		syncRequested:      make(map[string]uint64),
+	}
+	c.SetLogger(logging.Discard())
+	return c
+}
+
+// SetLogger replaces the logger of the client, which by default discards everything. It must be called before Start.
+func (c *Client) SetLogger(logger *logging.Logger) {
+	c.logger = logger
+	c.log = logger.GetLogger("core")
+}
+
+// Logger returns the logger of the client, so that the user interfaces could use it as well.
+func (c *Client) Logger() *logging.Logger {
+	return c.logger
+}
+
+// Start starts the base mixnet client and the background processing of received messages.
+func (c *Client) Start() error {
+	if err := c.transport.Start(); err != nil {
+		c.log.WithError(err).Error("Failed to start the transport")
+		return err
+	}
+	c.log.WithFields(logrus.Fields{
+		"publicKey":         base64.URLEncoding.EncodeToString(c.PublicKey()),
+		"providerPublicKey": base64.URLEncoding.EncodeToString(c.ProviderPublicKey()),
+	}).Info("Started the chat client")
+	// contacts might have been added while the client was not running, for example with `run --addContact`
+	c.pinContacts()
+	go c.receiveInBackground()
+
+	// the transport has already fetched the initial network view
+	c.updatePresence()
+	if c.presenceInterval > 0 {
+		go c.refreshPresenceInBackground()
+	}
+	return nil
+}
+
+// AppDir returns the directory holding all chat-specific data of this client.
+func (c *Client) AppDir() string {
+	return c.appDir
+}
+
+// Store returns the underlying chat store.
+func (c *Client) Store() storage.ChatStore {
+	return c.chatStore
+}
+
+// PublicKey returns our own public key.
+func (c *Client) PublicKey() []byte {
+	return c.transport.PublicKey()
+}
+
+// ProviderPublicKey returns the public key of our provider.
+func (c *Client) ProviderPublicKey() []byte {
+	return c.transport.ProviderPublicKey()
+}
+
+// SetActiveContact marks the contact the user is currently talking to.
+// Nil keys mean there is no active conversation.
+func (c *Client) SetActiveContact(targetKey, providerKey []byte) {
+	c.activeContactMu.Lock()
+	defer c.activeContactMu.Unlock()
+	c.activeContactKey = targetKey
+	c.activeContactProviderKey = providerKey
+}
+
+func (c *Client) createMessagePayload(msg string) *message.ChatMessage {
+	return &message.ChatMessage{
+		Content:                 []byte(msg),
+		SenderPublicKey:         c.PublicKey(),
+		SenderProviderPublicKey: c.ProviderPublicKey(),
+		MessageNonce:            atomic.AddInt64(&c.messageNonce, 1),
+		SenderTimestamp:         time.Now().UnixNano(),
+		Signature:               nil, // will be done later
+	}
+}
+
+// send passes the payload to the transport.
+// Note that the transport might still pick up the message from its queue after the context is done.
+func (c *Client) send(ctx context.Context, recipient config.ClientConfig, payload *message.ChatMessage) error {
+	recipientKey, recipientProvKey := utils.KeysFromBytes(recipient.PubKey, recipient.Provider.PubKey)
+	if recipientKey == nil || recipientProvKey == nil {
+		c.log.WithField("recipient", recipient.Id).Warn("Tried to send message to malformed recipient")
+		return ErrMalformedRecipient
+	}
+	log := c.log.WithFields(logrus.Fields{
+		"recipient": c.DisplayName(recipient.PubKey, recipient.Provider.PubKey),
+		"kind":      payload.Kind,
+	})
+
+	chatMsg, err := proto.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	// the mixnet client blocks until the packet is picked up from its outgoing queue
+	sendStart := time.Now()
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- c.transport.SendMessage(chatMsg, recipient)
+	}()
+	select {
+	case <-ctx.Done():
+		log.WithError(ctx.Err()).Warn("Gave up sending the message")
+		c.stats.recordFailedSend(recipient.PubKey, recipient.Provider.PubKey)
+		return ctx.Err()
+	case err := <-sendErr:
+		if err != nil {
+			log.WithError(err).Error("Failed to send the message")
+			c.stats.recordFailedSend(recipient.PubKey, recipient.Provider.PubKey)
+			return err
+		}
+	}
+
+	took := time.Since(sendStart)
+	log.WithFields(logrus.Fields{"size": len(chatMsg), "took": took}).Debug("Sent the message")
+	c.stats.recordSent(recipient.PubKey, recipient.Provider.PubKey, len(chatMsg), took)
+	return nil
+}
+
+// SendTo sends the message to the preferred endpoint of the recipient and stores it in the conversation history.
+// Note that the transport might still pick up the message from its queue after the context is done.
+func (c *Client) SendTo(ctx context.Context, recipient config.ClientConfig, content string) error {
+	// the recipient might have been merged into another contact or switched its preferred endpoint
+	recipient, err := c.preferredRecipient(recipient)
+	if err != nil {
+		return err
+	}
+	protoPayload := c.createMessagePayload(content)
+	if err := c.send(ctx, recipient, protoPayload); err != nil {
+		return err
+	}
+
+	recipientKey, recipientProvKey := utils.KeysFromBytes(recipient.PubKey, recipient.Provider.PubKey)
+	c.chatStore.StoreMessage(recipientKey, recipientProvKey, protoPayload)
+	// the recipient might not have been reachable when we last updated our profile
+	go c.shareProfile(recipient)
+	return nil
+}
+
+// Send sends the message to the specified contact. At least its provider has to be present in the current network view.
+func (c *Client) Send(ctx context.Context, target types.Contact, content string) error {
+	recipient, err := c.resolveRecipient(target)
+	if err != nil {
+		return err
+	}
+	return c.SendTo(ctx, recipient, content)
+}
+
+// Flush waits until everything sent so far has been written to the network, so that the client could be shut down
+// without losing any of the messages. Note that the transport keeps flushing in the background after the context is done.
+func (c *Client) Flush(ctx context.Context) error {
+	flushErr := make(chan error, 1)
+	go func() {
+		flushErr <- c.transport.Flush()
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-flushErr:
+		return err
+	}
+}
+
+// Wait waits till the client is terminated for any reason.
+func (c *Client) Wait() {
+	<-c.haltedCh
+}
+
+// Shutdown cleanly shuts down a given client instance.
+func (c *Client) Shutdown() {
+	c.haltOnce.Do(func() { c.halt() })
+}
+
+// calls any required cleanup code
+func (c *Client) halt() {
+	// close any listeners, free resources, etc
+	c.log.Info("Shutting down the chat client")
+	c.transport.Shutdown()
+	c.subscribers.removeAll()
+	c.logger.Close()
+
+	close(c.haltedCh)
+}
@@ -0,0 +1,297 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/helpers/topology"
+	"github.com/nymtech/nym-mixnet/sphinx"
+)
+
+const (
+	// VerifiedMark follows the names of the clients whose safety numbers have been confirmed
+	VerifiedMark = " ✓"
+)
+
+var (
+	ErrUnknownAlias      = errors.New("no client with given alias exists")
+	ErrAmbiguousAlias    = errors.New("more than a single client has given alias")
+	ErrUnknownProvider   = errors.New("the provider of the client is not present in the current network view")
+	ErrInvalidAliasName  = errors.New("invalid alias name")
+	ErrNoContactProvided = errors.New("neither alias nor keys of the contact were provided")
+)
+
+func (c *Client) makeAliasCacheKey(senderPublicKey, senderProviderPublicKey []byte) string {
+	b64SenderKey := base64.URLEncoding.EncodeToString(senderPublicKey)
+	b64SenderProviderKey := base64.URLEncoding.EncodeToString(senderProviderPublicKey)
+	cacheEntryKey := b64SenderKey + b64SenderProviderKey
+	return cacheEntryKey
+}
+
+// naming things is difficult...
+func (c *Client) recoverKeysFromCacheKey(key string) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	// due to both keys having same and constant length, we can just split the key in half
+	// and due to it being in base64, hence containing only ASCII, we don't need to bother with runes and UTF8 encoding
+	bKey := []byte(key)
+	key1 := string(bKey[:len(bKey)/2])
+	key2 := string(bKey[len(bKey)/2:])
+
+	return utils.KeysFromB64Strings(key1, key2)
+}
+
+// RefreshAliasCache makes sure that any entry present in the cache still exists in the store
+// and pins the keys of new contacts. It has to be called whenever aliases are modified directly in the store.
+func (c *Client) RefreshAliasCache() {
+	c.aliasCacheMu.Lock()
+	for k, v := range c.aliasCache {
+		clientKey, clientProviderKey := c.recoverKeysFromCacheKey(k)
+		storedAlias := c.chatStore.GetAlias(clientKey, clientProviderKey)
+		if storedAlias == nil || storedAlias.AssignedName != v {
+			delete(c.aliasCache, k)
+		}
+	}
+	c.aliasCacheMu.Unlock()
+	c.pinContacts()
+}
+
+// LookupAlias returns the stored alias of the client with given keys or nil if they are malformed.
+func (c *Client) LookupAlias(senderPublicKey, senderProviderPublicKey []byte) *alias.Alias {
+	senderKey, senderProvKey := utils.KeysFromBytes(senderPublicKey, senderProviderPublicKey)
+	if senderKey != nil && senderProvKey != nil {
+		return c.chatStore.GetAlias(senderKey, senderProvKey)
+	}
+	return nil
+}
+
+// DefaultDisplayName returns the name used for clients without any alias.
+func (c *Client) DefaultDisplayName(key []byte) string {
+	b64Key := base64.URLEncoding.EncodeToString(key)
+	return "??? - " + b64Key[:8] + "..."
+}
+
+// DisplayName returns the alias of the client, marked if its keys have been verified,
+// or the default name if it has not got one, followed by the name it suggests for itself, if any.
+func (c *Client) DisplayName(senderPublicKey, senderProviderPublicKey []byte) string {
+	senderPublicKey, senderProviderPublicKey = c.ContactOf(senderPublicKey, senderProviderPublicKey)
+	assignedName := c.assignedName(senderPublicKey, senderProviderPublicKey)
+	if assignedName == "" {
+		if suggested := c.suggestedName(senderPublicKey, senderProviderPublicKey); suggested != "" {
+			return c.DefaultDisplayName(senderPublicKey) + " ~" + suggested
+		}
+		return c.DefaultDisplayName(senderPublicKey)
+	}
+	if c.IsVerified(senderPublicKey, senderProviderPublicKey) {
+		return assignedName + VerifiedMark
+	}
+	return assignedName
+}
+
+// assignedName returns the alias of the client or an empty string if it has not got one
+func (c *Client) assignedName(senderPublicKey, senderProviderPublicKey []byte) string {
+	c.aliasCacheMu.Lock()
+	defer c.aliasCacheMu.Unlock()
+	cacheEntryKey := c.makeAliasCacheKey(senderPublicKey, senderProviderPublicKey)
+	displayName, ok := c.aliasCache[cacheEntryKey]
+	if ok {
+		return displayName
+	}
+	if storedAlias := c.LookupAlias(senderPublicKey, senderProviderPublicKey); storedAlias != nil {
+		// it's not in cache so update the cache
+		if storedAlias.AssignedName != "" {
+			c.aliasCache[cacheEntryKey] = storedAlias.AssignedName
+			return storedAlias.AssignedName
+		}
+	}
+	return ""
+}
+
+// suggestedName returns the name from the profile of the client or an empty string if it has not sent any
+func (c *Client) suggestedName(senderPublicKey, senderProviderPublicKey []byte) string {
+	senderKey, senderProvKey := utils.KeysFromBytes(senderPublicKey, senderProviderPublicKey)
+	if senderKey == nil || senderProvKey == nil {
+		return ""
+	}
+	if profile := c.chatStore.GetProfile(senderKey, senderProvKey); profile != nil {
+		return alias.SuggestedName(profile.Name)
+	}
+	return ""
+}
+
+// IsVerified tells whether the user has confirmed the safety number of the conversation with the client.
+func (c *Client) IsVerified(publicKey, providerPublicKey []byte) bool {
+	targetPub, providerPub := utils.KeysFromBytes(publicKey, providerPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return false
+	}
+	return c.chatStore.IsVerified(targetPub, providerPub)
+}
+
+func (c *Client) toContact(targetPub, providerPub *sphinx.PublicKey, assignedName string) *types.Contact {
+	return &types.Contact{
+		Alias:             assignedName,
+		PublicKey:         base64.URLEncoding.EncodeToString(targetPub.Bytes()),
+		ProviderPublicKey: base64.URLEncoding.EncodeToString(providerPub.Bytes()),
+	}
+}
+
+// resolveContact finds the keys of the contact either from the explicitly provided ones or from its alias.
+// Keys of any endpoint of a contact resolve to its preferred one.
+func (c *Client) resolveContact(target types.Contact) (*sphinx.PublicKey, *sphinx.PublicKey, error) {
+	if target.PublicKey != "" || target.ProviderPublicKey != "" {
+		targetPub, providerPub := utils.KeysFromB64Strings(target.PublicKey, target.ProviderPublicKey)
+		if targetPub == nil || providerPub == nil {
+			return nil, nil, ErrMalformedRecipient
+		}
+		targetPub, providerPub = c.contactOf(targetPub, providerPub)
+		return targetPub, providerPub, nil
+	}
+	if target.Alias == "" {
+		return nil, nil, ErrNoContactProvided
+	}
+
+	aliases := c.chatStore.GetAllAliasesByName(target.Alias)
+	switch len(aliases) {
+	case 0:
+		return nil, nil, ErrUnknownAlias
+	case 1:
+		return aliases[0].PublicKey, aliases[0].ProviderPublicKey, nil
+	default:
+		return nil, nil, ErrAmbiguousAlias
+	}
+}
+
+// recipientFromDirectory looks up the full client information, such as its provider address, in the current network view.
+// If the registration of the client has not propagated to the directory yet, its provider is looked up instead.
+func (c *Client) recipientFromDirectory(targetPub, providerPub *sphinx.PublicKey) (config.ClientConfig, error) {
+	for _, client := range c.transport.Clients() {
+		if bytes.Equal(client.PubKey, targetPub.Bytes()) && bytes.Equal(client.Provider.PubKey, providerPub.Bytes()) {
+			return client, nil
+		}
+	}
+
+	for _, provider := range c.transport.Providers() {
+		if bytes.Equal(provider.PubKey, providerPub.Bytes()) {
+			provider := provider
+			c.log.WithField("recipient", c.DisplayName(targetPub.Bytes(), providerPub.Bytes())).
+				Debug("Recipient is not in the directory yet, using its provider directly")
+			return config.ClientConfig{
+				Id:       base64.URLEncoding.EncodeToString(targetPub.Bytes()),
+				Host:     topology.DefaultClientHost,
+				Port:     topology.DefaultClientPort,
+				PubKey:   targetPub.Bytes(),
+				Provider: &provider,
+			}, nil
+		}
+	}
+	return config.ClientConfig{}, ErrUnknownProvider
+}
+
+// resolveRecipient finds the full client information of the contact in the current network view
+func (c *Client) resolveRecipient(target types.Contact) (config.ClientConfig, error) {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return config.ClientConfig{}, err
+	}
+	return c.recipientFromDirectory(targetPub, providerPub)
+}
+
+// ResolveRecipient finds the full client information of the contact, specified either by its alias or its keys,
+// required to send messages to it. It fails with ErrUnknownProvider if not even the provider of the contact
+// is present in the current network view, which might change once it is refreshed.
+func (c *Client) ResolveRecipient(target types.Contact) (config.ClientConfig, error) {
+	return c.resolveRecipient(target)
+}
+
+// UpdateNetworkView fetches the current network topology from the directory server
+// and updates the presence of the clients accordingly.
+func (c *Client) UpdateNetworkView() error {
+	if err := c.transport.UpdateNetworkView(); err != nil {
+		return err
+	}
+	c.updatePresence()
+	return nil
+}
+
+// DirectoryClients returns all clients present in the last fetched network view.
+func (c *Client) DirectoryClients() []config.ClientConfig {
+	return c.transport.Clients()
+}
+
+// Contacts returns all clients with assigned aliases.
+func (c *Client) Contacts() []*types.Contact {
+	aliases := c.chatStore.GetAllAliases()
+	contacts := make([]*types.Contact, len(aliases))
+	for i, storedAlias := range aliases {
+		contacts[i] = c.toContact(storedAlias.PublicKey, storedAlias.ProviderPublicKey, storedAlias.AssignedName)
+	}
+	return contacts
+}
+
+// Directory refreshes the network view and returns all clients present in it.
+func (c *Client) Directory() ([]*types.Contact, error) {
+	if err := c.UpdateNetworkView(); err != nil {
+		return nil, err
+	}
+	contacts := make([]*types.Contact, 0, len(c.transport.Clients()))
+	for _, client := range c.DirectoryClients() {
+		targetPub, providerPub := utils.KeysFromBytes(client.PubKey, client.Provider.PubKey)
+		if targetPub == nil || providerPub == nil {
+			continue
+		}
+		assignedName := ""
+		if storedAlias := c.chatStore.GetAlias(targetPub, providerPub); storedAlias != nil {
+			assignedName = storedAlias.AssignedName
+		}
+		contacts = append(contacts, c.toContact(targetPub, providerPub, assignedName))
+	}
+	return contacts, nil
+}
+
+// AddAlias assigns the alias to the client with the specified keys.
+func (c *Client) AddAlias(target types.Contact) error {
+	if target.Alias == "" || !alias.IsValidName(target.Alias) {
+		return ErrInvalidAliasName
+	}
+	targetPub, providerPub := utils.KeysFromB64Strings(target.PublicKey, target.ProviderPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
+	}
+	// the alias belongs to the contact, whichever of its endpoints was specified
+	targetPub, providerPub = c.contactOf(targetPub, providerPub)
+	c.chatStore.StoreAlias(&alias.Alias{
+		AssignedName:      target.Alias,
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+	})
+	c.RefreshAliasCache()
+	return nil
+}
+
+// RemoveAlias removes the alias of the specified contact.
+func (c *Client) RemoveAlias(target types.Contact) error {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return err
+	}
+	c.chatStore.RemoveAliasByKeys(targetPub, providerPub)
+	c.RefreshAliasCache()
+	return nil
+}
+
+// History returns all stored messages exchanged with the specified contact.
+func (c *Client) History(target types.Contact) ([]*types.Message, error) {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return nil, err
+	}
+	storedMsgs := c.chatStore.GetHistory(targetPub, providerPub)
+	msgs := make([]*types.Message, len(storedMsgs))
+	for i, msg := range storedMsgs {
+		msgs[i] = c.toMessage(msg)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/storage"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrSameContact   = errors.New("both clients already belong to the same contact")
+	ErrNotAnEndpoint = errors.New("the client is not an additional endpoint of any contact")
+)
+
+func sameKeys(pub1, providerPub1, pub2, providerPub2 *sphinx.PublicKey) bool {
+	return bytes.Equal(pub1.Bytes(), pub2.Bytes()) && bytes.Equal(providerPub1.Bytes(), providerPub2.Bytes())
+}
+
+// contactOf returns the keys the contact reachable at the endpoint is stored under, i.e. its preferred endpoint,
+// which are the keys of the endpoint itself unless it has been linked to another contact.
+func (c *Client) contactOf(targetPub, providerPub *sphinx.PublicKey) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	contactPub, contactProviderPub := c.chatStore.GetContactOf(&storage.Endpoint{
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+	})
+	if contactPub == nil || contactProviderPub == nil {
+		return targetPub, providerPub
+	}
+	return contactPub, contactProviderPub
+}
+
+func (c *Client) sameContact(pub1, providerPub1, pub2, providerPub2 *sphinx.PublicKey) bool {
+	contactPub1, contactProviderPub1 := c.contactOf(pub1, providerPub1)
+	contactPub2, contactProviderPub2 := c.contactOf(pub2, providerPub2)
+	return sameKeys(contactPub1, contactProviderPub1, contactPub2, contactProviderPub2)
+}
+
+// ContactOf returns the keys of the preferred endpoint of the contact reachable at the given keys,
+// which everything about the contact is stored under. Unless they have been linked to another contact,
+// or are malformed, they are the given keys themselves.
+func (c *Client) ContactOf(publicKey, providerPublicKey []byte) ([]byte, []byte) {
+	targetPub, providerPub := utils.KeysFromBytes(publicKey, providerPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return publicKey, providerPublicKey
+	}
+	contactPub, contactProviderPub := c.contactOf(targetPub, providerPub)
+	return contactPub.Bytes(), contactProviderPub.Bytes()
+}
+
+// preferredRecipient replaces the recipient with the preferred endpoint of its contact if it is not the one already
+func (c *Client) preferredRecipient(recipient config.ClientConfig) (config.ClientConfig, error) {
+	recipientKey, recipientProvKey := utils.KeysFromBytes(recipient.PubKey, recipient.Provider.PubKey)
+	if recipientKey == nil || recipientProvKey == nil {
+		return recipient, nil
+	}
+	contactPub, contactProviderPub := c.contactOf(recipientKey, recipientProvKey)
+	if sameKeys(recipientKey, recipientProvKey, contactPub, contactProviderPub) {
+		return recipient, nil
+	}
+	return c.recipientFromDirectory(contactPub, contactProviderPub)
+}
+
+// Endpoints returns all endpoints of the contact, starting with the preferred one.
+func (c *Client) Endpoints(target types.Contact) ([]*types.Endpoint, error) {
+	contactPub, contactProviderPub, err := c.resolveContact(target)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []*types.Endpoint{{
+		PublicKey:         base64.URLEncoding.EncodeToString(contactPub.Bytes()),
+		ProviderPublicKey: base64.URLEncoding.EncodeToString(contactProviderPub.Bytes()),
+		Preferred:         true,
+	}}
+	for _, endpoint := range c.chatStore.GetEndpoints(contactPub, contactProviderPub) {
+		endpoints = append(endpoints, &types.Endpoint{
+			PublicKey:         base64.URLEncoding.EncodeToString(endpoint.PublicKey.Bytes()),
+			ProviderPublicKey: base64.URLEncoding.EncodeToString(endpoint.ProviderPublicKey.Bytes()),
+		})
+	}
+	return endpoints, nil
+}
+
+// MergeContacts makes all endpoints of the second contact endpoints of the first one, moving its history over.
+// The alias of the first contact is kept, while the one of the second contact is removed.
+func (c *Client) MergeContacts(into, from types.Contact) error {
+	intoPub, intoProviderPub, err := c.resolveContact(into)
+	if err != nil {
+		return err
+	}
+	fromPub, fromProviderPub, err := c.resolveContact(from)
+	if err != nil {
+		return err
+	}
+	if sameKeys(intoPub, intoProviderPub, fromPub, fromProviderPub) {
+		return ErrSameContact
+	}
+
+	c.mergeInto(intoPub, intoProviderPub, fromPub, fromProviderPub)
+	c.log.WithFields(logrus.Fields{
+		"into": c.DisplayName(intoPub.Bytes(), intoProviderPub.Bytes()),
+		"from": base64.URLEncoding.EncodeToString(fromPub.Bytes()),
+	}).Info("Merged contacts")
+	c.RefreshAliasCache()
+	return nil
+}
+
+func (c *Client) mergeInto(intoPub, intoProviderPub, fromPub, fromProviderPub *sphinx.PublicKey) {
+	for _, endpoint := range c.chatStore.GetEndpoints(fromPub, fromProviderPub) {
+		c.chatStore.LinkEndpoint(endpoint, intoPub, intoProviderPub)
+	}
+	c.chatStore.LinkEndpoint(&storage.Endpoint{
+		PublicKey:         fromPub,
+		ProviderPublicKey: fromProviderPub,
+	}, intoPub, intoProviderPub)
+
+	c.chatStore.MoveHistory(fromPub, fromProviderPub, intoPub, intoProviderPub)
+	if note := c.chatStore.GetNote(fromPub, fromProviderPub); note != "" && c.chatStore.GetNote(intoPub, intoProviderPub) == "" {
+		c.chatStore.SetNote(intoPub, intoProviderPub, note)
+	}
+	if c.chatStore.IsFavourite(fromPub, fromProviderPub) {
+		c.chatStore.SetFavourite(intoPub, intoProviderPub, true)
+	}
+	if lastSeen := c.chatStore.GetLastSeen(fromPub, fromProviderPub); lastSeen.After(c.chatStore.GetLastSeen(intoPub, intoProviderPub)) {
+		c.chatStore.SetLastSeen(intoPub, intoProviderPub, lastSeen)
+	}
+	// unlike the verified mark, which belongs to the keys, the details belong to the contact
+	c.chatStore.SetNote(fromPub, fromProviderPub, "")
+	c.chatStore.SetFavourite(fromPub, fromProviderPub, false)
+	c.chatStore.RemoveAliasByKeys(fromPub, fromProviderPub)
+}
+
+// PreferEndpoint makes the endpoint, specified by its keys, the one the messages to its contact are sent to.
+// Everything about the contact is moved over to it.
+func (c *Client) PreferEndpoint(endpoint types.Contact) error {
+	targetPub, providerPub := utils.KeysFromB64Strings(endpoint.PublicKey, endpoint.ProviderPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
+	}
+	if !c.preferEndpoint(targetPub, providerPub) {
+		return ErrNotAnEndpoint
+	}
+	c.log.WithField("contact", c.DisplayName(targetPub.Bytes(), providerPub.Bytes())).Info("Changed the preferred endpoint")
+	c.RefreshAliasCache()
+	return nil
+}
+
+// preferEndpoint returns false if the endpoint is not linked to any contact
+func (c *Client) preferEndpoint(targetPub, providerPub *sphinx.PublicKey) bool {
+	newEndpoint := &storage.Endpoint{
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+	}
+	oldPub, oldProviderPub := c.chatStore.GetContactOf(newEndpoint)
+	if oldPub == nil || oldProviderPub == nil {
+		return false
+	}
+
+	for _, endpoint := range c.chatStore.GetEndpoints(oldPub, oldProviderPub) {
+		if sameKeys(endpoint.PublicKey, endpoint.ProviderPublicKey, targetPub, providerPub) {
+			c.chatStore.UnlinkEndpoint(endpoint)
+		} else {
+			c.chatStore.LinkEndpoint(endpoint, targetPub, providerPub)
+		}
+	}
+	c.chatStore.LinkEndpoint(&storage.Endpoint{
+		PublicKey:         oldPub,
+		ProviderPublicKey: oldProviderPub,
+	}, targetPub, providerPub)
+	c.moveContact(oldPub, oldProviderPub, targetPub, providerPub)
+	return true
+}
+
+// moveContact moves everything the user has assigned to the old keys of the contact to the new ones
+func (c *Client) moveContact(oldPub, oldProviderPub, newPub, newProviderPub *sphinx.PublicKey) {
+	if storedAlias := c.chatStore.GetAlias(oldPub, oldProviderPub); storedAlias != nil && storedAlias.AssignedName != "" {
+		c.chatStore.StoreAlias(&alias.Alias{
+			AssignedName:      storedAlias.AssignedName,
+			PublicKey:         newPub,
+			ProviderPublicKey: newProviderPub,
+		})
+		c.chatStore.RemoveAliasByKeys(oldPub, oldProviderPub)
+	}
+	c.chatStore.MoveHistory(oldPub, oldProviderPub, newPub, newProviderPub)
+	if note := c.chatStore.GetNote(oldPub, oldProviderPub); note != "" {
+		c.chatStore.SetNote(newPub, newProviderPub, note)
+		c.chatStore.SetNote(oldPub, oldProviderPub, "")
+	}
+	if c.chatStore.IsFavourite(oldPub, oldProviderPub) {
+		c.chatStore.SetFavourite(newPub, newProviderPub, true)
+		c.chatStore.SetFavourite(oldPub, oldProviderPub, false)
+	}
+	if c.chatStore.IsMuted(oldPub, oldProviderPub) {
+		c.chatStore.Mute(newPub, newProviderPub)
+		c.chatStore.Unmute(oldPub, oldProviderPub)
+	}
+	if lastSeen := c.chatStore.GetLastSeen(oldPub, oldProviderPub); lastSeen.After(c.chatStore.GetLastSeen(newPub, newProviderPub)) {
+		c.chatStore.SetLastSeen(newPub, newProviderPub, lastSeen)
+	}
+	// the safety number depends only on the public keys, so it still holds if just the provider has changed.
+	// Otherwise the mark stays with the old keys it belongs to.
+	if bytes.Equal(oldPub.Bytes(), newPub.Bytes()) && c.chatStore.IsVerified(oldPub, oldProviderPub) {
+		c.chatStore.SetVerified(newPub, newProviderPub, true)
+	}
+}
+
+// SplitEndpoint unlinks the endpoint from its contact, turning it into a standalone client without an alias.
+// The history of the contact stays with it.
+func (c *Client) SplitEndpoint(endpoint types.Contact) error {
+	targetPub, providerPub := utils.KeysFromB64Strings(endpoint.PublicKey, endpoint.ProviderPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
+	}
+	linked := &storage.Endpoint{
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+	}
+	if contactPub, _ := c.chatStore.GetContactOf(linked); contactPub == nil {
+		return ErrNotAnEndpoint
+	}
+	c.chatStore.UnlinkEndpoint(linked)
+	c.RefreshAliasCache()
+	return nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"reflect"
+	"testing"
+)
+
+func endpointsOf(t *testing.T, c *testClient, target types.Contact) []types.Endpoint {
+	t.Helper()
+	endpoints, err := c.Endpoints(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]types.Endpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		values[i] = *endpoint
+	}
+	return values
+}
+
+func endpointOf(c *testClient, preferred bool) types.Endpoint {
+	return types.Endpoint{
+		PublicKey:         c.contact().PublicKey,
+		ProviderPublicKey: c.contact().ProviderPublicKey,
+		Preferred:         preferred,
+	}
+}
+
+func TestMergedContacts(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	// bob has moved to a new client, which alice knows under another alias at first
+	alice, bob, movedBob := clients[0], clients[1], clients[2]
+	addAlias(t, alice, bob, "bob")
+	addAlias(t, alice, movedBob, "moved bob")
+
+	sendAll(t, bob, alice, []string{"old"})
+	nextReceived(t, alice, types.ReceivedMessage)
+
+	if err := alice.MergeContacts(bob.contact(), movedBob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.MergeContacts(bob.contact(), movedBob.contact()); err != ErrSameContact {
+		t.Errorf("merging the same contact again has returned %v instead of %v", err, ErrSameContact)
+	}
+	expected := []types.Endpoint{endpointOf(bob, true), endpointOf(movedBob, false)}
+	if endpoints := endpointsOf(t, alice, movedBob.contact()); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("the endpoints are %v instead of %v", endpoints, expected)
+	}
+
+	// whichever endpoint the message comes from, it belongs to the same contact
+	sendAll(t, movedBob, alice, []string{"new"})
+	if received := nextReceived(t, alice, types.ReceivedMessage); received.Message.Sender != "bob" {
+		t.Errorf("the message from the merged endpoint is from %q instead of %q", received.Message.Sender, "bob")
+	}
+	history, err := alice.History(types.Contact{Alias: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Content != "old" || history[1].Content != "new" {
+		t.Errorf("the history of the merged contact is %v", history)
+	}
+	if _, err := alice.History(types.Contact{Alias: "moved bob"}); err == nil {
+		t.Error("the alias of the merged contact has been kept")
+	}
+
+	// once the new endpoint is preferred, the messages go there
+	if err := alice.PreferEndpoint(movedBob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	expected = []types.Endpoint{endpointOf(movedBob, true), endpointOf(bob, false)}
+	if endpoints := endpointsOf(t, alice, types.Contact{Alias: "bob"}); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("the endpoints are %v instead of %v", endpoints, expected)
+	}
+	if err := alice.Send(context.Background(), types.Contact{Alias: "bob"}, "to the preferred endpoint"); err != nil {
+		t.Fatal(err)
+	}
+	if received := nextReceived(t, movedBob, types.ReceivedMessage); received.Message.Content != "to the preferred endpoint" {
+		t.Errorf("the preferred endpoint has received %q", received.Message.Content)
+	}
+	if history, err := alice.History(types.Contact{Alias: "bob"}); err != nil || len(history) != 3 {
+		t.Errorf("the history has not been moved to the preferred endpoint: %v, %v", history, err)
+	}
+
+	// the old endpoint is on its own again after the split
+	if err := alice.SplitEndpoint(bob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.SplitEndpoint(bob.contact()); err != ErrNotAnEndpoint {
+		t.Errorf("splitting the endpoint again has returned %v instead of %v", err, ErrNotAnEndpoint)
+	}
+	if err := alice.PreferEndpoint(bob.contact()); err != ErrNotAnEndpoint {
+		t.Errorf("preferring the split endpoint has returned %v instead of %v", err, ErrNotAnEndpoint)
+	}
+	expected = []types.Endpoint{endpointOf(movedBob, true)}
+	if endpoints := endpointsOf(t, alice, types.Contact{Alias: "bob"}); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("the endpoints are %v instead of %v", endpoints, expected)
+	}
+	if name := alice.DisplayName(bob.PublicKey(), bob.ProviderPublicKey()); name == "bob" {
+		t.Error("the split endpoint is still shown as the contact")
+	}
+}
@@ -0,0 +1,1179 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ed25519"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxGroupNameLength is the maximum length of the name of the group, in bytes
+	MaxGroupNameLength = 64
+
+	groupIDSize = 16
+	// how long we try to announce the change of the membership before giving up
+	groupUpdateTimeout = 30 * time.Second
+	// how many updates of the groups waiting for the preceding changes we keep at most
+	maxAwaitingGroup = 100
+	// what our signing key is derived for, it must never change or our signatures would stop being recognised
+	groupSigningKeyPurpose = "chat group signing key"
+	// what the key proving to a contact that our signing key is ours is derived for
+	signingKeyProofPurpose = "chat group signing key proof"
+)
+
+var (
+	ErrUnknownGroup     = errors.New("we are not a member of the group with given ID")
+	ErrInvalidGroupName = errors.New("invalid group name")
+	ErrAlreadyMember    = errors.New("the contact is already a member of the group")
+	ErrGroupSendFailed  = errors.New("the message could not be sent to some of the members")
+	ErrNotGroupMember   = errors.New("the contact is not a member of the group")
+	ErrNotAllowed       = errors.New("your role in the group does not allow that")
+	ErrBannedFromGroup  = errors.New("the contact is banned from the group")
+
+	errForgedChange      = errors.New("the change is not signed by a member allowed to make it")
+	errConflictingChange = errors.New("the change was made to a different state of the group")
+)
+
+func validateGroupName(name string) error {
+	if strings.TrimSpace(name) == "" || len(name) > MaxGroupNameLength || !utf8.ValidString(name) {
+		return ErrInvalidGroupName
+	}
+	return nil
+}
+
+func toProtoRole(role types.GroupRole) message.GroupRole {
+	switch role {
+	case types.GroupRoleOwner:
+		return message.GroupRole_OWNER
+	case types.GroupRoleAdmin:
+		return message.GroupRole_ADMIN
+	default:
+		return message.GroupRole_MEMBER
+	}
+}
+
+func fromProtoRole(role message.GroupRole) types.GroupRole {
+	switch role {
+	case message.GroupRole_OWNER:
+		return types.GroupRoleOwner
+	case message.GroupRole_ADMIN:
+		return types.GroupRoleAdmin
+	default:
+		return types.GroupRoleMember
+	}
+}
+
+// canManage tells whether the role allows adding and removing the members and renaming the group
+func canManage(role types.GroupRole) bool {
+	return role == types.GroupRoleOwner || role == types.GroupRoleAdmin
+}
+
+// canRemove tells whether the member with given role can remove the member with the other one.
+// Nobody can remove the owner, who can only leave on its own.
+func canRemove(role, subjectRole types.GroupRole) bool {
+	switch subjectRole {
+	case types.GroupRoleOwner:
+		return false
+	case types.GroupRoleAdmin:
+		return role == types.GroupRoleOwner
+	default:
+		return canManage(role)
+	}
+}
+
+// signedGroupUpdate returns the data the signature of the update is made over
+func signedGroupUpdate(groupID []byte, update *message.GroupUpdate) ([]byte, error) {
+	signature := update.Signature
+	update.Signature = nil
+	data, err := proto.Marshal(update)
+	update.Signature = signature
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, groupID...), data...), nil
+}
+
+func signGroupUpdate(groupID []byte, update *message.GroupUpdate, key ed25519.PrivateKey) error {
+	data, err := signedGroupUpdate(groupID, update)
+	if err != nil {
+		return err
+	}
+	update.Signature = ed25519.Sign(key, data)
+	return nil
+}
+
+func verifyGroupUpdate(groupID []byte, update *message.GroupUpdate, signingKey []byte) bool {
+	if len(signingKey) != ed25519.PublicKeySize {
+		return false
+	}
+	data, err := signedGroupUpdate(groupID, update)
+	return err == nil && ed25519.Verify(signingKey, data, update.Signature)
+}
+
+// signingKey returns our key the changes of the groups are signed with. It is derived from our private key,
+// so it is never stored on its own and stays the same for as long as our identity does.
+func (c *Client) signingKey() (ed25519.PrivateKey, error) {
+	seed, err := c.transport.DeriveKey(groupSigningKeyPurpose, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func (c *Client) isUs(targetPub, providerPub *sphinx.PublicKey) bool {
+	ourPub, ourProviderPub := utils.KeysFromBytes(c.PublicKey(), c.ProviderPublicKey())
+	return sameKeys(targetPub, providerPub, ourPub, ourProviderPub)
+}
+
+// getGroup finds the stored group by its base64 encoded ID
+func (c *Client) getGroup(id string) ([]byte, *types.Group, error) {
+	groupID, err := base64.URLEncoding.DecodeString(id)
+	if err != nil || len(groupID) != groupIDSize {
+		return nil, nil, ErrUnknownGroup
+	}
+	group := c.chatStore.GetGroup(groupID)
+	if group == nil {
+		return nil, nil, ErrUnknownGroup
+	}
+	return groupID, group, nil
+}
+
+// cloneGroup returns a deep copy of the group, so that a change can be made to it before it is known to be valid
+func cloneGroup(group *types.Group) *types.Group {
+	clone := *group
+	clone.Members = make([]*types.GroupMember, len(group.Members))
+	for i, member := range group.Members {
+		memberCopy := *member
+		clone.Members[i] = &memberCopy
+	}
+	clone.Banned = append([]types.Contact{}, group.Banned...)
+	return &clone
+}
+
+// withAliases fills in the current aliases and verification states of the members, as only their keys are stored
+func (c *Client) withAliases(group *types.Group) *types.Group {
+	for _, member := range group.Members {
+		memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey)
+		if memberPub == nil || memberProviderPub == nil {
+			continue
+		}
+		contactPub, contactProviderPub := c.ContactOf(memberPub.Bytes(), memberProviderPub.Bytes())
+		member.Alias = c.assignedName(contactPub, contactProviderPub)
+		member.Verified = c.IsVerified(contactPub, contactProviderPub)
+	}
+	return group
+}
+
+// findMember returns the index of the member of the group the client is an endpoint of, or -1 if it is not a member
+func (c *Client) findMember(group *types.Group, targetPub, providerPub *sphinx.PublicKey) int {
+	for i, member := range group.Members {
+		memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey)
+		if memberPub == nil || memberProviderPub == nil {
+			continue
+		}
+		if c.sameContact(targetPub, providerPub, memberPub, memberProviderPub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func toProtoMember(member *types.GroupMember) *message.GroupMember {
+	memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey)
+	if memberPub == nil || memberProviderPub == nil {
+		return nil
+	}
+	signingKey, _ := base64.URLEncoding.DecodeString(member.SigningKey)
+	return &message.GroupMember{
+		PublicKey:         memberPub.Bytes(),
+		ProviderPublicKey: memberProviderPub.Bytes(),
+		Role:              toProtoRole(member.Role),
+		SigningKey:        signingKey,
+	}
+}
+
+// protoMembers lists all members of the group, including us
+func (c *Client) protoMembers(group *types.Group, signingKey ed25519.PrivateKey) []*message.GroupMember {
+	members := []*message.GroupMember{c.protoUs(group, signingKey)}
+	for _, member := range group.Members {
+		if protoMember := toProtoMember(member); protoMember != nil {
+			members = append(members, protoMember)
+		}
+	}
+	return members
+}
+
+func (c *Client) protoUs(group *types.Group, signingKey ed25519.PrivateKey) *message.GroupMember {
+	return &message.GroupMember{
+		PublicKey:         c.PublicKey(),
+		ProviderPublicKey: c.ProviderPublicKey(),
+		Role:              toProtoRole(group.Role),
+		SigningKey:        signingKey.Public().(ed25519.PublicKey),
+	}
+}
+
+func protoBanned(group *types.Group) []*message.GroupMember {
+	banned := make([]*message.GroupMember, 0, len(group.Banned))
+	for _, contact := range group.Banned {
+		if protoMember := toProtoMember(&types.GroupMember{Contact: contact}); protoMember != nil {
+			banned = append(banned, protoMember)
+		}
+	}
+	return banned
+}
+
+func (c *Client) isBanned(group *types.Group, targetPub, providerPub *sphinx.PublicKey) bool {
+	for _, contact := range group.Banned {
+		bannedPub, bannedProviderPub := utils.KeysFromB64Strings(contact.PublicKey, contact.ProviderPublicKey)
+		if bannedPub != nil && bannedProviderPub != nil && c.sameContact(targetPub, providerPub, bannedPub, bannedProviderPub) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySnapshot fills the group we are joining with the name, the members and the banned clients carried by the
+// change adding us, signed by an admin. It returns false if we are not among the members.
+func (c *Client) applySnapshot(group *types.Group, update *message.GroupUpdate) bool {
+	isMember := false
+	members := make([]*types.GroupMember, 0, len(update.Members))
+	for _, protoMember := range update.Members {
+		memberPub, memberProviderPub := utils.KeysFromBytes(protoMember.PublicKey, protoMember.ProviderPublicKey)
+		if memberPub == nil || memberProviderPub == nil {
+			continue
+		}
+		if c.isUs(memberPub, memberProviderPub) {
+			group.Role = fromProtoRole(protoMember.Role)
+			isMember = true
+			continue
+		}
+		member := &types.GroupMember{
+			Contact: *c.toContact(memberPub, memberProviderPub, ""),
+			Role:    fromProtoRole(protoMember.Role),
+		}
+		if len(protoMember.SigningKey) == ed25519.PublicKeySize {
+			member.SigningKey = base64.URLEncoding.EncodeToString(protoMember.SigningKey)
+		}
+		members = append(members, member)
+	}
+
+	banned := make([]types.Contact, 0, len(update.Banned))
+	for _, protoMember := range update.Banned {
+		if bannedPub, bannedProviderPub := utils.KeysFromBytes(protoMember.PublicKey, protoMember.ProviderPublicKey); bannedPub != nil && bannedProviderPub != nil {
+			banned = append(banned, *c.toContact(bannedPub, bannedProviderPub, ""))
+		}
+	}
+
+	group.Name = update.Name
+	group.Members = members
+	group.Banned = banned
+	group.Version = update.Version
+	return isMember
+}
+
+// Groups returns all groups we are a member of.
+func (c *Client) Groups() []*types.Group {
+	groups := c.chatStore.GetAllGroups()
+	for _, group := range groups {
+		c.withAliases(group)
+	}
+	return groups
+}
+
+// Group returns the group with given ID.
+func (c *Client) Group(id string) (*types.Group, error) {
+	_, group, err := c.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.withAliases(group), nil
+}
+
+// CreateGroup creates new group with us as its owner and only member.
+func (c *Client) CreateGroup(name string) (*types.Group, error) {
+	if err := validateGroupName(name); err != nil {
+		return nil, err
+	}
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	groupID := make([]byte, groupIDSize)
+	if _, err := rand.Read(groupID); err != nil {
+		return nil, err
+	}
+	group := &types.Group{
+		ID:       base64.URLEncoding.EncodeToString(groupID),
+		Name:     name,
+		Role:     types.GroupRoleOwner,
+		Members:  []*types.GroupMember{},
+		JoinedAt: time.Now(),
+	}
+	c.chatStore.StoreGroup(groupID, group)
+	if _, err := c.ownSenderKey(groupID); err != nil {
+		return nil, err
+	}
+	c.log.WithField("group", name).Info("Created new group")
+	return group, nil
+}
+
+// signedSnapshot returns the update carrying the whole group, signed by us
+func (c *Client) signedSnapshot(groupID []byte, group *types.Group, op message.GroupOp, subject *message.GroupMember) (*message.GroupUpdate, error) {
+	signingKey, err := c.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	if subject == nil {
+		subject = c.protoUs(group, signingKey)
+	}
+	update := &message.GroupUpdate{
+		Op:      op,
+		Name:    group.Name,
+		Subject: subject,
+		Members: c.protoMembers(group, signingKey),
+		Version: group.Version,
+		Banned:  protoBanned(group),
+	}
+	if err := signGroupUpdate(groupID, update, signingKey); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+// commitGroupChange signs the change we have made to the previous state of the group, stores the group
+// and announces the change in the background to the recipients, which should include everybody affected by it.
+// The subject defaults to us, while without the previous state the change can not be replaced by a concurrent one.
+func (c *Client) commitGroupChange(groupID []byte, previous, group *types.Group, recipients []*types.GroupMember, op message.GroupOp, subject *message.GroupMember) error {
+	group.Version++
+	update, err := c.signedSnapshot(groupID, group, op, subject)
+	if err != nil {
+		group.Version--
+		return err
+	}
+	c.chatStore.StoreGroup(groupID, group)
+	if previous != nil {
+		c.chatStore.SetGroupCheckpoint(groupID, &types.GroupCheckpoint{Group: previous, ChangeHash: changeHash(groupID, update)})
+	} else {
+		c.chatStore.SetGroupCheckpoint(groupID, nil)
+	}
+	c.log.WithFields(logrus.Fields{"group": group.Name, "op": op}).Info("Changed the group")
+
+	go c.announceGroupUpdate(groupID, recipients, update)
+	return nil
+}
+
+// resolveMember finds the member of the group the contact is
+func (c *Client) resolveMember(group *types.Group, target types.Contact) (int, error) {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return -1, err
+	}
+	i := c.findMember(group, targetPub, providerPub)
+	if i < 0 {
+		return -1, ErrNotGroupMember
+	}
+	return i, nil
+}
+
+// AddGroupMember adds the contact to the group and announces it in the background to all of the members,
+// including the new one, which learns about everybody else that way. Only admins can add new members.
+// The signing key of the contact is part of the change, so unless the contact has already sent it to us,
+// we ask for it first and the contact is added once it arrives, in which case false is returned.
+func (c *Client) AddGroupMember(groupID string, target types.Contact) (bool, error) {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return false, err
+	}
+	if !canManage(group.Role) {
+		return false, ErrNotAllowed
+	}
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return false, err
+	}
+	if c.isUs(targetPub, providerPub) || c.findMember(group, targetPub, providerPub) >= 0 {
+		return false, ErrAlreadyMember
+	}
+	if c.isBanned(group, targetPub, providerPub) {
+		return false, ErrBannedFromGroup
+	}
+
+	signingKey := c.chatStore.GetSigningKey(targetPub, providerPub)
+	if signingKey == nil {
+		c.requestSigningKey(id, targetPub, providerPub)
+		c.log.WithField("group", group.Name).Info("Asked the new member for its signing key")
+		return false, nil
+	}
+	member := &types.GroupMember{
+		Contact:    *c.toContact(targetPub, providerPub, ""),
+		Role:       types.GroupRoleMember,
+		SigningKey: base64.URLEncoding.EncodeToString(signingKey),
+	}
+	return true, c.addMember(id, group, member)
+}
+
+// addMember adds the member to the group and announces it. It has to be called with groupMu held.
+func (c *Client) addMember(groupID []byte, group *types.Group, member *types.GroupMember) error {
+	previous := cloneGroup(group)
+	group.Members = append(group.Members, member)
+	return c.commitGroupChange(groupID, previous, group, group.Members, message.GroupOp_ADD, toProtoMember(member))
+}
+
+// KickGroupMember removes the member from the group and announces it in the background to all of the members,
+// including the removed one. Admins can remove members, while only the owner can remove admins.
+func (c *Client) KickGroupMember(groupID string, target types.Contact) error {
+	return c.removeGroupMember(groupID, target, message.GroupOp_REMOVE)
+}
+
+// BanGroupMember removes the member from the group the same way as KickGroupMember,
+// but it also makes sure the member can not be added to the group again.
+func (c *Client) BanGroupMember(groupID string, target types.Contact) error {
+	return c.removeGroupMember(groupID, target, message.GroupOp_BAN)
+}
+
+func (c *Client) removeGroupMember(groupID string, target types.Contact, op message.GroupOp) error {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return err
+	}
+	i, err := c.resolveMember(group, target)
+	if err != nil {
+		return err
+	}
+	member := group.Members[i]
+	if !canRemove(group.Role, member.Role) {
+		return ErrNotAllowed
+	}
+
+	// the removed member has to learn about it as well
+	previous := cloneGroup(group)
+	recipients := append([]*types.GroupMember{}, group.Members...)
+	group.Members = append(group.Members[:i], group.Members[i+1:]...)
+	if op == message.GroupOp_BAN {
+		group.Banned = append(group.Banned, types.Contact{
+			PublicKey:         member.PublicKey,
+			ProviderPublicKey: member.ProviderPublicKey,
+		})
+	}
+	if err := c.commitGroupChange(id, previous, group, recipients, op, toProtoMember(member)); err != nil {
+		return err
+	}
+	c.rekeyGroup(id, group, member)
+	return nil
+}
+
+// PromoteGroupMember makes the member an admin of the group, which only the owner can do.
+func (c *Client) PromoteGroupMember(groupID string, target types.Contact) error {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if group.Role != types.GroupRoleOwner {
+		return ErrNotAllowed
+	}
+	i, err := c.resolveMember(group, target)
+	if err != nil {
+		return err
+	}
+	member := group.Members[i]
+	if member.Role != types.GroupRoleMember {
+		return ErrNotAllowed
+	}
+	previous := cloneGroup(group)
+	member.Role = types.GroupRoleAdmin
+	return c.commitGroupChange(id, previous, group, group.Members, message.GroupOp_PROMOTE, toProtoMember(member))
+}
+
+// RenameGroup changes the name of the group for all of its members, which only admins can do.
+func (c *Client) RenameGroup(groupID string, name string) error {
+	if err := validateGroupName(name); err != nil {
+		return err
+	}
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if !canManage(group.Role) {
+		return ErrNotAllowed
+	}
+	previous := cloneGroup(group)
+	group.Name = name
+	return c.commitGroupChange(id, previous, group, group.Members, message.GroupOp_RENAME, nil)
+}
+
+// LeaveGroup forgets the group alongside its conversation and announces it in the background to all of the members.
+func (c *Client) LeaveGroup(groupID string) error {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return err
+	}
+	signingKey, err := c.signingKey()
+	if err != nil {
+		return err
+	}
+	// leaving is not a change signed by an admin, but it is signed by us, so that nobody can leave on our behalf
+	update := &message.GroupUpdate{
+		Op:      message.GroupOp_LEAVE,
+		Name:    group.Name,
+		Subject: c.protoUs(group, signingKey),
+		Version: group.Version,
+	}
+	if err := signGroupUpdate(id, update, signingKey); err != nil {
+		return err
+	}
+	c.chatStore.RemoveGroup(id)
+	c.chatStore.SetGroupTombstone(id, group.Version)
+	c.log.WithField("group", group.Name).Info("Left the group")
+
+	go c.announceGroupUpdate(id, group.Members, update)
+	return nil
+}
+
+func (c *Client) announceGroupUpdate(groupID []byte, members []*types.GroupMember, update *message.GroupUpdate) {
+	payload := c.createMessagePayload("")
+	payload.Kind = message.Kind_GROUP_UPDATE
+	payload.GroupId = groupID
+	payload.GroupUpdate = update
+
+	ctx, cancel := context.WithTimeout(context.Background(), groupUpdateTimeout)
+	defer cancel()
+	if failed := c.sendToMembers(ctx, members, payload); len(failed) > 0 {
+		c.log.WithField("failed", len(failed)).Warn("Could not announce the change of the group to some of its members")
+	}
+}
+
+// sendToMembers sends the payload to each of the members separately,
+// returning the members it could not be sent to
+func (c *Client) sendToMembers(ctx context.Context, members []*types.GroupMember, payload *message.ChatMessage) []*types.GroupMember {
+	var failed []*types.GroupMember
+	for _, member := range members {
+		// the member might have switched to another endpoint in the meantime
+		recipient, err := c.resolveRecipient(types.Contact{
+			PublicKey:         member.PublicKey,
+			ProviderPublicKey: member.ProviderPublicKey,
+		})
+		if err == nil {
+			err = c.send(ctx, recipient, payload)
+		}
+		if err != nil {
+			c.log.WithError(err).WithField("member", member.PublicKey).Debug("Could not send to the member of the group")
+			failed = append(failed, member)
+		}
+	}
+	return failed
+}
+
+// GroupSendError is returned by SendToGroup if the message could not be sent to some of the members of the group
+type GroupSendError struct {
+	// Failed are the members the message could not be sent to, alongside their aliases
+	Failed []*types.GroupMember
+	// Stored tells whether the message was sent to anybody at all and hence stored in the conversation of the group
+	Stored bool
+}
+
+func (e *GroupSendError) Error() string {
+	return fmt.Sprintf("%v (%d)", ErrGroupSendFailed, len(e.Failed))
+}
+
+// SendToGroup encrypts the message with our sender key, sends it to each member of the group separately
+// and stores it in the conversation of the group, unless it could not be sent to anybody.
+// If it could not be sent to some of the members, the returned error is a *GroupSendError listing them.
+// Note that the transport might still pick up the messages from its queue after the context is done.
+func (c *Client) SendToGroup(ctx context.Context, groupID string, content string) error {
+	id, group, err := c.getGroup(groupID)
+	if err != nil {
+		return err
+	}
+	c.groupMu.Lock()
+	senderKey, err := c.ownSenderKey(id)
+	c.groupMu.Unlock()
+	if err != nil {
+		return err
+	}
+	signingKey, err := c.signingKey()
+	if err != nil {
+		return err
+	}
+
+	payload := c.createMessagePayload(content)
+	payload.Kind = message.Kind_GROUP_TEXT
+	payload.GroupId = id
+	encrypted := proto.Clone(payload).(*message.ChatMessage)
+	encrypted.KeyGeneration = senderKey.Generation
+	if encrypted.Content, err = encryptGroupContent(senderKey, id, c.PublicKey(), payload.Content); err != nil {
+		return err
+	}
+	if err := signGroupMessage(encrypted, signingKey); err != nil {
+		return err
+	}
+
+	failed := c.sendToMembers(ctx, group.Members, encrypted)
+	stored := len(failed) < len(group.Members)
+	if stored {
+		c.chatStore.StoreGroupMessage(id, payload)
+	}
+	if len(failed) > 0 {
+		c.withAliases(&types.Group{Members: failed})
+		return &GroupSendError{Failed: failed, Stored: stored}
+	}
+	return nil
+}
+
+// GroupHistory returns all stored messages of the conversation of the group.
+func (c *Client) GroupHistory(groupID string) ([]*types.Message, error) {
+	id, _, err := c.getGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	storedMsgs := c.chatStore.GetGroupHistory(id)
+	msgs := make([]*types.Message, len(storedMsgs))
+	for i, msg := range storedMsgs {
+		msgs[i] = c.toMessage(msg)
+	}
+	return msgs, nil
+}
+
+// groupOf returns the group the received message belongs to
+func (c *Client) groupOf(msg *message.ChatMessage) *types.Group {
+	if group := c.chatStore.GetGroup(msg.GroupId); group != nil {
+		return c.withAliases(group)
+	}
+	return nil
+}
+
+// handleGroupMessage decrypts and stores the message sent to one of our groups by any of its members
+func (c *Client) handleGroupMessage(msg *message.ChatMessage, senderKey, senderProvKey *sphinx.PublicKey) receivedMessageAction {
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	group := c.chatStore.GetGroup(msg.GroupId)
+	if group == nil {
+		log.Debug("Ignored message sent to unknown group")
+		return ignoreMessage
+	}
+	log = log.WithField("group", group.Name)
+	i := c.findMember(group, senderKey, senderProvKey)
+	if i < 0 {
+		log.Warn("Ignored group message sent by somebody who is not its member")
+		return ignoreMessage
+	}
+
+	// the other members know the sender key as well, only the signature shows who has really sent the message
+	if signingKey, _ := base64.URLEncoding.DecodeString(group.Members[i].SigningKey); !verifyGroupMessage(msg, signingKey) {
+		log.Warn("Ignored group message not signed by the signing key of the member")
+		return ignoreMessage
+	}
+	memberPub, memberProviderPub := utils.KeysFromB64Strings(group.Members[i].PublicKey, group.Members[i].ProviderPublicKey)
+	content, err := decryptGroupContent(c.chatStore.GetSenderKey(msg.GroupId, memberPub, memberProviderPub), msg, time.Now())
+	if err == errSenderKeyMissing {
+		log.Debug("Held group message until the sender key arrives")
+		c.awaitKey(msg)
+		return ignoreMessage
+	}
+	if err == errSenderKeyExpired {
+		c.forgetPreviousKey(msg.GroupId, memberPub, memberProviderPub)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Could not decrypt group message")
+		return ignoreMessage
+	}
+	msg.Content = content
+
+	// messages from any endpoint of a contact are shown under its name
+	contactKey, contactProvKey := c.contactOf(senderKey, senderProvKey)
+	msg.SenderPublicKey, msg.SenderProviderPublicKey = contactKey.Bytes(), contactProvKey.Bytes()
+	c.chatStore.StoreGroupMessage(msg.GroupId, msg)
+	if c.chatStore.IsMuted(contactKey, contactProvKey) {
+		log.Debug("Stored group message from muted sender")
+		return ignoreMessage
+	}
+	log.Debug("Received group message")
+	return deliverGroupMessage
+}
+
+// joinGroup creates the group from the change adding us to it, as long as we are among its members,
+// we want to hear from whoever added us and the change is signed by it as an admin of the group
+func (c *Client) joinGroup(msg *message.ChatMessage, update *message.GroupUpdate, senderKey, senderProvKey *sphinx.PublicKey) *types.Group {
+	log := c.log.WithField("group", update.Name)
+	if validateGroupName(update.Name) != nil {
+		return nil
+	}
+	// otherwise anybody could drag us into their groups
+	if c.unknownSenders != ShowUnknownSenders && c.isUnknownSender(msg) {
+		log.Info("Ignored invitation to a group from unknown sender")
+		return nil
+	}
+
+	// we have no other way to learn the key of the sender than from the list of the members it has sent
+	var sender *message.GroupMember
+	for _, member := range update.Members {
+		memberPub, memberProviderPub := utils.KeysFromBytes(member.PublicKey, member.ProviderPublicKey)
+		if memberPub != nil && memberProviderPub != nil && c.sameContact(memberPub, memberProviderPub, senderKey, senderProvKey) {
+			sender = member
+		}
+	}
+	if sender == nil || !canManage(fromProtoRole(sender.Role)) || !verifyGroupUpdate(msg.GroupId, update, sender.SigningKey) {
+		log.Warn("Ignored invitation to a group not signed by any of its admins")
+		return nil
+	}
+	// we have exchanged the signing keys before being added, so both of them have to be the ones we know
+	if signingKey := c.chatStore.GetSigningKey(senderKey, senderProvKey); signingKey != nil && !bytes.Equal(signingKey, sender.SigningKey) {
+		log.Warn("Ignored invitation to a group signed by a different key than the one the sender has sent us")
+		return nil
+	}
+	ourKey, err := c.signingKey()
+	if err != nil || !bytes.Equal(update.Subject.SigningKey, ourKey.Public().(ed25519.PublicKey)) {
+		log.Warn("Ignored invitation to a group with somebody else's signing key in place of ours")
+		return nil
+	}
+
+	group := &types.Group{
+		ID:       base64.URLEncoding.EncodeToString(msg.GroupId),
+		Members:  []*types.GroupMember{},
+		JoinedAt: time.Now(),
+	}
+	if !c.applySnapshot(group, update) {
+		return nil
+	}
+	log.Info("Joined the group")
+	return group
+}
+
+// currentRole returns the role of the client within the group, or an empty string if it is not a member
+func (c *Client) currentRole(group *types.Group, targetPub, providerPub *sphinx.PublicKey) types.GroupRole {
+	if c.isUs(targetPub, providerPub) {
+		return group.Role
+	}
+	if i := c.findMember(group, targetPub, providerPub); i >= 0 {
+		return group.Members[i].Role
+	}
+	return ""
+}
+
+// applyChange returns the copy of the group with the change made by the sender applied to it, as long as it is
+// properly signed and the role of the sender allows it. Only the change of its subject is taken from the update,
+// the rest of the group it carries is merely checked against ours, so that nothing else can be sneaked in,
+// such as other signing keys, roles or banned clients.
+func (c *Client) applyChange(groupID []byte, group *types.Group, sender *types.GroupMember, update *message.GroupUpdate, subjectPub, subjectProvPub *sphinx.PublicKey) (*types.Group, error) {
+	signingKey, err := base64.URLEncoding.DecodeString(sender.SigningKey)
+	if err != nil || !verifyGroupUpdate(groupID, update, signingKey) {
+		return nil, errForgedChange
+	}
+
+	changed := cloneGroup(group)
+	subjectRole := c.currentRole(group, subjectPub, subjectProvPub)
+	subjectIndex := c.findMember(group, subjectPub, subjectProvPub)
+	switch update.Op {
+	case message.GroupOp_ADD:
+		if !canManage(sender.Role) || subjectRole != "" || c.isBanned(group, subjectPub, subjectProvPub) ||
+			update.Subject.Role != message.GroupRole_MEMBER || len(update.Subject.SigningKey) != ed25519.PublicKeySize {
+			return nil, errForgedChange
+		}
+		// the new member has sent its key to whoever added it, which has to be the one it has sent to us as well
+		if signingKey := c.chatStore.GetSigningKey(subjectPub, subjectProvPub); signingKey != nil && !bytes.Equal(signingKey, update.Subject.SigningKey) {
+			return nil, errForgedChange
+		}
+		changed.Members = append(changed.Members, &types.GroupMember{
+			Contact:    *c.toContact(subjectPub, subjectProvPub, ""),
+			Role:       types.GroupRoleMember,
+			SigningKey: base64.URLEncoding.EncodeToString(update.Subject.SigningKey),
+		})
+	case message.GroupOp_REMOVE, message.GroupOp_BAN:
+		if subjectRole == "" || !canRemove(sender.Role, subjectRole) {
+			return nil, errForgedChange
+		}
+		if subjectIndex >= 0 {
+			changed.Members = append(changed.Members[:subjectIndex], changed.Members[subjectIndex+1:]...)
+		}
+		if update.Op == message.GroupOp_BAN {
+			changed.Banned = append(changed.Banned, *c.toContact(subjectPub, subjectProvPub, ""))
+		}
+	case message.GroupOp_PROMOTE:
+		if sender.Role != types.GroupRoleOwner || subjectRole != types.GroupRoleMember {
+			return nil, errForgedChange
+		}
+		if subjectIndex >= 0 {
+			changed.Members[subjectIndex].Role = types.GroupRoleAdmin
+		} else {
+			changed.Role = types.GroupRoleAdmin
+		}
+	case message.GroupOp_RENAME:
+		if !canManage(sender.Role) || validateGroupName(update.Name) != nil {
+			return nil, errForgedChange
+		}
+		changed.Name = update.Name
+	default:
+		return nil, errForgedChange
+	}
+
+	if changed.Name != update.Name || !c.matchesGroup(changed, update) {
+		return nil, errConflictingChange
+	}
+	changed.Version = update.Version
+	return changed, nil
+}
+
+// matchesGroup tells whether the group carried by the update is the same as ours with the change applied.
+// Members only one of us knows about are tolerated, as somebody might have left in the meantime, but the owner
+// has to be there, while the roles and signing keys of everybody else and the banned clients have to be the same.
+func (c *Client) matchesGroup(group *types.Group, update *message.GroupUpdate) bool {
+	ownerListed := false
+	ownerKnown := group.Role == types.GroupRoleOwner
+	for _, member := range group.Members {
+		ownerKnown = ownerKnown || member.Role == types.GroupRoleOwner
+	}
+	for _, protoMember := range update.Members {
+		memberPub, memberProviderPub := utils.KeysFromBytes(protoMember.PublicKey, protoMember.ProviderPublicKey)
+		if memberPub == nil || memberProviderPub == nil {
+			return false
+		}
+		role := fromProtoRole(protoMember.Role)
+		var signingKey string
+		if c.isUs(memberPub, memberProviderPub) {
+			ourKey, err := c.signingKey()
+			if err != nil {
+				return false
+			}
+			if role != group.Role || (len(protoMember.SigningKey) > 0 && !bytes.Equal(protoMember.SigningKey, ourKey.Public().(ed25519.PublicKey))) {
+				return false
+			}
+		} else if i := c.findMember(group, memberPub, memberProviderPub); i >= 0 {
+			if role != group.Members[i].Role {
+				return false
+			}
+			signingKey = group.Members[i].SigningKey
+		} else if role == types.GroupRoleOwner || (role == types.GroupRoleAdmin && ownerKnown) {
+			// an unknown admin could not have been promoted without us knowing, unless the owner is gone
+			return false
+		}
+		if signingKey != "" && len(protoMember.SigningKey) > 0 && signingKey != base64.URLEncoding.EncodeToString(protoMember.SigningKey) {
+			return false
+		}
+		if role == types.GroupRoleOwner {
+			ownerListed = true
+		}
+	}
+	if ownerKnown && !ownerListed {
+		return false
+	}
+
+	if len(update.Banned) != len(group.Banned) {
+		return false
+	}
+	for _, protoMember := range update.Banned {
+		bannedPub, bannedProviderPub := utils.KeysFromBytes(protoMember.PublicKey, protoMember.ProviderPublicKey)
+		if bannedPub == nil || bannedProviderPub == nil || !c.isBanned(group, bannedPub, bannedProviderPub) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceGroup stores the group after it has changed, forgetting the sender keys of the members who are gone
+// and replacing our own, or sending ours to the new members otherwise. It has to be called with groupMu held.
+func (c *Client) replaceGroup(groupID []byte, previous, group *types.Group) {
+	var removed, added []*types.GroupMember
+	for _, member := range previous.Members {
+		if memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey); memberPub != nil && memberProviderPub != nil && c.findMember(group, memberPub, memberProviderPub) < 0 {
+			removed = append(removed, member)
+		}
+	}
+	for _, member := range group.Members {
+		if memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey); memberPub != nil && memberProviderPub != nil && c.findMember(previous, memberPub, memberProviderPub) < 0 {
+			added = append(added, member)
+		}
+	}
+
+	c.chatStore.StoreGroup(groupID, group)
+	if len(removed) > 0 {
+		c.rekeyGroup(groupID, group, removed...)
+		return
+	}
+	// the new members might have already sent us their keys before we knew them, this way they will send them again
+	if len(added) > 0 {
+		if senderKey, err := c.ownSenderKey(groupID); err == nil {
+			go c.distributeSenderKey(groupID, added, senderKey)
+		}
+	}
+}
+
+// notifyGroupChange queues the notification of the subscribers about the change of the group.
+// It has to be called with groupMu held, which has to be released by unlockGroups.
+func (c *Client) notifyGroupChange(change *types.GroupChange) {
+	c.groupChanges = append(c.groupChanges, change)
+}
+
+// unlockGroups releases groupMu and only then notifies the subscribers about the changes of the groups made meanwhile,
+// as a subscriber that is not keeping up would otherwise hold up everybody changing the groups
+func (c *Client) unlockGroups() {
+	changes := c.groupChanges
+	c.groupChanges = nil
+	c.groupMu.Unlock()
+	for _, change := range changes {
+		c.subscribers.publish(&types.Received{Kind: types.ReceivedGroupChange, GroupChange: change}, c.haltedCh)
+	}
+}
+
+// removedFromGroup forgets the group we have been removed from and lets the subscribers know about it.
+// It has to be called with groupMu held.
+func (c *Client) removedFromGroup(groupID []byte, group *types.Group, change *types.GroupChange, version uint64) {
+	c.chatStore.RemoveGroup(groupID)
+	c.chatStore.SetGroupTombstone(groupID, version)
+	ourPub, ourProviderPub := utils.KeysFromBytes(c.PublicKey(), c.ProviderPublicKey())
+	if change.Kind != types.GroupMemberBanned {
+		change.Kind = types.GroupMemberRemoved
+	}
+	change.Member = *c.toContact(ourPub, ourProviderPub, "")
+	change.Group = *c.withAliases(group)
+	c.log.WithFields(logrus.Fields{"group": group.Name, "change": change.Kind}).Info("Removed from the group")
+	c.notifyGroupChange(change)
+}
+
+func groupChangeKind(op message.GroupOp) types.GroupChangeKind {
+	switch op {
+	case message.GroupOp_ADD:
+		return types.GroupMemberAdded
+	case message.GroupOp_LEAVE:
+		return types.GroupMemberLeft
+	case message.GroupOp_REMOVE:
+		return types.GroupMemberRemoved
+	case message.GroupOp_BAN:
+		return types.GroupMemberBanned
+	case message.GroupOp_PROMOTE:
+		return types.GroupMemberPromoted
+	case message.GroupOp_SYNC:
+		return types.GroupSynced
+	default:
+		return types.GroupRenamed
+	}
+}
+
+// handleGroupUpdate applies the change of the group announced by one of its members and notifies the subscribers
+// about it. As the mixnet does not preserve the order of the messages, the changes that arrive before the ones
+// they follow are held and handled again once the group changes.
+func (c *Client) handleGroupUpdate(msg *message.ChatMessage, senderKey, senderProvKey *sphinx.PublicKey) receivedMessageAction {
+	if c.applyGroupUpdate(msg, senderKey, senderProvKey) {
+		c.retryAwaitingGroup(msg.GroupId)
+	}
+	return ignoreMessage
+}
+
+// awaitGroup keeps the update of the group until we join it or the changes preceding the update arrive.
+// Like retryAwaitingGroup, it is only ever called by the goroutine receiving the messages.
+func (c *Client) awaitGroup(msg *message.ChatMessage) {
+	if len(c.awaitingGroup) >= maxAwaitingGroup {
+		c.log.Warn("Dropped the oldest group update waiting for the preceding changes")
+		c.awaitingGroup = c.awaitingGroup[1:]
+	}
+	c.awaitingGroup = append(c.awaitingGroup, msg)
+}
+
+// retryAwaitingGroup handles again the held updates of the group that has just changed,
+// for as long as any of them can be applied
+func (c *Client) retryAwaitingGroup(groupID []byte) {
+	for changed := true; changed; {
+		changed = false
+		var ready []*message.ChatMessage
+		awaiting := c.awaitingGroup[:0]
+		for _, msg := range c.awaitingGroup {
+			if string(msg.GroupId) == string(groupID) {
+				ready = append(ready, msg)
+			} else {
+				awaiting = append(awaiting, msg)
+			}
+		}
+		c.awaitingGroup = awaiting
+
+		for _, msg := range ready {
+			msgKey, msgProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+			if c.applyGroupUpdate(msg, msgKey, msgProvKey) {
+				changed = true
+			}
+		}
+	}
+}
+
+// applyGroupUpdate handles the update of the group, returning whether the group has changed because of it
+func (c *Client) applyGroupUpdate(msg *message.ChatMessage, senderKey, senderProvKey *sphinx.PublicKey) bool {
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	update := msg.GetGroupUpdate()
+	if update == nil || update.Subject == nil || len(msg.GroupId) != groupIDSize {
+		log.Debug("Ignored malformed group update")
+		return false
+	}
+	subjectPub, subjectProvPub := utils.KeysFromBytes(update.Subject.PublicKey, update.Subject.ProviderPublicKey)
+	if subjectPub == nil || subjectProvPub == nil {
+		log.Debug("Ignored malformed group update")
+		return false
+	}
+	contactKey, contactProvKey := c.contactOf(senderKey, senderProvKey)
+	msg.SenderPublicKey, msg.SenderProviderPublicKey = contactKey.Bytes(), contactProvKey.Bytes()
+
+	change := &types.GroupChange{
+		Kind:   groupChangeKind(update.Op),
+		Member: *c.toContact(subjectPub, subjectProvPub, c.assignedName(c.ContactOf(subjectPub.Bytes(), subjectProvPub.Bytes()))),
+		By:     *c.toContact(contactKey, contactProvKey, c.assignedName(contactKey.Bytes(), contactProvKey.Bytes())),
+	}
+
+	c.groupMu.Lock()
+	defer c.unlockGroups()
+	// the signing keys are exchanged directly, before any of us is a member of the group
+	if update.Op == message.GroupOp_KEY {
+		c.handleSigningKey(msg, update, senderKey, senderProvKey)
+		return false
+	}
+	group := c.chatStore.GetGroup(msg.GroupId)
+	if group == nil {
+		// whatever happened to the group before we left it does not concern us anymore
+		if version, ok := c.chatStore.GetGroupTombstone(msg.GroupId); ok && update.Version <= version {
+			log.Debug("Ignored update of the group we are not a member of anymore")
+			return false
+		}
+		// the changes are announced concurrently, so adding somebody else might overtake adding us
+		if update.Op != message.GroupOp_ADD || !c.isUs(subjectPub, subjectProvPub) {
+			log.Debug("Held update of unknown group")
+			c.awaitGroup(msg)
+			return false
+		}
+		if group = c.joinGroup(msg, update, senderKey, senderProvKey); group == nil {
+			return false
+		}
+		c.chatStore.StoreGroup(msg.GroupId, group)
+		if senderKey, err := c.ownSenderKey(msg.GroupId); err == nil {
+			go c.distributeSenderKey(msg.GroupId, group.Members, senderKey)
+		}
+		change.Group = *c.withAliases(group)
+		c.notifyGroupChange(change)
+		return true
+	}
+
+	log = log.WithField("group", group.Name)
+	i := c.findMember(group, senderKey, senderProvKey)
+	if i < 0 {
+		// it might have been added by a change we have not got yet
+		if update.Version > group.Version {
+			log.Debug("Held group update sent by somebody who is not its member yet")
+			c.awaitGroup(msg)
+		} else {
+			log.Warn("Ignored group update sent by somebody who is not its member")
+		}
+		return false
+	}
+	sender := group.Members[i]
+
+	switch update.Op {
+	case message.GroupOp_LEAVE:
+		// members can only leave on their own
+		if !c.sameContact(subjectPub, subjectProvPub, senderKey, senderProvKey) {
+			log.Warn("Ignored group member acting on behalf of somebody else")
+			return false
+		}
+		if signingKey, _ := base64.URLEncoding.DecodeString(sender.SigningKey); !verifyGroupUpdate(msg.GroupId, update, signingKey) {
+			log.Warn("Ignored group member leaving without a valid signature")
+			return false
+		}
+		group.Members = append(group.Members[:i], group.Members[i+1:]...)
+		c.rekeyGroup(msg.GroupId, group, sender)
+
+	case message.GroupOp_SYNC_REQUEST:
+		c.handleSyncRequest(msg.GroupId, group, sender, update)
+		return false
+
+	case message.GroupOp_SYNC:
+		if update.Version < group.Version {
+			log.Debug("Ignored outdated group of the owner")
+			return false
+		}
+		synced, err := c.syncedGroup(msg.GroupId, group, sender, update)
+		if err != nil {
+			log.WithError(err).Warn("Ignored group of the owner")
+			return false
+		}
+		c.chatStore.SetGroupCheckpoint(msg.GroupId, nil)
+		if synced == nil {
+			c.removedFromGroup(msg.GroupId, group, change, update.Version)
+			return false
+		}
+		c.replaceGroup(msg.GroupId, group, synced)
+		group = synced
+
+	default:
+		// every change of the admins follows the previous one, which might have been overtaken by it
+		base := group
+		hash := changeHash(msg.GroupId, update)
+		switch {
+		case update.Version == group.Version:
+			// a concurrent change made to the same state, only the one with the lower hash is kept by everybody
+			checkpoint := c.chatStore.GetGroupCheckpoint(msg.GroupId)
+			if checkpoint == nil || checkpoint.Group.Version+1 != update.Version || bytes.Compare(hash, checkpoint.ChangeHash) >= 0 {
+				log.Debug("Ignored change of the group losing to a concurrent one")
+				return false
+			}
+			log.Info("Replacing the last change of the group with a concurrent one")
+			base = checkpoint.Group
+		case update.Version < group.Version:
+			log.Debug("Ignored outdated change of the group")
+			return false
+		case update.Version > group.Version+1:
+			log.Debug("Held change of the group until the preceding ones arrive")
+			c.awaitGroup(msg)
+			// the preceding change might have been lost on its way
+			if group.Role != types.GroupRoleOwner {
+				c.requestSync(msg.GroupId, group)
+			}
+			return false
+		}
+		j := c.findMember(base, senderKey, senderProvKey)
+		if j < 0 {
+			log.Warn("Ignored change of the group made by somebody who was not its member")
+			return false
+		}
+		changed, err := c.applyChange(msg.GroupId, base, base.Members[j], update, subjectPub, subjectProvPub)
+		if err == errConflictingChange {
+			// a concurrent change might still replace the one it conflicts with
+			log.Warn("Held change of the group made to a different state of it")
+			c.awaitGroup(msg)
+			c.resync(msg.GroupId, group, update.Version)
+			return false
+		}
+		if err != nil {
+			log.WithError(err).WithField("op", update.Op).Warn("Ignored change of the group")
+			return false
+		}
+		c.chatStore.SetGroupCheckpoint(msg.GroupId, &types.GroupCheckpoint{Group: base, ChangeHash: hash})
+		if (update.Op == message.GroupOp_REMOVE || update.Op == message.GroupOp_BAN) && c.isUs(subjectPub, subjectProvPub) {
+			c.removedFromGroup(msg.GroupId, changed, change, update.Version)
+			return false
+		}
+		if change.Kind == types.GroupRenamed {
+			change.PreviousName = group.Name
+		}
+		c.replaceGroup(msg.GroupId, group, changed)
+		group = changed
+	}
+
+	c.chatStore.StoreGroup(msg.GroupId, group)
+	change.Group = *c.withAliases(group)
+	log.WithField("change", change.Kind).Info("Group has changed")
+	c.notifyGroupChange(change)
+	return true
+}
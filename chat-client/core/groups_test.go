@@ -0,0 +1,441 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"golang.org/x/crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func memberOf(t *testing.T, c *testClient, role types.GroupRole) *types.GroupMember {
+	t.Helper()
+	signingKey, err := c.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.GroupMember{
+		Contact:    c.contact(),
+		Role:       role,
+		SigningKey: base64.URLEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey)),
+	}
+}
+
+// signedChange returns the change of the subject signed by the client, carrying the given members and banned clients
+// as the state of the group after the change
+func signedChange(t *testing.T, groupID []byte, by *testClient, op message.GroupOp, subject *types.GroupMember, members []*types.GroupMember, banned []types.Contact) *message.GroupUpdate {
+	t.Helper()
+	update := &message.GroupUpdate{
+		Op:      op,
+		Name:    "test",
+		Subject: toProtoMember(subject),
+		Version: 2,
+		Banned:  protoBanned(&types.Group{Banned: banned}),
+	}
+	for _, member := range members {
+		update.Members = append(update.Members, toProtoMember(member))
+	}
+	signingKey, err := by.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signGroupUpdate(groupID, update, signingKey); err != nil {
+		t.Fatal(err)
+	}
+	return update
+}
+
+func TestApplyChange(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 4)
+	defer cleanup()
+	us, owner, newcomer, outsider := clients[0], clients[1], clients[2], clients[3]
+
+	groupID := []byte("group")
+	ourMember := memberOf(t, us, types.GroupRoleMember)
+	ownerMember := memberOf(t, owner, types.GroupRoleOwner)
+	newMember := memberOf(t, newcomer, types.GroupRoleMember)
+	group := &types.Group{
+		Name:    "test",
+		Role:    types.GroupRoleMember,
+		Members: []*types.GroupMember{ownerMember},
+		Version: 1,
+	}
+	newcomerPub, newcomerProvPub := utils.KeysFromB64Strings(newMember.PublicKey, newMember.ProviderPublicKey)
+	after := []*types.GroupMember{ourMember, ownerMember, newMember}
+
+	demotedOwner := *ownerMember
+	demotedOwner.Role = types.GroupRoleAdmin
+	promotedUs := *ourMember
+	promotedUs.Role = types.GroupRoleAdmin
+	swappedKey := *ownerMember
+	swappedKey.SigningKey = newMember.SigningKey
+	tampered := signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, after, nil)
+	tampered.Name = "tampered"
+
+	tests := []struct {
+		name   string
+		sender *types.GroupMember
+		update *message.GroupUpdate
+		err    error
+	}{
+		{"signed by the owner", ownerMember, signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, after, nil), nil},
+		{"signed by somebody else", ownerMember, signedChange(t, groupID, outsider, message.GroupOp_ADD, newMember, after, nil), errForgedChange},
+		{"changed after signing", ownerMember, tampered, errForgedChange},
+		{"signed by a member", &types.GroupMember{Contact: ownerMember.Contact, Role: types.GroupRoleMember, SigningKey: ownerMember.SigningKey},
+			signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, after, nil), errForgedChange},
+		{"demoting the owner", ownerMember,
+			signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, []*types.GroupMember{ourMember, &demotedOwner, newMember}, nil), errConflictingChange},
+		{"promoting us", ownerMember,
+			signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, []*types.GroupMember{&promotedUs, ownerMember, newMember}, nil), errConflictingChange},
+		{"replacing the signing key", ownerMember,
+			signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, []*types.GroupMember{ourMember, &swappedKey, newMember}, nil), errConflictingChange},
+		{"banning somebody", ownerMember,
+			signedChange(t, groupID, owner, message.GroupOp_ADD, newMember, after, []types.Contact{outsider.contact()}), errConflictingChange},
+	}
+	for _, test := range tests {
+		changed, err := us.applyChange(groupID, group, test.sender, test.update, newcomerPub, newcomerProvPub)
+		if err != test.err {
+			t.Errorf("%s: got error %v instead of %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if i := us.findMember(changed, newcomerPub, newcomerProvPub); i < 0 || changed.Members[i].SigningKey != newMember.SigningKey {
+			t.Errorf("%s: the member has not been added with its signing key", test.name)
+		}
+		if changed.Version != 2 {
+			t.Errorf("%s: the version is %v instead of 2", test.name, changed.Version)
+		}
+	}
+	if len(group.Members) != 1 || group.Version != 1 {
+		t.Error("the change has been applied to the group passed in")
+	}
+}
+
+// createTestGroup creates the group owned by the first client and adds all of the others to it,
+// returning its ID once everybody has joined
+func createTestGroup(t *testing.T, clients []*testClient) string {
+	t.Helper()
+	owner := clients[0]
+	group, err := owner.CreateGroup("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range clients[1:] {
+		added, err := owner.AddGroupMember(group.ID, member.contact())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// nobody has exchanged the signing keys yet
+		if added {
+			t.Fatal("the member has been added before its signing key arrived")
+		}
+		change := nextReceived(t, member, types.ReceivedGroupChange).GroupChange
+		if change.Kind != types.GroupMemberAdded || change.Group.ID != group.ID {
+			t.Fatalf("unexpected change of the group: %+v", change)
+		}
+	}
+	eventually(t, "adding all of the members", func() bool {
+		for _, c := range clients {
+			group, err := c.Group(group.ID)
+			if err != nil || len(group.Members) != len(clients)-1 {
+				return false
+			}
+		}
+		return true
+	})
+	return group.ID
+}
+
+func TestGroupMessages(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob, carol := clients[0], clients[1], clients[2]
+	groupID := createTestGroup(t, clients)
+
+	// the sender keys might still be on their way, in which case the message waits for them
+	if err := bob.SendToGroup(context.Background(), groupID, "hello everybody"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*testClient{alice, carol} {
+		received := nextReceived(t, c, types.ReceivedGroupMessage)
+		if received.Message.Content != "hello everybody" || received.Group.ID != groupID {
+			t.Errorf("received %q in group %v", received.Message.Content, received.Group.ID)
+		}
+	}
+}
+
+func TestGroupChangesArrivingOutOfOrder(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{
+		Jitter:       20 * time.Millisecond,
+		ReorderRate:  0.5,
+		ReorderDelay: 100 * time.Millisecond,
+		Seed:         2,
+	}, 3)
+	defer cleanup()
+	alice := clients[0]
+	groupID := createTestGroup(t, clients)
+
+	for _, name := range []string{"first", "second", "third"} {
+		if err := alice.RenameGroup(groupID, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	owned, _ := alice.Group(groupID)
+	eventually(t, "applying all of the changes", func() bool {
+		for _, c := range clients[1:] {
+			group, err := c.Group(groupID)
+			if err != nil || group.Name != "third" || group.Version != owned.Version {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestConcurrentGroupChangesConverge(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{Delay: 50 * time.Millisecond}, 3)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+	groupID := createTestGroup(t, clients)
+
+	if err := alice.PromoteGroupMember(groupID, bob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "promoting the member", func() bool {
+		group, err := bob.Group(groupID)
+		return err == nil && group.Role == types.GroupRoleAdmin
+	})
+
+	// both changes are made to the same version of the group
+	if err := alice.RenameGroup(groupID, "by alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.RenameGroup(groupID, "by bob"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "agreeing on one of the changes", func() bool {
+		var name string
+		for _, c := range clients {
+			group, err := c.Group(groupID)
+			if err != nil || (name != "" && group.Name != name) {
+				return false
+			}
+			name = group.Name
+		}
+		return name == "by alice" || name == "by bob"
+	})
+}
+
+func TestRemovedMemberIgnoresOldChanges(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+	groupID := createTestGroup(t, clients)
+
+	if err := alice.KickGroupMember(groupID, bob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "removing the member", func() bool {
+		_, err := bob.Group(groupID)
+		return err == ErrUnknownGroup
+	})
+	id, _ := base64.URLEncoding.DecodeString(groupID)
+	if _, ok := bob.store.GetGroupTombstone(id); !ok {
+		t.Error("the group we have been removed from is not remembered")
+	}
+}
+
+func TestStalledSubscriberDoesNotBlockGroups(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	// a subscriber that never reads anything, once its buffer is full
+	bob.Subscribe()
+	contents := make([]string, subscriberBufferSize)
+	for i := range contents {
+		contents[i] = fmt.Sprint(i)
+	}
+	sendAll(t, alice, bob, contents)
+	for range contents {
+		nextReceived(t, bob, types.ReceivedMessage)
+	}
+
+	group, err := alice.CreateGroup("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.AddGroupMember(group.ID, bob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	// the change is stored before the subscribers are told about it
+	eventually(t, "joining the group", func() bool {
+		_, err := bob.Group(group.ID)
+		return err == nil
+	})
+	left := make(chan error, 1)
+	go func() { left <- bob.LeaveGroup(group.ID) }()
+	select {
+	case err := <-left:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the groups are held up by the subscriber that is not reading")
+	}
+}
+
+func TestSigningKeyBoundToSender(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob, mallory := clients[0], clients[1], clients[2]
+	groupID := make([]byte, groupIDSize)
+
+	// mallory's own signing key, which mallory claims to be alice's in the second case
+	signingKey, err := mallory.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		sender   *testClient
+		accepted bool
+	}{
+		{"own key", mallory, true},
+		{"key of somebody else", alice, false},
+	} {
+		msg := mallory.createMessagePayload("")
+		msg.Kind = message.Kind_GROUP_UPDATE
+		msg.GroupId = groupID
+		msg.SenderPublicKey, msg.SenderProviderPublicKey = test.sender.PublicKey(), test.sender.ProviderPublicKey()
+		update := &message.GroupUpdate{
+			Op: message.GroupOp_KEY,
+			Subject: &message.GroupMember{
+				PublicKey:         msg.SenderPublicKey,
+				ProviderPublicKey: msg.SenderProviderPublicKey,
+				SigningKey:        signingKey.Public().(ed25519.PublicKey),
+			},
+		}
+		if update.Proof, err = mallory.signingKeyProof(groupID, bob.PublicKey(), update.Subject); err != nil {
+			t.Fatal(err)
+		}
+		if err := signGroupUpdate(groupID, update, signingKey); err != nil {
+			t.Fatal(err)
+		}
+		msg.GroupUpdate = update
+		bob.processReceivedMessage(msg)
+
+		senderPub, senderProvPub := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+		if stored := bob.store.GetSigningKey(senderPub, senderProvPub); (stored != nil) != test.accepted {
+			t.Errorf("%s: the stored signing key is %v", test.name, stored)
+		}
+	}
+}
+
+// groupText returns the group message sent as the client, encrypted with the sender key and signed by the signer
+func groupText(t *testing.T, groupID []byte, as *testClient, senderKey *types.SenderKey, signer *testClient, content string) *message.ChatMessage {
+	t.Helper()
+	msg := as.createMessagePayload("")
+	msg.Kind = message.Kind_GROUP_TEXT
+	msg.GroupId = groupID
+	msg.KeyGeneration = senderKey.Generation
+	var err error
+	if msg.Content, err = encryptGroupContent(senderKey, groupID, as.PublicKey(), []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := signer.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signGroupMessage(msg, signingKey); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestForgedGroupMessage(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob, carol := clients[0], clients[1], clients[2]
+	groupID := createTestGroup(t, clients)
+	id, _ := base64.URLEncoding.DecodeString(groupID)
+	bobPub, bobProvPub := utils.KeysFromBytes(bob.PublicKey(), bob.ProviderPublicKey())
+	eventually(t, "learning the sender key", func() bool {
+		return alice.store.GetSenderKey(id, bobPub, bobProvPub) != nil && carol.store.GetSenderKey(id, bobPub, bobProvPub) != nil
+	})
+
+	// carol knows bob's sender key, but not his signing key
+	bobKey := carol.store.GetSenderKey(id, bobPub, bobProvPub)
+	if action := alice.processReceivedMessage(groupText(t, id, bob, bobKey, carol, "forged")); action != ignoreMessage {
+		t.Errorf("the message forged by another member has been accepted: %v", action)
+	}
+	if action := alice.processReceivedMessage(groupText(t, id, bob, bobKey, bob, "genuine")); action != deliverGroupMessage {
+		t.Errorf("the message signed by the sender has not been accepted: %v", action)
+	}
+}
+
+func TestKickedMemberCanNotReadOrWrite(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob, carol := clients[0], clients[1], clients[2]
+	groupID := createTestGroup(t, clients)
+	id, _ := base64.URLEncoding.DecodeString(groupID)
+	carolPub, carolProvPub := utils.KeysFromBytes(carol.PublicKey(), carol.ProviderPublicKey())
+	eventually(t, "learning the sender key", func() bool {
+		return bob.store.GetSenderKey(id, carolPub, carolProvPub) != nil && alice.store.GetSenderKey(id, carolPub, carolProvPub) != nil
+	})
+	oldKey := bob.store.GetSenderKey(id, carolPub, carolProvPub)
+
+	if err := alice.KickGroupMember(groupID, bob.contact()); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "replacing the sender key", func() bool {
+		senderKey := alice.store.GetSenderKey(id, carolPub, carolProvPub)
+		return senderKey != nil && senderKey.Generation == oldKey.Generation+1
+	})
+
+	// bob is not sent anything anymore and could not decrypt it anyway
+	if err := carol.SendToGroup(context.Background(), groupID, "without bob"); err != nil {
+		t.Fatal(err)
+	}
+	if received := nextReceived(t, alice, types.ReceivedGroupMessage); received.Message.Content != "without bob" {
+		t.Errorf("received %q", received.Message.Content)
+	}
+	timeout := time.After(testQuietPeriod)
+	for quiet := false; !quiet; {
+		select {
+		case received := <-bob.received:
+			if received.Kind == types.ReceivedGroupMessage {
+				t.Fatalf("the removed member has received %q", received.Message.Content)
+			}
+		case <-timeout:
+			quiet = true
+		}
+	}
+
+	// bob still knows carol's previous key, but can not sign with her signing key
+	if action := alice.processReceivedMessage(groupText(t, id, carol, oldKey, bob, "forged")); action != ignoreMessage {
+		t.Errorf("the message forged by the removed member has been accepted: %v", action)
+	}
+	// the messages carol sent before the removal might still be on their way
+	if action := alice.processReceivedMessage(groupText(t, id, carol, oldKey, carol, "in flight")); action != deliverGroupMessage {
+		t.Errorf("the message encrypted with the previous key has not been accepted: %v", action)
+	}
+	// but only for a while, as bob knows the key
+	senderKey := alice.store.GetSenderKey(id, carolPub, carolProvPub)
+	senderKey.PreviousKeyExpiresAt = time.Now().Add(-time.Second)
+	alice.store.SetSenderKey(id, carolPub, carolProvPub, senderKey)
+	if action := alice.processReceivedMessage(groupText(t, id, carol, oldKey, carol, "late")); action != ignoreMessage {
+		t.Errorf("the message encrypted with the expired previous key has been accepted: %v", action)
+	}
+	if senderKey := alice.store.GetSenderKey(id, carolPub, carolProvPub); senderKey.PreviousKey != nil {
+		t.Error("the expired previous key has been kept")
+	}
+}
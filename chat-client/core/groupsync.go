@@ -0,0 +1,134 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/golang/protobuf/proto"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// changeHash identifies the signed change of the group. Of two concurrent changes of the same version,
+// everybody keeps the one with the lower hash.
+func changeHash(groupID []byte, update *message.GroupUpdate) []byte {
+	data, err := proto.Marshal(update)
+	if err != nil {
+		return nil
+	}
+	hash := sha256.Sum256(append(append([]byte{}, groupID...), data...))
+	return hash[:]
+}
+
+// resync makes everybody's group the same as the one of the owner after the change of the given version
+// did not match ours. The owner announces its group to everybody, with a version newer than anything seen so far,
+// while the others ask the owner for it. It has to be called with groupMu held.
+func (c *Client) resync(groupID []byte, group *types.Group, seenVersion uint64) {
+	if group.Role != types.GroupRoleOwner {
+		c.requestSync(groupID, group)
+		return
+	}
+	if seenVersion > group.Version {
+		group.Version = seenVersion
+	}
+	if err := c.commitGroupChange(groupID, nil, group, group.Members, message.GroupOp_SYNC, nil); err != nil {
+		c.log.WithError(err).Error("Could not announce our group after conflicting changes")
+	}
+}
+
+// requestSync asks the owner for its group, unless we have already asked since our group last changed.
+// It has to be called with groupMu held.
+func (c *Client) requestSync(groupID []byte, group *types.Group) {
+	if version, ok := c.syncRequested[string(groupID)]; ok && version == group.Version {
+		return
+	}
+	var owner *types.GroupMember
+	for _, member := range group.Members {
+		if member.Role == types.GroupRoleOwner {
+			owner = member
+		}
+	}
+	// once the owner has left, the concurrent changes are only settled by their hashes
+	if owner == nil {
+		return
+	}
+	signingKey, err := c.signingKey()
+	if err != nil {
+		c.log.WithError(err).Error("Could not derive the signing key")
+		return
+	}
+	update := &message.GroupUpdate{
+		Op:      message.GroupOp_SYNC_REQUEST,
+		Name:    group.Name,
+		Subject: c.protoUs(group, signingKey),
+		Version: group.Version,
+	}
+	if err := signGroupUpdate(groupID, update, signingKey); err != nil {
+		c.log.WithError(err).Error("Could not sign the request for the group of the owner")
+		return
+	}
+	c.syncRequested[string(groupID)] = group.Version
+	c.log.WithField("group", group.Name).Info("Asked the owner for its group")
+	go c.announceGroupUpdate(groupID, []*types.GroupMember{owner}, update)
+}
+
+// handleSyncRequest sends our group to the member asking for it, as long as we are the owner.
+// It has to be called with groupMu held.
+func (c *Client) handleSyncRequest(groupID []byte, group *types.Group, sender *types.GroupMember, update *message.GroupUpdate) {
+	log := c.log.WithFields(logrus.Fields{"group": group.Name, "member": sender.PublicKey})
+	signingKey, err := base64.URLEncoding.DecodeString(sender.SigningKey)
+	if err != nil || group.Role != types.GroupRoleOwner || !verifyGroupUpdate(groupID, update, signingKey) {
+		log.Debug("Ignored request for the group of the owner")
+		return
+	}
+	synced, err := c.signedSnapshot(groupID, group, message.GroupOp_SYNC, nil)
+	if err != nil {
+		log.WithError(err).Error("Could not sign our group")
+		return
+	}
+	log.Info("Sending our group to the member")
+	go c.announceGroupUpdate(groupID, []*types.GroupMember{sender}, synced)
+}
+
+// syncedGroup returns the group the owner has sent us to replace ours, or nil if we are not its member anymore.
+// The owner is only trusted with the signing keys of the members we do not know yet.
+func (c *Client) syncedGroup(groupID []byte, group *types.Group, sender *types.GroupMember, update *message.GroupUpdate) (*types.Group, error) {
+	signingKey, err := base64.URLEncoding.DecodeString(sender.SigningKey)
+	if err != nil || sender.Role != types.GroupRoleOwner || !verifyGroupUpdate(groupID, update, signingKey) ||
+		validateGroupName(update.Name) != nil {
+		return nil, errForgedChange
+	}
+
+	synced := &types.Group{
+		ID:       group.ID,
+		Members:  []*types.GroupMember{},
+		JoinedAt: group.JoinedAt,
+	}
+	if !c.applySnapshot(synced, update) {
+		return nil, nil
+	}
+	if synced.Role == types.GroupRoleOwner {
+		return nil, errForgedChange
+	}
+	ownerListed := false
+	senderPub, senderProviderPub := utils.KeysFromB64Strings(sender.PublicKey, sender.ProviderPublicKey)
+	for _, member := range synced.Members {
+		memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey)
+		if member.Role == types.GroupRoleOwner {
+			if !c.sameContact(memberPub, memberProviderPub, senderPub, senderProviderPub) {
+				return nil, errForgedChange
+			}
+			ownerListed = true
+		}
+		if i := c.findMember(group, memberPub, memberProviderPub); i >= 0 && group.Members[i].SigningKey != "" {
+			member.SigningKey = group.Members[i].SigningKey
+		} else if known := c.chatStore.GetSigningKey(memberPub, memberProviderPub); known != nil {
+			member.SigningKey = base64.URLEncoding.EncodeToString(known)
+		}
+	}
+	if !ownerListed {
+		return nil, errForgedChange
+	}
+	return synced, nil
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/storage"
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	// how long we wait for anything to arrive before failing the test
+	testTimeout = 10 * time.Second
+	// how long we wait to make sure nothing arrives
+	testQuietPeriod = 300 * time.Millisecond
+)
+
+type testClient struct {
+	*Client
+	store    *storage.DbStore
+	received <-chan *types.Received
+}
+
+func (tc *testClient) contact() types.Contact {
+	return types.Contact{
+		PublicKey:         base64.URLEncoding.EncodeToString(tc.PublicKey()),
+		ProviderPublicKey: base64.URLEncoding.EncodeToString(tc.ProviderPublicKey()),
+	}
+}
+
+// startTestClients starts the chat clients on top of the loopback network with given behaviour.
+// The returned function shuts them down and removes their stores, which are left open
+// as the background sends might still be using them, just like when the process exits.
+func startTestClients(t *testing.T, cfg loopback.Config, count int) (*loopback.Network, []*testClient, func()) {
+	t.Helper()
+	return startTestClientsWithPolicy(t, cfg, count, ShowUnknownSenders)
+}
+
+// startTestClientsWithPolicy starts the chat clients just like startTestClients, treating the unknown senders
+// according to the policy
+func startTestClientsWithPolicy(t *testing.T, cfg loopback.Config, count int, unknownSenders UnknownSendersPolicy) (*loopback.Network, []*testClient, func()) {
+	t.Helper()
+	network, err := loopback.NewNetwork(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "chat-client-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := make([]*testClient, count)
+	cleanup := func() {
+		for _, c := range clients {
+			if c != nil {
+				c.Shutdown()
+			}
+		}
+		os.RemoveAll(dir)
+	}
+	for i := range clients {
+		transport, err := network.NewClient()
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		store, err := storage.NewDbStore(fmt.Sprintf("client%d", i), dir)
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		c := NewWithTransport(transport, store, dir, unknownSenders)
+		received, _ := c.Subscribe()
+		clients[i] = &testClient{Client: c, store: store, received: received}
+	}
+	for _, c := range clients {
+		if err := c.Start(); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return network, clients, cleanup
+}
+
+// nextReceived returns the next received item of given kind, skipping everything else
+func nextReceived(t *testing.T, c *testClient, kind types.ReceivedKind) *types.Received {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case received, ok := <-c.received:
+			if !ok {
+				t.Fatal("the subscription has been closed")
+			}
+			if received.Kind == kind {
+				return received
+			}
+		case <-timeout:
+			t.Fatalf("nothing of kind %v has been received in %v", kind, testTimeout)
+		}
+	}
+}
+
+// eventually fails the test unless the condition is met in time
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has not happened in %v", description, testTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func sendAll(t *testing.T, from, to *testClient, contents []string) {
+	t.Helper()
+	for _, content := range contents {
+		if err := from.Send(context.Background(), to.contact(), content); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSendAndReceive(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	sendAll(t, alice, bob, []string{"hello"})
+	received := nextReceived(t, bob, types.ReceivedMessage)
+	if received.Message.Content != "hello" {
+		t.Errorf("received %q instead of %q", received.Message.Content, "hello")
+	}
+	if received.Message.SenderPublicKey != alice.contact().PublicKey {
+		t.Errorf("received message from %v instead of %v", received.Message.SenderPublicKey, alice.contact().PublicKey)
+	}
+	if history, err := alice.History(bob.contact()); err != nil || len(history) != 1 || !history[0].Outgoing {
+		t.Errorf("the sent message is not in the history: %v, %v", history, err)
+	}
+}
+
+func TestDelayedMessages(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	_, clients, cleanup := startTestClients(t, loopback.Config{Delay: delay, Jitter: 50 * time.Millisecond}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	sentAt := time.Now()
+	sendAll(t, alice, bob, []string{"hello"})
+	nextReceived(t, bob, types.ReceivedMessage)
+	if elapsed := time.Since(sentAt); elapsed < delay {
+		t.Errorf("the message has arrived after %v, before the delay of %v", elapsed, delay)
+	}
+}
+
+func TestLostMessages(t *testing.T) {
+	network, clients, cleanup := startTestClients(t, loopback.Config{LossRate: 1}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	// the loss is silent, just like in the mixnet
+	sendAll(t, alice, bob, []string{"one", "two", "three"})
+	network.Wait()
+	timeout := time.After(testQuietPeriod)
+	for {
+		select {
+		case received := <-bob.received:
+			if received.Kind == types.ReceivedMessage {
+				t.Fatalf("lost message %q has been received", received.Message.Content)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestReorderedMessages(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{
+		ReorderRate:  0.5,
+		ReorderDelay: 100 * time.Millisecond,
+		Seed:         1,
+	}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	sent := make([]string, 10)
+	for i := range sent {
+		sent[i] = fmt.Sprint(i)
+	}
+	sendAll(t, alice, bob, sent)
+
+	remaining := make(map[string]bool)
+	for _, content := range sent {
+		remaining[content] = true
+	}
+	inOrder := true
+	for i := range sent {
+		content := nextReceived(t, bob, types.ReceivedMessage).Message.Content
+		if !remaining[content] {
+			t.Fatalf("unexpected message %q", content)
+		}
+		delete(remaining, content)
+		inOrder = inOrder && content == sent[i]
+	}
+	if inOrder {
+		t.Error("the messages have not been reordered")
+	}
+}
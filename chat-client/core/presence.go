@@ -0,0 +1,175 @@
+package core
+
+import (
+	"encoding/base64"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/config"
+	"sync"
+	"time"
+)
+
+// presenceEntry is what we know about the presence of a single client since the client was started
+type presenceEntry struct {
+	publicKey         []byte
+	providerPublicKey []byte
+	online            bool
+	since             time.Time
+	lastSeen          time.Time
+}
+
+// presenceTracker follows which clients are present in the network view as it gets refreshed
+type presenceTracker struct {
+	sync.Mutex
+	// changes are only reported once we have seen the first view, otherwise everybody
+	// would be announced as having just come online whenever we start
+	initialised bool
+	clients     map[string]*presenceEntry
+}
+
+func newPresenceTracker() *presenceTracker {
+	return &presenceTracker{
+		clients: make(map[string]*presenceEntry),
+	}
+}
+
+// update marks the clients present in the view as online and everybody else as offline.
+// It returns the entries that have changed their state.
+func (p *presenceTracker) update(clients []config.ClientConfig, now time.Time) []presenceEntry {
+	p.Lock()
+	defer p.Unlock()
+	changed := make([]presenceEntry, 0)
+	present := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		key := string(client.PubKey) + string(client.Provider.PubKey)
+		present[key] = true
+		entry, ok := p.clients[key]
+		if !ok {
+			entry = &presenceEntry{
+				publicKey:         client.PubKey,
+				providerPublicKey: client.Provider.PubKey,
+			}
+			p.clients[key] = entry
+		}
+		entry.lastSeen = now
+		if !entry.online {
+			entry.online = true
+			entry.since = now
+			if p.initialised {
+				changed = append(changed, *entry)
+			}
+		}
+	}
+
+	for key, entry := range p.clients {
+		if entry.online && !present[key] {
+			entry.online = false
+			entry.since = time.Time{}
+			changed = append(changed, *entry)
+		}
+	}
+	p.initialised = true
+	return changed
+}
+
+func (p *presenceTracker) get(publicKey, providerPublicKey []byte) (presenceEntry, bool) {
+	p.Lock()
+	defer p.Unlock()
+	entry, ok := p.clients[string(publicKey)+string(providerPublicKey)]
+	if !ok {
+		return presenceEntry{}, false
+	}
+	return *entry, true
+}
+
+// SetPresenceRefreshInterval sets how often the network view is refreshed in the background
+// to find out which contacts are online. Non-positive interval, the default, disables the refreshing.
+// It must be called before Start.
+func (c *Client) SetPresenceRefreshInterval(interval time.Duration) {
+	c.presenceInterval = interval
+}
+
+// updatePresence has to be called whenever the network view was refreshed.
+// Our contacts coming online or going offline are published to the subscribers.
+func (c *Client) updatePresence() {
+	// make sure the changes are published in the same order as they were noticed
+	c.presenceUpdateMu.Lock()
+	defer c.presenceUpdateMu.Unlock()
+
+	now := time.Now()
+	clients := c.transport.Clients()
+	changed := c.presence.update(clients, now)
+
+	// there is no point in remembering everybody present in the directory, only our contacts
+	for _, client := range clients {
+		if storedAlias := c.LookupAlias(client.PubKey, client.Provider.PubKey); storedAlias != nil && storedAlias.AssignedName != "" {
+			c.chatStore.SetLastSeen(storedAlias.PublicKey, storedAlias.ProviderPublicKey, now)
+		}
+	}
+
+	for _, entry := range changed {
+		storedAlias := c.LookupAlias(entry.publicKey, entry.providerPublicKey)
+		if storedAlias == nil || storedAlias.AssignedName == "" {
+			continue
+		}
+		c.log.WithField("contact", storedAlias.AssignedName).WithField("online", entry.online).Info("Presence of contact changed")
+		c.subscribers.publish(&types.Received{
+			Kind:     types.ReceivedPresence,
+			Presence: c.Presence(entry.publicKey, entry.providerPublicKey),
+		}, c.haltedCh)
+	}
+}
+
+// refreshPresenceInBackground periodically fetches the network view, so that we would notice contacts coming and going
+func (c *Client) refreshPresenceInBackground() {
+	ticker := time.NewTicker(c.presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.haltedCh:
+			return
+		case <-ticker.C:
+			if err := c.UpdateNetworkView(); err != nil {
+				// keep the last known state rather than treating everybody as offline
+				c.log.WithError(err).Warn("Failed to refresh the network view")
+			}
+		}
+	}
+}
+
+// Presence tells whether the client with given keys is present in the current network view
+// and when it was last seen. Last seen times of our contacts are remembered between runs.
+func (c *Client) Presence(publicKey, providerPublicKey []byte) *types.Presence {
+	presence := &types.Presence{
+		Contact: types.Contact{
+			PublicKey:         base64.URLEncoding.EncodeToString(publicKey),
+			ProviderPublicKey: base64.URLEncoding.EncodeToString(providerPublicKey),
+		},
+	}
+	if storedAlias := c.LookupAlias(publicKey, providerPublicKey); storedAlias != nil {
+		presence.Alias = storedAlias.AssignedName
+	}
+
+	if entry, ok := c.presence.get(publicKey, providerPublicKey); ok {
+		presence.Online = entry.online
+		presence.Since = entry.since
+		presence.LastSeen = entry.lastSeen
+	}
+	if presence.LastSeen.IsZero() {
+		targetPub, providerPub := utils.KeysFromBytes(publicKey, providerPublicKey)
+		if targetPub != nil && providerPub != nil {
+			presence.LastSeen = c.chatStore.GetLastSeen(targetPub, providerPub)
+		}
+	}
+	return presence
+}
+
+// ContactsPresence returns the presence of all clients with assigned aliases.
+func (c *Client) ContactsPresence() []*types.Presence {
+	aliases := c.chatStore.GetAllAliases()
+	presence := make([]*types.Presence, len(aliases))
+	for i, storedAlias := range aliases {
+		presence[i] = c.Presence(storedAlias.PublicKey.Bytes(), storedAlias.ProviderPublicKey.Bytes())
+	}
+	return presence
+}
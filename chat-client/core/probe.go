@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/config"
+	"sync"
+	"time"
+)
+
+const (
+	// how long we try to send the answer to a probe before giving up
+	probeAnswerTimeout = 30 * time.Second
+)
+
+// probeWaiter is notified once the answer to the particular probe arrives
+type probeWaiter struct {
+	publicKey         []byte
+	providerPublicKey []byte
+	answered          chan time.Time
+}
+
+// probes keeps track of all probes waiting for their answers, by their nonces
+type probes struct {
+	sync.Mutex
+	waiting map[int64]*probeWaiter
+}
+
+func newProbes() *probes {
+	return &probes{
+		waiting: make(map[int64]*probeWaiter),
+	}
+}
+
+func (p *probes) add(nonce int64, recipient config.ClientConfig) *probeWaiter {
+	p.Lock()
+	defer p.Unlock()
+	waiter := &probeWaiter{
+		publicKey:         recipient.PubKey,
+		providerPublicKey: recipient.Provider.PubKey,
+		answered:          make(chan time.Time, 1),
+	}
+	p.waiting[nonce] = waiter
+	return waiter
+}
+
+func (p *probes) remove(nonce int64) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.waiting, nonce)
+}
+
+// answer notifies the waiter of the probe, as long as the answer came from the client that was actually probed
+func (p *probes) answer(pong *message.ChatMessage, receivedAt time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+	waiter, ok := p.waiting[pong.ReplyTo]
+	if !ok ||
+		!bytes.Equal(waiter.publicKey, pong.SenderPublicKey) ||
+		!bytes.Equal(waiter.providerPublicKey, pong.SenderProviderPublicKey) {
+		return false
+	}
+	delete(p.waiting, pong.ReplyTo)
+	waiter.answered <- receivedAt
+	return true
+}
+
+// PingTo sends a probe to the recipient, whose client answers it automatically, and returns the round-trip time.
+// It waits for the answer until the context is done.
+func (c *Client) PingTo(ctx context.Context, recipient config.ClientConfig) (time.Duration, error) {
+	payload := c.createMessagePayload("")
+	payload.Kind = message.Kind_PING
+
+	waiter := c.probes.add(payload.MessageNonce, recipient)
+	defer c.probes.remove(payload.MessageNonce)
+
+	sentAt := time.Now()
+	if err := c.send(ctx, recipient, payload); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-c.haltedCh:
+		return 0, context.Canceled
+	case answeredAt := <-waiter.answered:
+		return answeredAt.Sub(sentAt), nil
+	}
+}
+
+// Ping sends a probe to the specified contact. At least its provider has to be present in the current network view.
+func (c *Client) Ping(ctx context.Context, target types.Contact) (time.Duration, error) {
+	recipient, err := c.resolveRecipient(target)
+	if err != nil {
+		return 0, err
+	}
+	return c.PingTo(ctx, recipient)
+}
+
+// answerPing sends the PONG back to the sender of the probe
+func (c *Client) answerPing(ping *message.ChatMessage) {
+	senderKey, senderProvKey := utils.KeysFromBytes(ping.SenderPublicKey, ping.SenderProviderPublicKey)
+	recipient, err := c.recipientFromDirectory(senderKey, senderProvKey)
+	if err != nil {
+		c.log.WithError(err).Warn("Could not answer the probe")
+		return
+	}
+
+	pong := c.createMessagePayload("")
+	pong.Kind = message.Kind_PONG
+	pong.ReplyTo = ping.MessageNonce
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeAnswerTimeout)
+	defer cancel()
+	// any errors are already logged
+	_ = c.send(ctx, recipient, pong)
+}
+
+// handleProbe answers the PING or notifies whoever is waiting for the PONG.
+// Probes are never shown to the user nor stored.
+func (c *Client) handleProbe(msg *message.ChatMessage) {
+	switch msg.Kind {
+	case message.Kind_PING:
+		// do not reveal we are online to anybody we would not even show the message to
+		if c.unknownSenders != ShowUnknownSenders && c.isUnknownSender(msg) {
+			return
+		}
+		go c.answerPing(msg)
+	case message.Kind_PONG:
+		c.probes.answer(msg, time.Now())
+	}
+}
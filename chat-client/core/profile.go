@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/invite"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxProfileNameLength is the maximum length of the name in the profile, in bytes, same as in the invites
+	MaxProfileNameLength = invite.MaxNameLength
+	// MaxProfileStatusLength is the maximum length of the status in the profile, in bytes
+	MaxProfileStatusLength = 140
+
+	// how long we try to send our profile to a single contact before giving up
+	profileSendTimeout = 30 * time.Second
+)
+
+var (
+	ErrProfileNameTooLong   = errors.New("the name is too long")
+	ErrProfileStatusTooLong = errors.New("the status is too long")
+	ErrInvalidProfile       = errors.New("the profile is not valid UTF-8")
+	ErrNoProfile            = errors.New("the contact has not sent us its profile")
+	ErrNoSuggestedName      = errors.New("the contact has not suggested any name")
+	ErrNameInUse            = errors.New("another contact already has that alias")
+)
+
+func validateProfile(name, status string) error {
+	switch {
+	case len(name) > MaxProfileNameLength:
+		return ErrProfileNameTooLong
+	case len(status) > MaxProfileStatusLength:
+		return ErrProfileStatusTooLong
+	case !utf8.ValidString(name) || !utf8.ValidString(status):
+		return ErrInvalidProfile
+	}
+	return nil
+}
+
+// Profile returns our own profile, which is empty until it is set.
+func (c *Client) Profile() *types.Profile {
+	return c.chatStore.GetOwnProfile()
+}
+
+// SetProfile replaces our own profile and sends it to all of our contacts in the background.
+// Contacts that are not reachable at the moment get it along with the next message sent to them.
+func (c *Client) SetProfile(name, status string) error {
+	if err := validateProfile(name, status); err != nil {
+		return err
+	}
+	c.chatStore.SetOwnProfile(&types.Profile{
+		Name:      name,
+		Status:    status,
+		UpdatedAt: time.Now(),
+		Version:   c.chatStore.GetOwnProfile().Version + 1,
+	})
+	c.log.Info("Updated our profile")
+	go c.broadcastProfile()
+	return nil
+}
+
+// broadcastProfile sends our profile to every contact that has not got its current version yet
+func (c *Client) broadcastProfile() {
+	for _, storedAlias := range c.chatStore.GetAllAliases() {
+		if storedAlias.AssignedName == "" || storedAlias.PublicKey == nil || storedAlias.ProviderPublicKey == nil {
+			continue
+		}
+		if c.chatStore.IsBlocked(storedAlias.PublicKey, storedAlias.ProviderPublicKey) {
+			continue
+		}
+		select {
+		case <-c.haltedCh:
+			return
+		default:
+		}
+		recipient, err := c.recipientFromDirectory(storedAlias.PublicKey, storedAlias.ProviderPublicKey)
+		if err != nil {
+			c.log.WithField("recipient", storedAlias.AssignedName).Debug("Postponed sending our profile to unreachable contact")
+			continue
+		}
+		c.shareProfile(recipient)
+	}
+}
+
+// shareProfile sends our profile to the recipient unless it has already got its current version
+// or it is not one of our contacts, i.e. we have not given it an alias
+func (c *Client) shareProfile(recipient config.ClientConfig) {
+	recipientKey, recipientProvKey := utils.KeysFromBytes(recipient.PubKey, recipient.Provider.PubKey)
+	if recipientKey == nil || recipientProvKey == nil {
+		return
+	}
+	contactKey, contactProvKey := c.contactOf(recipientKey, recipientProvKey)
+	if storedAlias := c.chatStore.GetAlias(contactKey, contactProvKey); storedAlias == nil || storedAlias.AssignedName == "" {
+		return
+	}
+	profile := c.chatStore.GetOwnProfile()
+	if profile.UpdatedAt.IsZero() || !c.chatStore.GetProfileSent(recipientKey, recipientProvKey).Before(profile.UpdatedAt) {
+		return
+	}
+
+	payload := c.createMessagePayload("")
+	payload.Kind = message.Kind_PROFILE
+	payload.Profile = &message.Profile{
+		Name:    profile.Name,
+		Status:  profile.Status,
+		Version: profile.Version,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), profileSendTimeout)
+	defer cancel()
+	// failures are already logged, we will try again with the next message
+	if err := c.send(ctx, recipient, payload); err == nil {
+		c.chatStore.SetProfileSent(recipientKey, recipientProvKey, profile.UpdatedAt)
+	}
+}
+
+// handleProfile stores the profile received from the sender under its contact
+// and decides whether the user should be told about it
+func (c *Client) handleProfile(msg *message.ChatMessage, senderKey, senderProvKey *sphinx.PublicKey) receivedMessageAction {
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	received := msg.GetProfile()
+	if received == nil || validateProfile(received.Name, received.Status) != nil {
+		log.Debug("Ignored malformed profile")
+		return ignoreMessage
+	}
+
+	contactKey, contactProvKey := c.contactOf(senderKey, senderProvKey)
+	msg.SenderPublicKey, msg.SenderProviderPublicKey = contactKey.Bytes(), contactProvKey.Bytes()
+	unknown := c.isUnknownSender(msg)
+	if unknown && c.unknownSenders == DropUnknownSenders {
+		log.Debug("Dropped profile of unknown sender")
+		return ignoreMessage
+	}
+
+	previous := c.chatStore.GetProfile(contactKey, contactProvKey)
+	// the mixnet does not preserve the order of the messages
+	if previous != nil && received.Version <= previous.Version {
+		log.Debug("Ignored outdated profile")
+		return ignoreMessage
+	}
+	c.chatStore.SetProfile(contactKey, contactProvKey, &types.Profile{
+		Name:      received.Name,
+		Status:    received.Status,
+		UpdatedAt: time.Now(),
+		Version:   received.Version,
+	})
+	log.Debug("Received profile")
+
+	// the name is just a suggestion, but somebody else claiming the name of a pinned contact is worth a warning,
+	// as long as the sender is not one of those the user does not want to hear from
+	if name := alias.SuggestedName(received.Name); name != "" && (!unknown || c.unknownSenders == ShowUnknownSenders) {
+		c.CheckClaimedName(name, senderKey.Bytes(), senderProvKey.Bytes(), types.KeySourceProfile)
+	}
+
+	if (unknown && c.unknownSenders != ShowUnknownSenders) || c.chatStore.IsMuted(contactKey, contactProvKey) {
+		return ignoreMessage
+	}
+	if previous != nil && previous.Name == received.Name && previous.Status == received.Status {
+		return ignoreMessage
+	}
+	return notifyProfileUpdate
+}
+
+func (c *Client) toContactProfile(targetPub, providerPub *sphinx.PublicKey, profile *types.Profile) *types.ContactProfile {
+	return &types.ContactProfile{
+		Contact: *c.toContact(targetPub, providerPub, c.assignedName(targetPub.Bytes(), providerPub.Bytes())),
+		Profile: *profile,
+	}
+}
+
+// receivedProfile is the profile just stored by handleProfile, for which the keys of the message point at the contact
+func (c *Client) receivedProfile(msg *message.ChatMessage) *types.ContactProfile {
+	contactKey, contactProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+	return c.toContactProfile(contactKey, contactProvKey, c.chatStore.GetProfile(contactKey, contactProvKey))
+}
+
+// ContactProfile returns the latest profile received from the specified contact.
+func (c *Client) ContactProfile(target types.Contact) (*types.ContactProfile, error) {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return nil, err
+	}
+	profile := c.chatStore.GetProfile(targetPub, providerPub)
+	if profile == nil {
+		return nil, ErrNoProfile
+	}
+	return c.toContactProfile(targetPub, providerPub, profile), nil
+}
+
+// AcceptSuggestedName assigns the name suggested in the profile of the contact as its alias,
+// as long as no other contact uses it already, and returns it.
+func (c *Client) AcceptSuggestedName(target types.Contact) (string, error) {
+	targetPub, providerPub, err := c.resolveContact(target)
+	if err != nil {
+		return "", err
+	}
+	profile := c.chatStore.GetProfile(targetPub, providerPub)
+	if profile == nil {
+		return "", ErrNoProfile
+	}
+	name := alias.SuggestedName(profile.Name)
+	if name == "" {
+		return "", ErrNoSuggestedName
+	}
+	if invite.IsInvite(name) {
+		return "", ErrInvalidAliasName
+	}
+	for _, storedAlias := range c.chatStore.GetAllAliasesByName(name) {
+		if !sameKeys(storedAlias.PublicKey, storedAlias.ProviderPublicKey, targetPub, providerPub) {
+			return "", ErrNameInUse
+		}
+	}
+	return name, c.AddAlias(*c.toContact(targetPub, providerPub, name))
+}
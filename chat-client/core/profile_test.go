@@ -0,0 +1,65 @@
+package core
+
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"testing"
+	"time"
+)
+
+func TestProfileSharedWithContacts(t *testing.T) {
+	network, clients, cleanup := startTestClients(t, loopback.Config{}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+	if err := alice.SetProfile("alice", "here"); err != nil {
+		t.Fatal(err)
+	}
+
+	sendAll(t, alice, bob, []string{"hello stranger"})
+	nextReceived(t, bob, types.ReceivedMessage)
+	network.Wait()
+	time.Sleep(testQuietPeriod)
+	if profile, err := bob.ContactProfile(alice.contact()); err != ErrNoProfile {
+		t.Fatalf("the profile has been sent to somebody without an alias: %+v, %v", profile, err)
+	}
+
+	addAlias(t, alice, bob, "bob")
+	sendAll(t, alice, bob, []string{"hello bob"})
+	received := nextReceived(t, bob, types.ReceivedProfile)
+	if received.Profile.Name != "alice" || received.Profile.Status != "here" || received.Profile.Version != 1 {
+		t.Errorf("unexpected profile %+v", received.Profile.Profile)
+	}
+}
+
+func TestOutdatedProfile(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 2)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	receive := func(status string, version uint64, sentAt time.Time) {
+		msg := alice.createMessagePayload("")
+		msg.Kind = message.Kind_PROFILE
+		msg.SenderTimestamp = sentAt.UnixNano()
+		msg.Profile = &message.Profile{Name: "alice", Status: status, Version: version}
+		bob.processReceivedMessage(msg)
+	}
+	status := func() string {
+		profile, err := bob.ContactProfile(alice.contact())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return profile.Status
+	}
+
+	// the timestamp is up to the sender, only the version tells the profiles apart
+	receive("first", 1, time.Now().Add(24*time.Hour))
+	receive("second", 2, time.Now())
+	if got := status(); got != "second" {
+		t.Errorf("the status is %q instead of %q", got, "second")
+	}
+	receive("outdated", 1, time.Now().Add(time.Hour))
+	if got := status(); got != "second" {
+		t.Errorf("the outdated profile has replaced the current one, the status is %q", got)
+	}
+}
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/golang/protobuf/proto"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"sync"
+	"time"
+)
+
+const (
+	subscriberBufferSize = 100
+)
+
+// consumer is a single subscriber of the received messages
+type consumer struct {
+	ch       chan *types.Received
+	done     chan struct{}
+	doneOnce sync.Once
+	// protects ch from being closed while something is being delivered to it
+	mu     sync.Mutex
+	closed bool
+}
+
+// deliver blocks until the consumer accepts the message, unsubscribes or the client is shut down,
+// hence applying backpressure to the whole receive pipeline
+func (c *consumer) deliver(received *types.Received, haltedCh <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.ch <- received:
+	case <-c.done:
+	case <-haltedCh:
+	}
+}
+
+func (c *consumer) close() {
+	// unblock any pending delivery first, so that we could acquire the lock
+	c.doneOnce.Do(func() { close(c.done) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.ch)
+	}
+}
+
+// subscribers holds all consumers interested in the received messages
+type subscribers struct {
+	sync.Mutex
+	nextID    int
+	consumers map[int]*consumer
+}
+
+func newSubscribers() *subscribers {
+	return &subscribers{
+		consumers: make(map[int]*consumer),
+	}
+}
+
+func (s *subscribers) add() (int, <-chan *types.Received) {
+	s.Lock()
+	defer s.Unlock()
+	c := &consumer{
+		ch:   make(chan *types.Received, subscriberBufferSize),
+		done: make(chan struct{}),
+	}
+	id := s.nextID
+	s.nextID++
+	s.consumers[id] = c
+	return id, c.ch
+}
+
+func (s *subscribers) remove(id int) {
+	s.Lock()
+	c, ok := s.consumers[id]
+	delete(s.consumers, id)
+	s.Unlock()
+	if ok {
+		c.close()
+	}
+}
+
+func (s *subscribers) removeAll() {
+	s.Lock()
+	consumers := s.consumers
+	s.consumers = make(map[int]*consumer)
+	s.Unlock()
+	for _, c := range consumers {
+		c.close()
+	}
+}
+
+func (s *subscribers) publish(received *types.Received, haltedCh <-chan struct{}) {
+	// do not hold the lock while delivering, as it might block for a while
+	s.Lock()
+	consumers := make([]*consumer, 0, len(s.consumers))
+	for _, c := range s.consumers {
+		consumers = append(consumers, c)
+	}
+	s.Unlock()
+	for _, c := range consumers {
+		c.deliver(received, haltedCh)
+	}
+}
+
+func (c *Client) toMessage(msg *message.ChatMessage) *types.Message {
+	group := ""
+	if len(msg.GroupId) > 0 {
+		group = base64.URLEncoding.EncodeToString(msg.GroupId)
+	}
+	return &types.Message{
+		Sender:                  c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey),
+		SenderPublicKey:         base64.URLEncoding.EncodeToString(msg.SenderPublicKey),
+		SenderProviderPublicKey: base64.URLEncoding.EncodeToString(msg.SenderProviderPublicKey),
+		Content:                 string(msg.Content),
+		SentAt:                  time.Unix(0, msg.SenderTimestamp),
+		Outgoing:                bytes.Equal(msg.SenderPublicKey, c.PublicKey()),
+		Group:                   group,
+	}
+}
+
+func (c *Client) isBlockedSender(msg *message.ChatMessage) bool {
+	senderKey, senderProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+	if senderKey == nil || senderProvKey == nil {
+		return false
+	}
+	if c.chatStore.IsBlocked(senderKey, senderProvKey) {
+		return true
+	}
+	// blocking a contact blocks all of its endpoints
+	contactKey, contactProvKey := c.contactOf(senderKey, senderProvKey)
+	return c.chatStore.IsBlocked(contactKey, contactProvKey)
+}
+
+func (c *Client) parseReceivedMessage(msg []byte) *message.ChatMessage {
+	if msg == nil {
+		return nil
+	}
+	parsedMsg := &message.ChatMessage{}
+	if err := proto.Unmarshal(msg, parsedMsg); err != nil {
+		c.log.WithError(err).Warn("Received malformed message")
+		return nil
+	}
+	if c.isBlockedSender(parsedMsg) {
+		c.log.WithField("sender", c.DisplayName(parsedMsg.SenderPublicKey, parsedMsg.SenderProviderPublicKey)).
+			Debug("Dropped message from blocked sender")
+		return nil
+	}
+	return parsedMsg
+}
+
+// isUnknownSender checks whether we have not assigned any alias to the sender.
+// The active contact is never treated as unknown as we have explicitly chosen to talk to them.
+func (c *Client) isUnknownSender(msg *message.ChatMessage) bool {
+	return c.isUnknownClient(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+}
+
+func (c *Client) isUnknownClient(publicKey, providerPublicKey []byte) bool {
+	c.activeContactMu.Lock()
+	isActive := bytes.Equal(publicKey, c.activeContactKey) && bytes.Equal(providerPublicKey, c.activeContactProviderKey)
+	c.activeContactMu.Unlock()
+	if isActive {
+		return false
+	}
+	storedAlias := c.LookupAlias(publicKey, providerPublicKey)
+	return storedAlias == nil || storedAlias.AssignedName == ""
+}
+
+type receivedMessageAction int
+
+const (
+	// deliverMessage means the message should be shown to the user
+	deliverMessage receivedMessageAction = iota
+	// notifyNewRequest means the message was held and it is the first one from that particular sender
+	notifyNewRequest
+	// ignoreMessage means the message was either held, stored in the background or dropped
+	ignoreMessage
+	// notifyProfileUpdate means the message carried a changed profile of one of the contacts
+	notifyProfileUpdate
+	// deliverGroupMessage means the message was sent to one of our groups and should be shown to the user
+	deliverGroupMessage
+)
+
+// processReceivedMessage stores the message wherever it belongs and decides what should be done with it further.
+func (c *Client) processReceivedMessage(msg *message.ChatMessage) receivedMessageAction {
+	senderKey, senderProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+	if senderKey == nil || senderProvKey == nil {
+		c.log.Warn("Received message with malformed sender keys")
+		return ignoreMessage
+	}
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	// messages from any endpoint of a contact belong to its conversation
+	contactKey, contactProvKey := c.contactOf(senderKey, senderProvKey)
+	accepted := c.unknownSenders == ShowUnknownSenders || !c.isUnknownClient(contactKey.Bytes(), contactProvKey.Bytes())
+
+	// anybody can send us anything, so only the senders we accept are worth checking and keeping the statistics of
+	if accepted {
+		// the message itself is handled as usual, it is up to the user to decide whether it is still the same person
+		c.checkSender(senderKey, senderProvKey)
+		c.stats.recordReceived(msg.SenderPublicKey, msg.SenderProviderPublicKey, proto.Size(msg), time.Unix(0, msg.SenderTimestamp))
+	}
+
+	switch msg.Kind {
+	case message.Kind_TEXT:
+	case message.Kind_PING, message.Kind_PONG:
+		log.WithField("kind", msg.Kind).Debug("Received probe")
+		c.handleProbe(msg)
+		return ignoreMessage
+	case message.Kind_PROFILE:
+		return c.handleProfile(msg, senderKey, senderProvKey)
+	case message.Kind_GROUP_TEXT:
+		return c.handleGroupMessage(msg, senderKey, senderProvKey)
+	case message.Kind_GROUP_UPDATE:
+		return c.handleGroupUpdate(msg, senderKey, senderProvKey)
+	case message.Kind_GROUP_KEY:
+		return c.handleSenderKey(msg, senderKey, senderProvKey)
+	default:
+		// sent by some newer client, there is nothing sensible we could do with it
+		log.WithField("kind", msg.Kind).Debug("Ignored message of unknown kind")
+		return ignoreMessage
+	}
+
+	senderKey, senderProvKey = contactKey, contactProvKey
+	msg.SenderPublicKey, msg.SenderProviderPublicKey = senderKey.Bytes(), senderProvKey.Bytes()
+
+	// muted messages are kept in the history, but we do not bother the user with them
+	if c.chatStore.IsMuted(senderKey, senderProvKey) {
+		log.Debug("Stored message from muted sender")
+		c.chatStore.StoreMessage(senderKey, senderProvKey, msg)
+		return ignoreMessage
+	}
+
+	if !accepted {
+		if c.unknownSenders == DropUnknownSenders {
+			log.Debug("Dropped message from unknown sender")
+			return ignoreMessage
+		}
+		// only notify about the first message, otherwise we would just spam the user
+		isFirst := !c.chatStore.HasRequest(senderKey, senderProvKey)
+		c.chatStore.StoreRequestMessage(msg)
+		log.WithField("first", isFirst).Info("Held message from unknown sender")
+		if isFirst {
+			return notifyNewRequest
+		}
+		return ignoreMessage
+	}
+
+	log.Debug("Received message")
+	c.chatStore.StoreMessage(senderKey, senderProvKey, msg)
+	return deliverMessage
+}
+
+// receiveInBackground is the receive pipeline of the client. Each message pushed by the transport
+// is processed and then passed to all of the subscribers. If any of them is not keeping up,
+// the pipeline waits for it, leaving any further messages buffered by the transport.
+func (c *Client) receiveInBackground() {
+	for {
+		select {
+		case <-c.haltedCh:
+			return
+		case rawMsg := <-c.transport.Received():
+			msg := c.parseReceivedMessage(rawMsg)
+			if msg == nil {
+				continue
+			}
+			switch c.processReceivedMessage(msg) {
+			case deliverMessage:
+				c.subscribers.publish(&types.Received{Kind: types.ReceivedMessage, Message: c.toMessage(msg)}, c.haltedCh)
+			case notifyNewRequest:
+				c.subscribers.publish(&types.Received{Kind: types.ReceivedContactRequest, Message: c.toMessage(msg)}, c.haltedCh)
+			case deliverGroupMessage:
+				c.subscribers.publish(&types.Received{Kind: types.ReceivedGroupMessage, Message: c.toMessage(msg), Group: c.groupOf(msg)}, c.haltedCh)
+			case notifyProfileUpdate:
+				c.subscribers.publish(&types.Received{Kind: types.ReceivedProfile, Profile: c.receivedProfile(msg)}, c.haltedCh)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel on which everything received by the client that requires user's attention
+// will be pushed alongside the function that has to be called to stop the subscription.
+// The channel is closed once the subscription is stopped or the client is shut down.
+func (c *Client) Subscribe() (<-chan *types.Received, func()) {
+	id, ch := c.subscribers.add()
+	return ch, func() { c.subscribers.remove(id) }
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+)
+
+var (
+	ErrNoSuchRequest = errors.New("there is no pending request from given client")
+)
+
+// Requests returns the messages held from unknown senders, grouped by their senders.
+func (c *Client) Requests() []*types.ContactRequest {
+	storedRequests := c.chatStore.GetAllRequests()
+	contactRequests := make([]*types.ContactRequest, len(storedRequests))
+	for i, req := range storedRequests {
+		msgs := make([]*types.Message, len(req.Messages))
+		for j, msg := range req.Messages {
+			msgs[j] = c.toMessage(msg)
+		}
+		contactRequests[i] = &types.ContactRequest{
+			Contact:  *c.toContact(req.PublicKey, req.ProviderPublicKey, ""),
+			Messages: msgs,
+		}
+	}
+	return contactRequests
+}
+
+// AcceptRequest assigns the alias to the sender of the request, moving its held messages to their conversation.
+func (c *Client) AcceptRequest(target types.Contact) error {
+	targetPub, providerPub := utils.KeysFromB64Strings(target.PublicKey, target.ProviderPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
+	}
+	if !c.chatStore.HasRequest(targetPub, providerPub) {
+		return ErrNoSuchRequest
+	}
+	if err := c.AddAlias(target); err != nil {
+		return err
+	}
+	c.chatStore.ArchiveRequest(targetPub, providerPub)
+	c.chatStore.RemoveRequest(targetPub, providerPub)
+	return nil
+}
+
+// IgnoreRequest discards the request alongside all of its held messages.
+func (c *Client) IgnoreRequest(target types.Contact) error {
+	targetPub, providerPub := utils.KeysFromB64Strings(target.PublicKey, target.ProviderPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
+	}
+	if !c.chatStore.HasRequest(targetPub, providerPub) {
+		return ErrNoSuchRequest
+	}
+	c.chatStore.RemoveRequest(targetPub, providerPub)
+	return nil
+}
@@ -0,0 +1,319 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/ed25519"
+	"time"
+)
+
+const (
+	// how many group messages waiting for the sender keys of their senders we keep at most
+	maxAwaitingKey = 100
+	// how long the replaced sender key is accepted for, which is only meant for the messages that were on their way
+	// at the time, as the key is still known to the member whose removal it was replaced for
+	previousSenderKeyLifetime = 2 * time.Minute
+)
+
+var (
+	errSenderKeyMissing = errors.New("the sender key the message is encrypted with has not arrived yet")
+	errSenderKeyExpired = errors.New("the sender key the message is encrypted with is not accepted anymore")
+	errMalformedContent = errors.New("the encrypted content is too short")
+)
+
+func newSenderKey(generation uint32, previousKey []byte) (*types.SenderKey, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	senderKey := &types.SenderKey{
+		Key:        key,
+		Generation: generation,
+	}
+	if previousKey != nil {
+		senderKey.PreviousKey, senderKey.PreviousKeyExpiresAt = previousKey, time.Now().Add(previousSenderKeyLifetime)
+	}
+	return senderKey, nil
+}
+
+// senderKeyData binds the encrypted content to the group, its sender and the generation of the key,
+// so that it can not be passed off as a message of anybody else
+func senderKeyData(groupID, senderPublicKey []byte, generation uint32) []byte {
+	data := make([]byte, 4, 4+len(groupID)+len(senderPublicKey))
+	binary.BigEndian.PutUint32(data, generation)
+	return append(append(data, groupID...), senderPublicKey...)
+}
+
+// signedSenderKey returns the data the signature of the sender key is made over,
+// binding it to the group and its sender, so that it can not be passed on by anybody else
+func signedSenderKey(groupID, senderPublicKey []byte, senderKey *message.SenderKey) ([]byte, error) {
+	signature := senderKey.Signature
+	senderKey.Signature = nil
+	data, err := proto.Marshal(senderKey)
+	senderKey.Signature = signature
+	if err != nil {
+		return nil, err
+	}
+	return append(append(append([]byte{}, groupID...), senderPublicKey...), data...), nil
+}
+
+func verifySenderKey(groupID, senderPublicKey []byte, senderKey *message.SenderKey, signingKey []byte) bool {
+	if len(signingKey) != ed25519.PublicKeySize {
+		return false
+	}
+	data, err := signedSenderKey(groupID, senderPublicKey, senderKey)
+	return err == nil && ed25519.Verify(signingKey, data, senderKey.Signature)
+}
+
+// signedGroupMessage returns the data the signature of the encrypted group message is made over,
+// i.e. the whole message apart from the signature itself
+func signedGroupMessage(msg *message.ChatMessage) ([]byte, error) {
+	signature := msg.Signature
+	msg.Signature = nil
+	data, err := proto.Marshal(msg)
+	msg.Signature = signature
+	return data, err
+}
+
+// signGroupMessage signs the encrypted group message with our signing key, as anybody holding our sender key
+// could otherwise encrypt whatever they like in our name
+func signGroupMessage(msg *message.ChatMessage, key ed25519.PrivateKey) error {
+	data, err := signedGroupMessage(msg)
+	if err != nil {
+		return err
+	}
+	msg.Signature = ed25519.Sign(key, data)
+	return nil
+}
+
+func verifyGroupMessage(msg *message.ChatMessage, signingKey []byte) bool {
+	if len(signingKey) != ed25519.PublicKeySize {
+		return false
+	}
+	data, err := signedGroupMessage(msg)
+	return err == nil && ed25519.Verify(signingKey, data, msg.Signature)
+}
+
+// encryptGroupContent encrypts the content with our sender key, prepending the random nonce to it
+func encryptGroupContent(senderKey *types.SenderKey, groupID, senderPublicKey, content []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(senderKey.Key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(content)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, content, senderKeyData(groupID, senderPublicKey, senderKey.Generation)), nil
+}
+
+// decryptGroupContent decrypts the content of the message sent to the group with the stored sender key of its sender,
+// accepting the previous key only until it expires
+func decryptGroupContent(senderKey *types.SenderKey, msg *message.ChatMessage, now time.Time) ([]byte, error) {
+	var key []byte
+	switch {
+	case senderKey == nil || msg.KeyGeneration > senderKey.Generation:
+		return nil, errSenderKeyMissing
+	case msg.KeyGeneration == senderKey.Generation:
+		key = senderKey.Key
+	case msg.KeyGeneration+1 == senderKey.Generation && senderKey.PreviousKey != nil && now.Before(senderKey.PreviousKeyExpiresAt):
+		key = senderKey.PreviousKey
+	default:
+		return nil, errSenderKeyExpired
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Content) < aead.NonceSize() {
+		return nil, errMalformedContent
+	}
+	nonce, ciphertext := msg.Content[:aead.NonceSize()], msg.Content[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, senderKeyData(msg.GroupId, msg.SenderPublicKey, msg.KeyGeneration))
+}
+
+// ownSenderKey returns our current sender key for the group, generating the first one if there is none yet.
+// It has to be called with groupMu held.
+func (c *Client) ownSenderKey(groupID []byte) (*types.SenderKey, error) {
+	ourPub, ourProviderPub := utils.KeysFromBytes(c.PublicKey(), c.ProviderPublicKey())
+	if senderKey := c.chatStore.GetSenderKey(groupID, ourPub, ourProviderPub); senderKey != nil {
+		return senderKey, nil
+	}
+	senderKey, err := newSenderKey(1, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.chatStore.SetSenderKey(groupID, ourPub, ourProviderPub, senderKey)
+	return senderKey, nil
+}
+
+// rekeyGroup forgets the sender keys of the members removed from the group and replaces our own with a new generation,
+// sending it to the remaining members, so that the removed ones can not read anything we send from now on.
+// It has to be called with groupMu held.
+func (c *Client) rekeyGroup(groupID []byte, group *types.Group, removed ...*types.GroupMember) {
+	for _, member := range removed {
+		if removedPub, removedProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey); removedPub != nil && removedProviderPub != nil {
+			c.chatStore.RemoveSenderKey(groupID, removedPub, removedProviderPub)
+		}
+	}
+
+	ourPub, ourProviderPub := utils.KeysFromBytes(c.PublicKey(), c.ProviderPublicKey())
+	var generation uint32 = 1
+	var previousKey []byte
+	if current := c.chatStore.GetSenderKey(groupID, ourPub, ourProviderPub); current != nil {
+		generation, previousKey = current.Generation+1, current.Key
+	}
+	senderKey, err := newSenderKey(generation, previousKey)
+	if err != nil {
+		c.log.WithError(err).Error("Could not generate new sender key")
+		return
+	}
+	c.chatStore.SetSenderKey(groupID, ourPub, ourProviderPub, senderKey)
+	c.log.WithField("group", group.Name).Info("Replaced our sender key after a member was removed from the group")
+	go c.distributeSenderKey(groupID, group.Members, senderKey)
+}
+
+// distributeSenderKey sends our sender key to each of the members separately, the same way as the direct messages.
+// It is signed with our signing key, so that nobody else can make the members decrypt our messages with another key.
+func (c *Client) distributeSenderKey(groupID []byte, members []*types.GroupMember, senderKey *types.SenderKey) {
+	signingKey, err := c.signingKey()
+	if err != nil {
+		c.log.WithError(err).Error("Could not derive the signing key")
+		return
+	}
+	payload := c.createMessagePayload("")
+	payload.Kind = message.Kind_GROUP_KEY
+	payload.GroupId = groupID
+	payload.SenderKey = &message.SenderKey{
+		Key:        senderKey.Key,
+		Generation: senderKey.Generation,
+	}
+	data, err := signedSenderKey(groupID, c.PublicKey(), payload.SenderKey)
+	if err != nil {
+		c.log.WithError(err).Error("Could not sign our sender key")
+		return
+	}
+	payload.SenderKey.Signature = ed25519.Sign(signingKey, data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), groupUpdateTimeout)
+	defer cancel()
+	if failed := c.sendToMembers(ctx, members, payload); len(failed) > 0 {
+		c.log.WithField("failed", len(failed)).Warn("Could not send our sender key to some of the members of the group")
+	}
+}
+
+// handleSenderKey stores the sender key of the member of the group signed by the member's signing key,
+// replying with our own if it is the first one we have got from the member, as it has probably just joined
+func (c *Client) handleSenderKey(msg *message.ChatMessage, senderKey, senderProvKey *sphinx.PublicKey) receivedMessageAction {
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	received := msg.GetSenderKey()
+	if received == nil || len(received.Key) != chacha20poly1305.KeySize || received.Generation == 0 {
+		log.Debug("Ignored malformed sender key")
+		return ignoreMessage
+	}
+
+	c.groupMu.Lock()
+	group := c.chatStore.GetGroup(msg.GroupId)
+	if group == nil {
+		c.groupMu.Unlock()
+		log.Debug("Ignored sender key of unknown group")
+		return ignoreMessage
+	}
+	log = log.WithField("group", group.Name)
+	i := c.findMember(group, senderKey, senderProvKey)
+	if i < 0 {
+		c.groupMu.Unlock()
+		// it might have been added by somebody else without us knowing yet, in which case it will get our key once we do
+		log.Debug("Ignored sender key sent by somebody who is not a member of the group")
+		return ignoreMessage
+	}
+	// the keys are stored under the endpoint the member was added with
+	member := group.Members[i]
+	memberSigningKey, _ := base64.URLEncoding.DecodeString(member.SigningKey)
+	if !verifySenderKey(msg.GroupId, senderKey.Bytes(), received, memberSigningKey) {
+		c.groupMu.Unlock()
+		log.Warn("Ignored sender key not signed by the signing key of the member")
+		return ignoreMessage
+	}
+	memberPub, memberProviderPub := utils.KeysFromB64Strings(member.PublicKey, member.ProviderPublicKey)
+	stored := c.chatStore.GetSenderKey(msg.GroupId, memberPub, memberProviderPub)
+	if stored != nil && received.Generation <= stored.Generation {
+		c.groupMu.Unlock()
+		log.Debug("Ignored outdated sender key")
+		return ignoreMessage
+	}
+	updated := &types.SenderKey{
+		Key:        received.Key,
+		Generation: received.Generation,
+	}
+	if stored != nil {
+		updated.PreviousKey, updated.PreviousKeyExpiresAt = stored.Key, time.Now().Add(previousSenderKeyLifetime)
+	}
+	c.chatStore.SetSenderKey(msg.GroupId, memberPub, memberProviderPub, updated)
+	log.WithField("generation", received.Generation).Debug("Received sender key")
+
+	if stored == nil {
+		if ownKey, err := c.ownSenderKey(msg.GroupId); err == nil {
+			go c.distributeSenderKey(msg.GroupId, []*types.GroupMember{member}, ownKey)
+		}
+	}
+	c.groupMu.Unlock()
+
+	c.deliverAwaiting(msg.GroupId, senderKey, senderProvKey)
+	return ignoreMessage
+}
+
+// forgetPreviousKey removes the previous sender key of the member once it has expired
+func (c *Client) forgetPreviousKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey) {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	// the key might have been replaced or removed in the meantime
+	senderKey := c.chatStore.GetSenderKey(groupID, memberPub, memberProviderPub)
+	if senderKey == nil || senderKey.PreviousKey == nil || time.Now().Before(senderKey.PreviousKeyExpiresAt) {
+		return
+	}
+	senderKey.PreviousKey, senderKey.PreviousKeyExpiresAt = nil, time.Time{}
+	c.chatStore.SetSenderKey(groupID, memberPub, memberProviderPub, senderKey)
+}
+
+// awaitKey keeps the group message until the sender key it is encrypted with arrives,
+// as the mixnet does not preserve the order of the messages.
+// Like deliverAwaiting, it is only ever called by the goroutine receiving the messages.
+func (c *Client) awaitKey(msg *message.ChatMessage) {
+	if len(c.awaitingKey) >= maxAwaitingKey {
+		c.log.Warn("Dropped the oldest group message waiting for the sender key")
+		c.awaitingKey = c.awaitingKey[1:]
+	}
+	c.awaitingKey = append(c.awaitingKey, msg)
+}
+
+// deliverAwaiting handles again the messages sent to the group by the member whose sender key has just arrived
+func (c *Client) deliverAwaiting(groupID []byte, senderKey, senderProvKey *sphinx.PublicKey) {
+	var ready []*message.ChatMessage
+	awaiting := c.awaitingKey[:0]
+	for _, msg := range c.awaitingKey {
+		msgKey, msgProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+		if string(msg.GroupId) == string(groupID) && c.sameContact(msgKey, msgProvKey, senderKey, senderProvKey) {
+			ready = append(ready, msg)
+		} else {
+			awaiting = append(awaiting, msg)
+		}
+	}
+	c.awaitingKey = awaiting
+
+	for _, msg := range ready {
+		msgKey, msgProvKey := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+		if c.handleGroupMessage(msg, msgKey, msgProvKey) == deliverGroupMessage {
+			c.subscribers.publish(&types.Received{Kind: types.ReceivedGroupMessage, Message: c.toMessage(msg), Group: c.groupOf(msg)}, c.haltedCh)
+		}
+	}
+}
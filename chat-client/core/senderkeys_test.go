@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"golang.org/x/crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func encryptedMessage(t *testing.T, key []byte, generation uint32, groupID, senderPub []byte, content string) *message.ChatMessage {
+	t.Helper()
+	encrypted, err := encryptGroupContent(&types.SenderKey{Key: key, Generation: generation}, groupID, senderPub, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &message.ChatMessage{
+		Content:         encrypted,
+		GroupId:         groupID,
+		SenderPublicKey: senderPub,
+		KeyGeneration:   generation,
+	}
+}
+
+func TestDecryptGroupContent(t *testing.T) {
+	groupID, senderPub := []byte("group"), []byte("sender")
+	first, err := newSenderKey(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newSenderKey(2, first.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := newSenderKey(3, second.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forOtherSender := encryptedMessage(t, second.Key, 2, groupID, senderPub, "hello")
+	forOtherSender.SenderPublicKey = []byte("somebody else")
+	truncated := encryptedMessage(t, second.Key, 2, groupID, senderPub, "hello")
+	truncated.Content = truncated.Content[:4]
+	expired := *second
+	expired.PreviousKeyExpiresAt = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name      string
+		senderKey *types.SenderKey
+		msg       *message.ChatMessage
+		err       error
+	}{
+		{"no key", nil, encryptedMessage(t, first.Key, 1, groupID, senderPub, "hello"), errSenderKeyMissing},
+		{"current key", second, encryptedMessage(t, second.Key, 2, groupID, senderPub, "hello"), nil},
+		{"previous key", second, encryptedMessage(t, first.Key, 1, groupID, senderPub, "hello"), nil},
+		{"expired previous key", &expired, encryptedMessage(t, first.Key, 1, groupID, senderPub, "hello"), errSenderKeyExpired},
+		{"newer key", second, encryptedMessage(t, third.Key, 3, groupID, senderPub, "hello"), errSenderKeyMissing},
+		{"replaced twice", third, encryptedMessage(t, first.Key, 1, groupID, senderPub, "hello"), errSenderKeyExpired},
+		{"first key without previous", first, encryptedMessage(t, first.Key, 0, groupID, senderPub, "hello"), errSenderKeyExpired},
+		{"truncated", second, truncated, errMalformedContent},
+	}
+	for _, test := range tests {
+		content, err := decryptGroupContent(test.senderKey, test.msg, time.Now())
+		if err != test.err {
+			t.Errorf("%s: got error %v instead of %v", test.name, err, test.err)
+		} else if err == nil && string(content) != "hello" {
+			t.Errorf("%s: decrypted %q", test.name, content)
+		}
+	}
+
+	// the content is bound to its sender and generation
+	if _, err := decryptGroupContent(second, forOtherSender, time.Now()); err == nil {
+		t.Error("decrypted the content passed off as sent by somebody else")
+	}
+	wrongGeneration := encryptedMessage(t, second.Key, 2, groupID, senderPub, "hello")
+	wrongGeneration.KeyGeneration = 1
+	withPrevious := &types.SenderKey{Key: second.Key, Generation: 2, PreviousKey: second.Key, PreviousKeyExpiresAt: time.Now().Add(time.Minute)}
+	if _, err := decryptGroupContent(withPrevious, wrongGeneration, time.Now()); err == nil {
+		t.Error("decrypted the content with the generation changed")
+	}
+}
+
+func TestVerifySenderKey(t *testing.T) {
+	groupID, senderPub := []byte("group"), []byte("sender")
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(key ed25519.PrivateKey) *message.SenderKey {
+		senderKey := &message.SenderKey{Key: bytes.Repeat([]byte{1}, 32), Generation: 2}
+		data, err := signedSenderKey(groupID, senderPub, senderKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		senderKey.Signature = ed25519.Sign(key, data)
+		return senderKey
+	}
+
+	signed := sign(private)
+	if !verifySenderKey(groupID, senderPub, signed, public) {
+		t.Error("rejected the sender key signed by the sender")
+	}
+	if verifySenderKey(groupID, senderPub, sign(otherPrivate), public) {
+		t.Error("accepted the sender key signed by somebody else")
+	}
+	if verifySenderKey(groupID, senderPub, signed, otherPublic) {
+		t.Error("accepted the sender key against a different signing key")
+	}
+	if verifySenderKey(groupID, []byte("somebody else"), signed, public) {
+		t.Error("accepted the sender key passed on by somebody else")
+	}
+	if verifySenderKey([]byte("other group"), senderPub, signed, public) {
+		t.Error("accepted the sender key passed on to another group")
+	}
+	if verifySenderKey(groupID, senderPub, &message.SenderKey{Key: signed.Key, Generation: signed.Generation}, public) {
+		t.Error("accepted the unsigned sender key")
+	}
+	signed.Generation++
+	if verifySenderKey(groupID, senderPub, signed, public) {
+		t.Error("accepted the sender key with the generation changed")
+	}
+}
+
+func TestVerifyGroupMessage(t *testing.T) {
+	groupID, senderPub := []byte("group"), []byte("sender")
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newSenderKey(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := func() *message.ChatMessage {
+		msg := encryptedMessage(t, key.Key, 1, groupID, senderPub, "hello")
+		msg.Kind = message.Kind_GROUP_TEXT
+		if err := signGroupMessage(msg, private); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if !verifyGroupMessage(signed(), public) {
+		t.Error("rejected the message signed by the sender")
+	}
+	if verifyGroupMessage(signed(), otherPublic) {
+		t.Error("accepted the message against a different signing key")
+	}
+	for _, test := range []struct {
+		name   string
+		change func(msg *message.ChatMessage)
+	}{
+		{"unsigned", func(msg *message.ChatMessage) { msg.Signature = nil }},
+		{"content changed", func(msg *message.ChatMessage) { msg.Content[len(msg.Content)-1]++ }},
+		{"sender changed", func(msg *message.ChatMessage) { msg.SenderPublicKey = []byte("somebody else") }},
+		{"group changed", func(msg *message.ChatMessage) { msg.GroupId = []byte("other group") }},
+		{"generation changed", func(msg *message.ChatMessage) { msg.KeyGeneration++ }},
+	} {
+		msg := signed()
+		test.change(msg)
+		if verifyGroupMessage(msg, public) {
+			t.Errorf("%s: accepted the message", test.name)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"golang.org/x/crypto/ed25519"
+)
+
+// signingKeyProof returns the MAC of the signing key with the key we share with the client with given public key,
+// which proves to the client that the signing key has been sent by the owner of the public key of the subject
+func (c *Client) signingKeyProof(groupID, peerPublicKey []byte, subject *message.GroupMember) ([]byte, error) {
+	key, err := c.transport.SharedKey(peerPublicKey, signingKeyProofPurpose, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(groupID)
+	mac.Write(subject.PublicKey)
+	mac.Write(subject.SigningKey)
+	return mac.Sum(nil), nil
+}
+
+// sendSigningKey sends our signing key directly to the contact, signed by the key itself to prove we own it
+// and accompanied by the proof that it belongs to our public key, which only the contact can check.
+// The group is only there to tell the contact what the key is needed for.
+func (c *Client) sendSigningKey(groupID []byte, target types.Contact) {
+	signingKey, err := c.signingKey()
+	if err != nil {
+		c.log.WithError(err).Error("Could not derive the signing key")
+		return
+	}
+	targetPub, _ := base64.URLEncoding.DecodeString(target.PublicKey)
+	update := &message.GroupUpdate{
+		Op: message.GroupOp_KEY,
+		Subject: &message.GroupMember{
+			PublicKey:         c.PublicKey(),
+			ProviderPublicKey: c.ProviderPublicKey(),
+			SigningKey:        signingKey.Public().(ed25519.PublicKey),
+		},
+	}
+	if update.Proof, err = c.signingKeyProof(groupID, targetPub, update.Subject); err != nil {
+		c.log.WithError(err).Error("Could not prove the signing key is ours")
+		return
+	}
+	if err := signGroupUpdate(groupID, update, signingKey); err != nil {
+		c.log.WithError(err).Error("Could not sign the signing key")
+		return
+	}
+	c.announceGroupUpdate(groupID, []*types.GroupMember{{Contact: target}}, update)
+}
+
+// requestSigningKey remembers that the contact is to be added to the group once we learn its signing key
+// and sends it ours, which it answers with its own. It has to be called with groupMu held.
+func (c *Client) requestSigningKey(groupID []byte, targetPub, providerPub *sphinx.PublicKey) {
+	pendingKey := c.makeAliasCacheKey(targetPub.Bytes(), providerPub.Bytes())
+	for _, pendingID := range c.awaitingSigningKey[pendingKey] {
+		if bytes.Equal(pendingID, groupID) {
+			return
+		}
+	}
+	c.awaitingSigningKey[pendingKey] = append(c.awaitingSigningKey[pendingKey], groupID)
+	go c.sendSigningKey(groupID, *c.toContact(targetPub, providerPub, ""))
+}
+
+// handleSigningKey stores the signing key the contact has sent us directly, answering with ours unless we have
+// asked for it, in which case the contact is added to the groups it has been waiting for.
+// The key has to come with the proof that it belongs to the public key of the sender
+// and the first such key is the one that sticks. It has to be called with groupMu held.
+func (c *Client) handleSigningKey(msg *message.ChatMessage, update *message.GroupUpdate, senderKey, senderProvKey *sphinx.PublicKey) {
+	log := c.log.WithField("sender", c.DisplayName(msg.SenderPublicKey, msg.SenderProviderPublicKey))
+	subjectPub, subjectProvPub := utils.KeysFromBytes(update.Subject.PublicKey, update.Subject.ProviderPublicKey)
+	if !sameKeys(subjectPub, subjectProvPub, senderKey, senderProvKey) || !verifyGroupUpdate(msg.GroupId, update, update.Subject.SigningKey) {
+		log.Warn("Ignored signing key not signed by the sender itself")
+		return
+	}
+	// anybody can put any public key in the message, only the proof shows it is really the sender's one
+	if proof, err := c.signingKeyProof(msg.GroupId, senderKey.Bytes(), update.Subject); err != nil || !hmac.Equal(proof, update.Proof) {
+		log.Warn("Ignored signing key without a valid proof that it belongs to the sender")
+		return
+	}
+
+	contactPub, contactProvPub := c.contactOf(senderKey, senderProvKey)
+	pendingKey := c.makeAliasCacheKey(contactPub.Bytes(), contactProvPub.Bytes())
+	pending, asked := c.awaitingSigningKey[pendingKey]
+	// otherwise anybody could make us answer
+	if !asked && c.unknownSenders != ShowUnknownSenders && c.isUnknownSender(msg) {
+		log.Info("Ignored signing key of unknown sender")
+		return
+	}
+	// the key belongs to the endpoint, not to the whole contact
+	signingKey := c.chatStore.GetSigningKey(senderKey, senderProvKey)
+	if signingKey != nil && !bytes.Equal(signingKey, update.Subject.SigningKey) {
+		log.Warn("Ignored signing key differing from the one the sender has sent before")
+		return
+	}
+	if signingKey == nil {
+		signingKey = update.Subject.SigningKey
+		c.chatStore.SetSigningKey(senderKey, senderProvKey, signingKey)
+		log.Debug("Learned the signing key of the contact")
+	}
+
+	if !asked {
+		// it is about to add us to a group
+		go c.sendSigningKey(msg.GroupId, *c.toContact(contactPub, contactProvPub, ""))
+		return
+	}
+	delete(c.awaitingSigningKey, pendingKey)
+	for _, groupID := range pending {
+		c.completeAddition(groupID, contactPub, contactProvPub, signingKey)
+	}
+}
+
+// completeAddition adds the contact to the group now that we know its signing key,
+// unless something has changed in the meantime, and lets the subscribers know about it.
+// It has to be called with groupMu held.
+func (c *Client) completeAddition(groupID []byte, targetPub, providerPub *sphinx.PublicKey, signingKey []byte) {
+	group := c.chatStore.GetGroup(groupID)
+	if group == nil || !canManage(group.Role) || c.findMember(group, targetPub, providerPub) >= 0 || c.isBanned(group, targetPub, providerPub) {
+		return
+	}
+	member := &types.GroupMember{
+		Contact:    *c.toContact(targetPub, providerPub, ""),
+		Role:       types.GroupRoleMember,
+		SigningKey: base64.URLEncoding.EncodeToString(signingKey),
+	}
+	if err := c.addMember(groupID, group, member); err != nil {
+		c.log.WithError(err).Error("Could not add the member to the group")
+		return
+	}
+
+	ourPub, ourProviderPub := utils.KeysFromBytes(c.PublicKey(), c.ProviderPublicKey())
+	change := &types.GroupChange{
+		Group:  *c.withAliases(group),
+		Kind:   types.GroupMemberAdded,
+		Member: *c.toContact(targetPub, providerPub, c.assignedName(targetPub.Bytes(), providerPub.Bytes())),
+		By:     *c.toContact(ourPub, ourProviderPub, ""),
+	}
+	c.notifyGroupChange(change)
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/base64"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// contactCounters accumulate the traffic statistics of a single contact
+type contactCounters struct {
+	publicKey         []byte
+	providerPublicKey []byte
+
+	messagesSent     uint64
+	messagesReceived uint64
+	failedSends      uint64
+	bytesSent        uint64
+	bytesReceived    uint64
+	sendDurationSum  time.Duration
+
+	delaySamples uint64
+	minDelay     time.Duration
+	delaySum     time.Duration
+	lastActivity time.Time
+}
+
+// statsCollector keeps the traffic statistics in memory for the lifetime of the client
+type statsCollector struct {
+	sync.Mutex
+	since    time.Time
+	contacts map[string]*contactCounters
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		since:    time.Now(),
+		contacts: make(map[string]*contactCounters),
+	}
+}
+
+// must be called with the lock held
+func (s *statsCollector) counters(publicKey, providerPublicKey []byte) *contactCounters {
+	key := string(publicKey) + string(providerPublicKey)
+	counters, ok := s.contacts[key]
+	if !ok {
+		counters = &contactCounters{
+			publicKey:         publicKey,
+			providerPublicKey: providerPublicKey,
+		}
+		s.contacts[key] = counters
+	}
+	return counters
+}
+
+func (s *statsCollector) recordSent(publicKey, providerPublicKey []byte, size int, took time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	counters := s.counters(publicKey, providerPublicKey)
+	counters.messagesSent++
+	counters.bytesSent += uint64(size)
+	counters.sendDurationSum += took
+	counters.lastActivity = time.Now()
+}
+
+func (s *statsCollector) recordFailedSend(publicKey, providerPublicKey []byte) {
+	s.Lock()
+	defer s.Unlock()
+	counters := s.counters(publicKey, providerPublicKey)
+	counters.failedSends++
+	counters.lastActivity = time.Now()
+}
+
+func (s *statsCollector) recordReceived(publicKey, providerPublicKey []byte, size int, sentAt time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	counters := s.counters(publicKey, providerPublicKey)
+	counters.messagesReceived++
+	counters.bytesReceived += uint64(size)
+	counters.lastActivity = now
+
+	// the delay depends on the sender's clock, so it is negative if the clock is ahead of ours by more than
+	// the actual delay, in which case it is clamped to zero rather than skewing the average below any real value
+	delay := now.Sub(sentAt)
+	if delay < 0 {
+		delay = 0
+	}
+	if counters.delaySamples == 0 || delay < counters.minDelay {
+		counters.minDelay = delay
+	}
+	counters.delaySum += delay
+	counters.delaySamples++
+}
+
+// Stats returns the snapshot of the traffic statistics of all contacts we exchanged messages with
+// since the client was created, sorted by their display names.
+func (c *Client) Stats() *types.Stats {
+	c.stats.Lock()
+	defer c.stats.Unlock()
+
+	uptime := time.Since(c.stats.since)
+	stats := &types.Stats{
+		Since:    c.stats.since,
+		Uptime:   uptime,
+		Contacts: make([]*types.ContactStats, 0, len(c.stats.contacts)),
+	}
+
+	for _, counters := range c.stats.contacts {
+		contactStats := &types.ContactStats{
+			Contact: types.Contact{
+				Alias:             c.DisplayName(counters.publicKey, counters.providerPublicKey),
+				PublicKey:         base64.URLEncoding.EncodeToString(counters.publicKey),
+				ProviderPublicKey: base64.URLEncoding.EncodeToString(counters.providerPublicKey),
+			},
+			MessagesSent:     counters.messagesSent,
+			MessagesReceived: counters.messagesReceived,
+			FailedSends:      counters.failedSends,
+			BytesSent:        counters.bytesSent,
+			BytesReceived:    counters.bytesReceived,
+			DelaySamples:     counters.delaySamples,
+			MinDelay:         counters.minDelay,
+			LastActivity:     counters.lastActivity,
+		}
+		if uptime > 0 {
+			contactStats.SentBytesPerSecond = float64(counters.bytesSent) / uptime.Seconds()
+			contactStats.ReceivedBytesPerSecond = float64(counters.bytesReceived) / uptime.Seconds()
+		}
+		if counters.messagesSent > 0 {
+			contactStats.AvgSendDuration = counters.sendDurationSum / time.Duration(counters.messagesSent)
+		}
+		if counters.delaySamples > 0 {
+			contactStats.AvgDelay = counters.delaySum / time.Duration(counters.delaySamples)
+			contactStats.AvgExtraDelay = contactStats.AvgDelay - contactStats.MinDelay
+		}
+		stats.Contacts = append(stats.Contacts, contactStats)
+	}
+
+	sort.Slice(stats.Contacts, func(i, j int) bool {
+		if stats.Contacts[i].Alias == stats.Contacts[j].Alias {
+			return stats.Contacts[i].PublicKey < stats.Contacts[j].PublicKey
+		}
+		return stats.Contacts[i].Alias < stats.Contacts[j].Alias
+	})
+	return stats
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"testing"
+	"time"
+)
+
+func TestStatsOfUnknownSenders(t *testing.T) {
+	network, clients, cleanup := startTestClientsWithPolicy(t, loopback.Config{}, 2, DropUnknownSenders)
+	defer cleanup()
+	alice, bob := clients[0], clients[1]
+
+	sendAll(t, alice, bob, []string{"dropped"})
+	network.Wait()
+	time.Sleep(testQuietPeriod)
+	if stats := bob.Stats(); len(stats.Contacts) != 0 {
+		t.Fatalf("the statistics of a dropped sender have been recorded: %+v", stats.Contacts[0])
+	}
+
+	contact := alice.contact()
+	contact.Alias = "alice"
+	if err := bob.AddAlias(contact); err != nil {
+		t.Fatal(err)
+	}
+	sendAll(t, alice, bob, []string{"delivered"})
+	nextReceived(t, bob, types.ReceivedMessage)
+	stats := bob.Stats()
+	if len(stats.Contacts) != 1 || stats.Contacts[0].MessagesReceived != 1 {
+		t.Errorf("the statistics of the contact are wrong: %+v", stats.Contacts)
+	}
+}
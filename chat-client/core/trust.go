@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/storage"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrMalformedKeyChange = errors.New("the key change does not contain valid keys")
+	ErrKeyChangeOutdated  = errors.New("the pinned keys have changed since the key change was recorded")
+)
+
+// pinContacts pins the keys of every contact seen for the first time and reports the ones that differ
+// from what has already been pinned. If nothing has been pinned yet, i.e. the store was created
+// before the pinning was introduced, all existing contacts are trusted as they are.
+func (c *Client) pinContacts() {
+	c.pinMu.Lock()
+	defer c.unlockPins()
+
+	trustExisting := len(c.chatStore.GetAllPins()) == 0
+	for _, storedAlias := range c.chatStore.GetAllAliases() {
+		if storedAlias.AssignedName == "" || storedAlias.PublicKey == nil || storedAlias.ProviderPublicKey == nil {
+			continue
+		}
+		if trustExisting {
+			if c.chatStore.GetPin(storedAlias.PublicKey) == nil {
+				c.pin(storedAlias.PublicKey, storedAlias.ProviderPublicKey, storedAlias.AssignedName)
+			}
+			continue
+		}
+		// the user has chosen the name themselves, so it is not a claim of somebody else's name
+		c.checkKeys(storedAlias.AssignedName, storedAlias.PublicKey, storedAlias.ProviderPublicKey, types.KeySourceAlias, true)
+	}
+}
+
+func (c *Client) pin(targetPub, providerPub *sphinx.PublicKey, name string) {
+	c.chatStore.SetPin(&storage.Pin{
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+		Name:              name,
+		FirstSeen:         time.Now(),
+	})
+}
+
+// checkKeys compares the keys against the pinned ones, reporting a different provider of a pinned public key.
+// The reported change is returned, or nil if there was nothing to report.
+// If claimedName is not empty, the keys are reported as well when they are not pinned, but somebody else
+// has been pinned under that name. Unknown keys are pinned under the name if it is not empty and pinUnknown is set.
+// It must be called with pinMu held.
+func (c *Client) checkKeys(claimedName string, targetPub, providerPub *sphinx.PublicKey, source string, pinUnknown bool) *types.KeyChange {
+	if pin := c.chatStore.GetPin(targetPub); pin != nil {
+		// endpoints sharing the public key might have been linked to a single contact by the user
+		if !bytes.Equal(pin.ProviderPublicKey.Bytes(), providerPub.Bytes()) && !c.sameContact(targetPub, providerPub, pin.PublicKey, pin.ProviderPublicKey) {
+			return c.reportKeyChange(types.KeyChangeProvider, source, pin, targetPub, providerPub, claimedName)
+		}
+		return nil
+	}
+	if claimedName == "" {
+		// there is nothing to tie the keys to, they are pinned once the user gives them a name
+		return nil
+	}
+	if !pinUnknown {
+		for _, pin := range c.chatStore.GetAllPins() {
+			if pin.Name == claimedName {
+				return c.reportKeyChange(types.KeyChangeName, source, pin, targetPub, providerPub, claimedName)
+			}
+		}
+		return nil
+	}
+	c.pin(targetPub, providerPub, claimedName)
+	return nil
+}
+
+// unlockPins releases pinMu and only then notifies the subscribers about the key changes reported meanwhile,
+// as a subscriber that is not keeping up would otherwise hold up everybody checking the keys
+func (c *Client) unlockPins() {
+	changes := c.reportedKeyChanges
+	c.reportedKeyChanges = nil
+	c.pinMu.Unlock()
+	for _, change := range changes {
+		c.subscribers.publish(&types.Received{Kind: types.ReceivedKeyChange, KeyChange: change}, c.haltedCh)
+	}
+}
+
+// reportKeyChange adds the change to the audit log and queues the notification of the subscribers about it,
+// unless the very same change is already there waiting for the user to decide about it,
+// in which case the logged one is returned instead. It must be called with pinMu held.
+func (c *Client) reportKeyChange(kind types.KeyChangeKind, source string, pin *storage.Pin, seenPub, seenProviderPub *sphinx.PublicKey, seenName string) *types.KeyChange {
+	change := &types.KeyChange{
+		Kind:   kind,
+		At:     time.Now(),
+		Source: source,
+		Pinned: *c.toContact(pin.PublicKey, pin.ProviderPublicKey, pin.Name),
+		Seen:   *c.toContact(seenPub, seenProviderPub, seenName),
+	}
+	for _, logged := range c.chatStore.GetKeyChanges() {
+		if !logged.Accepted && logged.Kind == change.Kind && logged.Pinned == change.Pinned && logged.Seen == change.Seen {
+			return logged
+		}
+	}
+
+	c.chatStore.StoreKeyChange(change)
+	c.log.WithFields(logrus.Fields{
+		"kind":              kind,
+		"source":            source,
+		"pinnedName":        change.Pinned.Alias,
+		"pinnedPublicKey":   change.Pinned.PublicKey,
+		"pinnedProviderKey": change.Pinned.ProviderPublicKey,
+		"seenName":          change.Seen.Alias,
+		"seenPublicKey":     change.Seen.PublicKey,
+		"seenProviderKey":   change.Seen.ProviderPublicKey,
+	}).Warn("Seen keys different from the pinned ones")
+	c.reportedKeyChanges = append(c.reportedKeyChanges, change)
+	return change
+}
+
+// checkSender compares the keys of the sender of a received message against the pinned ones
+func (c *Client) checkSender(senderKey, senderProvKey *sphinx.PublicKey) {
+	c.pinMu.Lock()
+	defer c.unlockPins()
+	c.checkKeys("", senderKey, senderProvKey, types.KeySourceMessage, false)
+}
+
+// CheckClaimedName compares the keys of a client claiming the name, for example in an invite,
+// against the pinned ones. It reports a different provider of a pinned public key or different keys
+// claiming the name of a pinned contact, returning the reported change. Nothing gets pinned
+// until the user gives the client an alias.
+func (c *Client) CheckClaimedName(name string, publicKey, providerPublicKey []byte, source string) *types.KeyChange {
+	targetPub, providerPub := utils.KeysFromBytes(publicKey, providerPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return nil
+	}
+	c.pinMu.Lock()
+	defer c.unlockPins()
+	return c.checkKeys(name, targetPub, providerPub, source, false)
+}
+
+// KeyChanges returns the audit log of all keys seen for our contacts that differed from the pinned ones,
+// starting from the oldest.
+func (c *Client) KeyChanges() []*types.KeyChange {
+	return c.chatStore.GetKeyChanges()
+}
+
+// AcceptKeyChange pins the seen keys instead of the original ones. If the contact has moved to another provider,
+// the new keys become its preferred endpoint, so that the conversation carries on where it was.
+func (c *Client) AcceptKeyChange(change *types.KeyChange) error {
+	pinnedPub, pinnedProviderPub := utils.KeysFromB64Strings(change.Pinned.PublicKey, change.Pinned.ProviderPublicKey)
+	seenPub, seenProviderPub := utils.KeysFromB64Strings(change.Seen.PublicKey, change.Seen.ProviderPublicKey)
+	if pinnedPub == nil || pinnedProviderPub == nil || seenPub == nil || seenProviderPub == nil {
+		return ErrMalformedKeyChange
+	}
+
+	c.pinMu.Lock()
+	switch change.Kind {
+	case types.KeyChangeProvider:
+		pin := c.chatStore.GetPin(pinnedPub)
+		if pin == nil || !bytes.Equal(pin.ProviderPublicKey.Bytes(), pinnedProviderPub.Bytes()) {
+			c.pinMu.Unlock()
+			return ErrKeyChangeOutdated
+		}
+		c.pin(seenPub, seenProviderPub, pin.Name)
+		contactPub, contactProviderPub := c.contactOf(pinnedPub, pinnedProviderPub)
+		if seenContactPub, seenContactProviderPub := c.contactOf(seenPub, seenProviderPub); !sameKeys(contactPub, contactProviderPub, seenContactPub, seenContactProviderPub) {
+			c.mergeInto(contactPub, contactProviderPub, seenContactPub, seenContactProviderPub)
+		}
+		c.preferEndpoint(seenPub, seenProviderPub)
+	case types.KeyChangeName:
+		c.pin(seenPub, seenProviderPub, change.Seen.Alias)
+	}
+	change.Accepted = true
+	c.chatStore.StoreKeyChange(change)
+	c.pinMu.Unlock()
+
+	c.log.WithFields(logrus.Fields{
+		"kind":            change.Kind,
+		"seenPublicKey":   base64.URLEncoding.EncodeToString(seenPub.Bytes()),
+		"seenProviderKey": base64.URLEncoding.EncodeToString(seenProviderPub.Bytes()),
+	}).Info("Accepted key change")
+	c.RefreshAliasCache()
+	return nil
+}
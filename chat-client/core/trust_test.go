@@ -0,0 +1,95 @@
+package core
+
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/transport/loopback"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"testing"
+)
+
+func addAlias(t *testing.T, c, target *testClient, name string) {
+	t.Helper()
+	contact := target.contact()
+	contact.Alias = name
+	if err := c.AddAlias(contact); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyChangeOfSender(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		unknownSenders UnknownSendersPolicy
+		reported       bool
+	}{
+		{"shown", ShowUnknownSenders, true},
+		{"held", HoldUnknownSenders, false},
+		{"dropped", DropUnknownSenders, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, clients, cleanup := startTestClientsWithPolicy(t, loopback.Config{}, 2, test.unknownSenders)
+			defer cleanup()
+			alice, bob := clients[0], clients[1]
+			addAlias(t, bob, alice, "alice")
+			_, otherProviderKey, err := sphinx.GenerateKeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// alice's public key at another provider, all of the loopback clients share a single one
+			msg := alice.createMessagePayload("hello")
+			msg.Kind = message.Kind_TEXT
+			msg.SenderProviderPublicKey = otherProviderKey.Bytes()
+			bob.processReceivedMessage(msg)
+
+			changes := bob.KeyChanges()
+			if !test.reported {
+				if len(changes) != 0 {
+					t.Errorf("the keys of the sender that is not accepted have been reported: %+v", changes[0])
+				}
+				return
+			}
+			if len(changes) != 1 || changes[0].Kind != types.KeyChangeProvider || changes[0].Source != types.KeySourceMessage {
+				t.Fatalf("unexpected key changes %+v", changes)
+			}
+			if received := nextReceived(t, bob, types.ReceivedKeyChange); received.KeyChange.Seen != changes[0].Seen {
+				t.Errorf("reported %+v instead of %+v", received.KeyChange.Seen, changes[0].Seen)
+			}
+		})
+	}
+}
+
+func TestClaimedName(t *testing.T) {
+	_, clients, cleanup := startTestClients(t, loopback.Config{}, 3)
+	defer cleanup()
+	alice, bob, mallory := clients[0], clients[1], clients[2]
+	addAlias(t, bob, alice, "alice")
+
+	if change := bob.CheckClaimedName("alice", alice.PublicKey(), alice.ProviderPublicKey(), types.KeySourceInvite); change != nil {
+		t.Fatalf("the pinned keys have been reported: %+v", change)
+	}
+	if change := bob.CheckClaimedName("carol", mallory.PublicKey(), mallory.ProviderPublicKey(), types.KeySourceInvite); change != nil {
+		t.Fatalf("an unpinned name has been reported: %+v", change)
+	}
+
+	change := bob.CheckClaimedName("alice", mallory.PublicKey(), mallory.ProviderPublicKey(), types.KeySourceInvite)
+	if change == nil || change.Kind != types.KeyChangeName || change.Seen.PublicKey != mallory.contact().PublicKey {
+		t.Fatalf("unexpected key change %+v", change)
+	}
+	nextReceived(t, bob, types.ReceivedKeyChange)
+	// the same claim is not reported again while it is waiting for the user
+	if again := bob.CheckClaimedName("alice", mallory.PublicKey(), mallory.ProviderPublicKey(), types.KeySourceProfile); again == nil || again.Seen != change.Seen {
+		t.Errorf("the logged claim has not been returned: %+v", again)
+	}
+	if changes := bob.KeyChanges(); len(changes) != 1 {
+		t.Errorf("%v key changes have been logged instead of one", len(changes))
+	}
+
+	if err := bob.AcceptKeyChange(change); err != nil {
+		t.Fatal(err)
+	}
+	if again := bob.CheckClaimedName("alice", mallory.PublicKey(), mallory.ProviderPublicKey(), types.KeySourceInvite); again != nil {
+		t.Errorf("the accepted keys have been reported again: %+v", again)
+	}
+}
@@ -1,13 +1,11 @@
 package chat_client
 
 import (
-	"encoding/base64"
 	"fmt"
 	"github.com/jroimartin/gocui"
 	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/core"
 	"github.com/nymtech/demo-mixnet-chat-client/gui/layout"
-	"github.com/nymtech/demo-mixnet-chat-client/utils"
-	"github.com/nymtech/nym-mixnet/sphinx"
 )
 
 func (c *ChatClient) resetView(v *gocui.View) error {
@@ -20,66 +18,20 @@ func (c *ChatClient) updateSendViewTitle(g *gocui.Gui) error {
 	if err != nil {
 		return err
 	}
-	v.Title = fmt.Sprintf(" send to: %s ", c.session.RecipientAlias())
-	return nil
-}
-
-func (c *ChatClient) makeAliasCacheKey(senderPublicKey, senderProviderPublicKey []byte) string {
-	b64SenderKey := base64.URLEncoding.EncodeToString(senderPublicKey)
-	b64SenderProviderKey := base64.URLEncoding.EncodeToString(senderProviderPublicKey)
-	cacheEntryKey := b64SenderKey + b64SenderProviderKey
-	return cacheEntryKey
-}
-
-// naming things is difficult...
-func (c *ChatClient) recoverKeysFromCacheKey(key string) (*sphinx.PublicKey, *sphinx.PublicKey) {
-	// due to both keys having same and constant length, we can just split the key in half
-	// and due to it being in base64, hence containing only ASCII, we don't need to bother with runes and UTF8 encoding
-	bKey := []byte(key)
-	key1 := string(bKey[:len(bKey)/2])
-	key2 := string(bKey[len(bKey)/2:])
-
-	decodedKey1, err := base64.URLEncoding.DecodeString(key1)
-	if err != nil {
-		return nil, nil
+	if groupID := c.session.Group(); groupID != "" {
+		members := 0
+		if group, err := c.core.Group(groupID); err == nil {
+			members = len(group.Members)
+		}
+		v.Title = fmt.Sprintf(" send to: group %s (%d other member(s)) ", c.session.GroupName(), members)
+		return nil
 	}
-	decodedKey2, err := base64.URLEncoding.DecodeString(key2)
-	if err != nil {
-		return nil, nil
-	}
-
-	return utils.KeysFromBytes(decodedKey1, decodedKey2)
-}
-
-
-func (c *ChatClient) tryAliasStore(senderPublicKey, senderProviderPublicKey []byte) *alias.Alias {
-	senderKey, senderProvKey := utils.KeysFromBytes(senderPublicKey, senderProviderPublicKey)
-	if senderKey != nil && senderProvKey != nil {
-		return c.chatStore.GetAlias(senderKey, senderProvKey)
+	recipient := c.session.Recipient()
+	presence := c.core.Presence(recipient.PubKey, recipient.Provider.PubKey)
+	recipientName := c.session.RecipientAlias()
+	if c.core.IsVerified(recipient.PubKey, recipient.Provider.PubKey) {
+		recipientName += core.VerifiedMark
 	}
+	v.Title = fmt.Sprintf(" send to: %s (%s) ", recipientName, alias.PresenceString(presence))
 	return nil
 }
-
-
-func (c *ChatClient) defaultDisplayName(key []byte) string {
-	b64Key := base64.URLEncoding.EncodeToString(key)
-	return "??? - " + b64Key[:8] + "..."
-}
-
-
-func (c *ChatClient) getDisplayName(senderPublicKey, senderProviderPublicKey []byte) string {
-	cacheEntryKey := c.makeAliasCacheKey(senderPublicKey, senderProviderPublicKey)
-	displayName, ok := c.aliasCache[cacheEntryKey]
-	if ok {
-		return displayName
-	}
-	if storedAlias := c.tryAliasStore(senderPublicKey, senderProviderPublicKey); storedAlias != nil {
-		// it's not in cache so update the cache
-		if storedAlias.AssignedName != "" {
-			cacheEntryKey := c.makeAliasCacheKey(senderPublicKey, senderProviderPublicKey)
-			c.aliasCache[cacheEntryKey] = storedAlias.AssignedName
-			return storedAlias.AssignedName
-		}
-	}
-	return c.defaultDisplayName(senderPublicKey)
-}
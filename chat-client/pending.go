@@ -0,0 +1,203 @@
+package chat_client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/gui"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/nym-mixnet/config"
+	"sort"
+	"sync"
+)
+
+// pendingConversation holds messages received from particular sender that have not been displayed yet
+type pendingConversation struct {
+	sender   string
+	messages []*types.Message
+}
+
+// router passes everything received by the core client to the active chat session.
+// Whatever arrives while there is no session, for example while the recipient is being chosen,
+// or is sent by somebody else than the current recipient, is kept as pending until its conversation is opened.
+type router struct {
+	sync.Mutex
+	activeGui       *gocui.Gui
+	activeKey       string
+	pending         map[string]*pendingConversation
+	pendingRequests int
+	// changes of the keys and of the membership of the groups are too important to be reduced to a counter,
+	// so they are kept whole
+	pendingChanges []*types.Received
+}
+
+func newRouter() *router {
+	return &router{
+		pending: make(map[string]*pendingConversation),
+	}
+}
+
+func conversationKey(publicKey, providerPublicKey string) string {
+	return publicKey + providerPublicKey
+}
+
+// groupConversationKey can never clash with the key of the conversation with a client, as it is not just base64
+func groupConversationKey(groupID string) string {
+	return "group:" + groupID
+}
+
+func (c *ChatClient) routeReceived() {
+	// channel is closed once the client is shut down
+	for received := range c.received {
+		c.router.Lock()
+		g := c.router.activeGui
+		switch received.Kind {
+		case types.ReceivedContactRequest:
+			if g != nil {
+				c.handleReceived(g, received)
+			} else {
+				c.router.pendingRequests++
+			}
+		case types.ReceivedMessage:
+			key := conversationKey(received.Message.SenderPublicKey, received.Message.SenderProviderPublicKey)
+			if g != nil && key == c.router.activeKey {
+				c.handleReceived(g, received)
+			} else {
+				c.addPending(key, received.Message.Sender, received.Message)
+				if g != nil {
+					gui.WriteNotice(fmt.Sprintf("New message from %s. It will be shown once you open the conversation.\n",
+						received.Message.Sender,
+					), g, "Pending")
+				}
+			}
+		case types.ReceivedGroupMessage:
+			key := groupConversationKey(received.Message.Group)
+			groupName := received.Message.Group
+			if received.Group != nil {
+				groupName = received.Group.Name
+			}
+			if g != nil && key == c.router.activeKey {
+				c.handleReceived(g, received)
+			} else {
+				c.addPending(key, "group "+groupName, received.Message)
+				if g != nil {
+					gui.WriteNotice(fmt.Sprintf("New message from %s in group %s. Type /group open %s to read it.\n",
+						received.Message.Sender,
+						groupName,
+						groupName,
+					), g, "Pending")
+				}
+			}
+		case types.ReceivedKeyChange, types.ReceivedGroupChange:
+			if g != nil {
+				c.handleReceived(g, received)
+			} else {
+				c.router.pendingChanges = append(c.router.pendingChanges, received)
+			}
+		case types.ReceivedPresence, types.ReceivedProfile:
+			// while the recipient is being chosen, the list already shows what the contacts are up to
+			if g != nil {
+				c.handleReceived(g, received)
+			}
+		}
+		c.router.Unlock()
+	}
+}
+
+// must be called with the router lock held
+func (c *ChatClient) addPending(key, sender string, msg *types.Message) {
+	conversation, ok := c.router.pending[key]
+	if !ok {
+		conversation = &pendingConversation{
+			messages: make([]*types.Message, 0, 1),
+		}
+		c.router.pending[key] = conversation
+	}
+	conversation.sender = sender
+	conversation.messages = append(conversation.messages, msg)
+}
+
+// pendingCount returns the number of not yet displayed messages from the given client
+func (c *ChatClient) pendingCount(publicKey, providerPublicKey string) int {
+	c.router.Lock()
+	defer c.router.Unlock()
+	if conversation, ok := c.router.pending[conversationKey(publicKey, providerPublicKey)]; ok {
+		return len(conversation.messages)
+	}
+	return 0
+}
+
+func (c *ChatClient) hasPending(client config.ClientConfig) bool {
+	return c.pendingCount(
+		base64.URLEncoding.EncodeToString(client.PubKey),
+		base64.URLEncoding.EncodeToString(client.Provider.PubKey),
+	) > 0
+}
+
+// activateSession makes the gui the target of all received messages and displays
+// everything that was pending for the current recipient alongside the summary of the rest.
+func (c *ChatClient) activateSession(g *gocui.Gui, publicKey, providerPublicKey string, sessionHalt <-chan struct{}) {
+	c.router.Lock()
+	defer c.router.Unlock()
+	// the session might have already finished in the meantime
+	select {
+	case <-sessionHalt:
+		return
+	default:
+	}
+	c.router.activeGui = g
+	c.router.activeKey = conversationKey(publicKey, providerPublicKey)
+	c.showPending(g)
+
+	others := make([]*pendingConversation, 0, len(c.router.pending))
+	for _, conversation := range c.router.pending {
+		others = append(others, conversation)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].sender < others[j].sender })
+	for _, conversation := range others {
+		gui.WriteNotice(fmt.Sprintf("%d unread message(s) from %s\n",
+			len(conversation.messages),
+			conversation.sender,
+		), g, "Pending")
+	}
+
+	if c.router.pendingRequests > 0 {
+		gui.WriteNotice(fmt.Sprintf("%d new contact(s) tried to reach you. Type /requests to review them.\n",
+			c.router.pendingRequests,
+		), g, "Request")
+		c.router.pendingRequests = 0
+	}
+
+	for _, received := range c.router.pendingChanges {
+		c.handleReceived(g, received)
+	}
+	c.router.pendingChanges = nil
+}
+
+// showPending displays all pending messages of the active conversation.
+// It must be called with the router lock held.
+func (c *ChatClient) showPending(g *gocui.Gui) {
+	if conversation, ok := c.router.pending[c.router.activeKey]; ok {
+		for _, msg := range conversation.messages {
+			c.handleReceived(g, &types.Received{Kind: types.ReceivedMessage, Message: msg})
+		}
+		delete(c.router.pending, c.router.activeKey)
+	}
+}
+
+// switchConversation makes the conversation with given key the one displayed by the active session
+func (c *ChatClient) switchConversation(key string) {
+	c.router.Lock()
+	defer c.router.Unlock()
+	c.router.activeKey = key
+	if c.router.activeGui != nil {
+		c.showPending(c.router.activeGui)
+	}
+}
+
+func (c *ChatClient) deactivateSession() {
+	c.router.Lock()
+	defer c.router.Unlock()
+	c.router.activeGui = nil
+	c.router.activeKey = ""
+}
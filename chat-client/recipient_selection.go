@@ -1,72 +1,315 @@
 package chat_client
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/jroimartin/gocui"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/core"
+	"github.com/nymtech/demo-mixnet-chat-client/gui/picker"
+	"github.com/nymtech/demo-mixnet-chat-client/invite"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
 	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"sort"
+	"strings"
+	"time"
 )
 
-func (c *ChatClient) toChoosable(client config.ClientConfig) string {
-	b64Key := base64.URLEncoding.EncodeToString(client.PubKey)
-	b64ProviderKey := base64.URLEncoding.EncodeToString(client.Provider.PubKey)
+const (
+	pastedSection    = "pasted keys"
+	unreadSection    = "unread"
+	favouriteSection = "favourites"
+	recentSection    = "recent conversations"
+	onlineSection    = "online"
+	offlineSection   = "offline contacts"
 
-	aliasedName := "<no alias>"
-	possibleAlias := c.tryAliasStore(client.PubKey, client.Provider.PubKey)
-	if possibleAlias != nil && possibleAlias.AssignedName != "" {
-		aliasedName = possibleAlias.AssignedName
+	// conversations older than the most recent ones are listed amongst the rest of the clients
+	maxRecentConversations = 10
+
+	nameColumnWidth = 16
+	keyPrefixLength = 12
+)
+
+// recipientCandidate is a client the user can choose to talk to.
+// It is either present in the network view, one of our contacts or somebody we have talked to before.
+type recipientCandidate struct {
+	publicKey         []byte
+	providerPublicKey []byte
+	// recipient is nil if the client is not present in the current network view
+	recipient        *config.ClientConfig
+	alias            string
+	note             string
+	favourite        bool
+	lastConversation time.Time
+	recent           bool
+	pending          int
+	// nil if the client has never sent us its profile
+	profile *types.Profile
+}
+
+func (r *recipientCandidate) section() string {
+	switch {
+	case r.pending > 0:
+		return unreadSection
+	case r.favourite:
+		return favouriteSection
+	case r.recent:
+		return recentSection
+	case r.recipient != nil:
+		return onlineSection
+	default:
+		return offlineSection
 	}
+}
 
-	return fmt.Sprintf("%10s - (Pubkey) %s @[Provider] %s", aliasedName, b64Key, b64ProviderKey)
+func (r *recipientCandidate) name() string {
+	if r.alias == "" {
+		return "<no alias>"
+	}
+	return r.alias
 }
 
-func (c *ChatClient) makeChoosables(recipients []config.ClientConfig) (map[string]config.ClientConfig, []string) {
-	choosableRecipients := make(map[string]config.ClientConfig)
-	options := make([]string, len(recipients)+1)
-	for i, recipient := range recipients {
-		choosableRecipient := c.toChoosable(recipient)
-		options[i] = choosableRecipient
-		choosableRecipients[choosableRecipient] = recipient // basically a mapping from the string back to original struct
+func sectionOrder(section string) int {
+	for i, s := range []string{unreadSection, favouriteSection, recentSection, onlineSection, offlineSection} {
+		if s == section {
+			return i
+		}
 	}
+	return -1
+}
 
-	options[len(recipients)] = refreshClientOption
-	return choosableRecipients, options
+// less orders the candidates within their sections
+func (r *recipientCandidate) less(other *recipientCandidate) bool {
+	switch r.section() {
+	case unreadSection:
+		if r.pending != other.pending {
+			return r.pending > other.pending
+		}
+	case recentSection:
+		return r.lastConversation.After(other.lastConversation)
+	}
+	// clients with aliases go before the ones without
+	if (r.alias == "") != (other.alias == "") {
+		return r.alias != ""
+	}
+	if r.alias != other.alias {
+		return strings.ToLower(r.alias) < strings.ToLower(other.alias)
+	}
+	return base64.URLEncoding.EncodeToString(r.publicKey) < base64.URLEncoding.EncodeToString(other.publicKey)
 }
 
-func (c *ChatClient) chooseRecipient() (string, map[string]config.ClientConfig) {
-	choosableRecipients, choosableOptions := c.makeChoosables(c.mixClient.Network.Clients)
+// recipientCandidates gathers everybody the user might want to talk to
+func (c *ChatClient) recipientCandidates() []*recipientCandidate {
+	candidates := make(map[string]*recipientCandidate)
+	candidate := func(publicKey, providerPublicKey []byte) *recipientCandidate {
+		// contacts are listed once, under their preferred endpoint
+		publicKey, providerPublicKey = c.core.ContactOf(publicKey, providerPublicKey)
+		key := conversationKey(
+			base64.URLEncoding.EncodeToString(publicKey),
+			base64.URLEncoding.EncodeToString(providerPublicKey),
+		)
+		if existing, ok := candidates[key]; ok {
+			return existing
+		}
+		created := &recipientCandidate{
+			publicKey:         publicKey,
+			providerPublicKey: providerPublicKey,
+		}
+		candidates[key] = created
+		return created
+	}
 
-	var chosenClientOption string
-	prompt := &survey.Select{
-		Message: "Choose another client to communicate with:",
-		Options: choosableOptions,
+	for _, client := range c.core.DirectoryClients() {
+		client := client
+		listed := candidate(client.PubKey, client.Provider.PubKey)
+		// other endpoints of the contact are not where the messages are sent to
+		if bytes.Equal(listed.publicKey, client.PubKey) && bytes.Equal(listed.providerPublicKey, client.Provider.PubKey) {
+			listed.recipient = &client
+		}
+	}
+	chatStore := c.core.Store()
+	for _, storedAlias := range chatStore.GetAllAliases() {
+		candidate(storedAlias.PublicKey.Bytes(), storedAlias.ProviderPublicKey.Bytes())
+	}
+	conversations := chatStore.GetConversations()
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastMessageAt.After(conversations[j].LastMessageAt)
+	})
+	for i, conversation := range conversations {
+		recent := candidate(conversation.PublicKey.Bytes(), conversation.ProviderPublicKey.Bytes())
+		recent.lastConversation = conversation.LastMessageAt
+		recent.recent = i < maxRecentConversations
 	}
-	if err := survey.AskOne(prompt, &chosenClientOption, nil); err == terminal.InterruptErr {
-		c.Shutdown()
-		return "", nil
+
+	sorted := make([]*recipientCandidate, 0, len(candidates))
+	for _, r := range candidates {
+		if targetPub, providerPub := utils.KeysFromBytes(r.publicKey, r.providerPublicKey); targetPub != nil && providerPub != nil {
+			r.alias = chatStore.GetAlias(targetPub, providerPub).AssignedName
+			r.note = chatStore.GetNote(targetPub, providerPub)
+			r.profile = chatStore.GetProfile(targetPub, providerPub)
+			r.favourite = chatStore.IsFavourite(targetPub, providerPub)
+		}
+		r.pending = c.pendingCount(
+			base64.URLEncoding.EncodeToString(r.publicKey),
+			base64.URLEncoding.EncodeToString(r.providerPublicKey),
+		)
+		sorted = append(sorted, r)
 	}
-	// do not return actual client config as the chosen option might be "refresh"
-	return chosenClientOption, choosableRecipients
+	sort.Slice(sorted, func(i, j int) bool {
+		if sectionI, sectionJ := sectionOrder(sorted[i].section()), sectionOrder(sorted[j].section()); sectionI != sectionJ {
+			return sectionI < sectionJ
+		}
+		return sorted[i].less(sorted[j])
+	})
+	return sorted
 }
 
-// firstly choose our recipient, then create proper gui
-// if this command line chat was to be used further down the line,
-// I would have probably tried to implement the list selection in gocui,
-// but using two frameworks is just way simpler in this case
-func(c *ChatClient) getRecipient() (config.ClientConfig, error) {
-	chosenClientOption := refreshClientOption
-	var clientMapping map[string]config.ClientConfig
-	for chosenClientOption == refreshClientOption {
-		chosenClientOption, clientMapping = c.chooseRecipient()
-		if err := c.mixClient.UpdateNetworkView(); err != nil {
-			return config.ClientConfig{}, err
+func (c *ChatClient) toPickerEntry(r *recipientCandidate) picker.Entry {
+	b64Key := base64.URLEncoding.EncodeToString(r.publicKey)
+	b64ProviderKey := base64.URLEncoding.EncodeToString(r.providerPublicKey)
+
+	favourite := " "
+	if r.favourite {
+		favourite = "*"
+	}
+	name := r.name()
+	if r.alias != "" && c.core.IsVerified(r.publicKey, r.providerPublicKey) {
+		name += core.VerifiedMark
+	}
+	if len([]rune(name)) > nameColumnWidth {
+		name = string([]rune(name)[:nameColumnWidth-1]) + "~"
+	}
+	presence := c.core.Presence(r.publicKey, r.providerPublicKey)
+	label := fmt.Sprintf("%s %-*s %s... @%s...  %s",
+		favourite,
+		nameColumnWidth,
+		name,
+		b64Key[:keyPrefixLength],
+		b64ProviderKey[:keyPrefixLength/2],
+		alias.PresenceString(presence),
+	)
+	if r.pending > 0 {
+		label += fmt.Sprintf(" (%d new)", r.pending)
+	}
+	if r.note != "" {
+		label += fmt.Sprintf(" - %s", r.note)
+	}
+	suggestedName := ""
+	if r.profile != nil {
+		suggestedName = r.profile.Name
+		// the suggested name is only a hint for the clients the user has not named yet
+		if r.alias == "" && suggestedName != "" {
+			label += fmt.Sprintf(" ~%s", suggestedName)
+		}
+		if r.profile.Status != "" {
+			label += fmt.Sprintf(" [%s]", r.profile.Status)
+		}
+	}
+
+	return picker.Entry{
+		ID:       conversationKey(b64Key, b64ProviderKey),
+		Section:  r.section(),
+		Label:    label,
+		Keywords: []string{r.alias, r.note, suggestedName},
+		Prefixes: []string{b64Key, b64ProviderKey},
+		Value:    r,
+	}
+}
+
+// keysFromQuery creates the entry of the client whose keys were pasted as the search query,
+// i.e. "<b64_public_key> <b64_provider_public_key>" or its invite, so that it could be chosen even if it is not listed
+func (c *ChatClient) keysFromQuery(query string) []picker.Entry {
+	var targetPub, providerPub *sphinx.PublicKey
+	suggestedName := ""
+	if invite.IsInvite(query) {
+		inv, err := invite.Parse(query)
+		if err != nil {
+			return nil
+		}
+		targetPub, providerPub, suggestedName = inv.PublicKey, inv.ProviderPublicKey, inv.Name
+	} else {
+		fields := strings.FieldsFunc(query, func(r rune) bool { return r == ' ' || r == '@' })
+		if len(fields) != 2 {
+			return nil
+		}
+		targetPub, providerPub = utils.KeysFromB64Strings(fields[0], fields[1])
+		if targetPub == nil || providerPub == nil {
+			return nil
 		}
 	}
+	candidate := &recipientCandidate{
+		publicKey:         targetPub.Bytes(),
+		providerPublicKey: providerPub.Bytes(),
+	}
+	candidate.alias = c.core.Store().GetAlias(targetPub, providerPub).AssignedName
+	if candidate.alias == "" && suggestedName != "" {
+		// the invite can be added to the contacts with /addcontact once the conversation is open
+		candidate.note = fmt.Sprintf("suggested name: %s", suggestedName)
+	}
+	entry := c.toPickerEntry(candidate)
+	entry.Section = pastedSection
+	return []picker.Entry{entry}
+}
+
+func (c *ChatClient) chooseRecipient(g *gocui.Gui, entry picker.Entry, sessionHalt chan struct{}) error {
+	r := entry.Value.(*recipientCandidate)
+	recipient := r.recipient
+	if recipient == nil {
+		// the client might not be in the directory yet, but its provider might
+		resolved, err := c.core.ResolveRecipient(types.Contact{
+			PublicKey:         base64.URLEncoding.EncodeToString(r.publicKey),
+			ProviderPublicKey: base64.URLEncoding.EncodeToString(r.providerPublicKey),
+		})
+		if err == core.ErrUnknownProvider {
+			return &picker.RetryError{Err: fmt.Errorf("the provider of %s is not present in the network view yet", r.name())}
+		}
+		if err != nil {
+			return err
+		}
+		recipient = &resolved
+	}
+	return c.openConversation(g, *recipient, sessionHalt)
+}
+
+func (c *ChatClient) recipientPickerEntries() []picker.Entry {
+	candidates := c.recipientCandidates()
+	entries := make([]picker.Entry, len(candidates))
+	for i, candidate := range candidates {
+		entries[i] = c.toPickerEntry(candidate)
+	}
+	return entries
+}
 
-	if clientMapping == nil {
-		return config.ClientConfig{}, ErrNoRecipient
+func (c *ChatClient) toggleFavourite(g *gocui.Gui, entry picker.Entry) error {
+	r := entry.Value.(*recipientCandidate)
+	targetPub, providerPub := utils.KeysFromBytes(r.publicKey, r.providerPublicKey)
+	if targetPub == nil || providerPub == nil {
+		return ErrMalformedRecipient
 	}
+	c.core.Store().SetFavourite(targetPub, providerPub, !r.favourite)
+	return nil
+}
 
-	return clientMapping[chosenClientOption], nil
+// newRecipientPicker creates the picker of the recipient, which opens the conversation as soon as one is chosen.
+// The list is refreshed periodically, so it picks up any change of the network view done in the background.
+func (c *ChatClient) newRecipientPicker(sessionHalt chan struct{}) *picker.Picker {
+	return picker.New(picker.Config{
+		Title:     "choose another client to communicate with",
+		Source:    c.recipientPickerEntries,
+		FromQuery: c.keysFromQuery,
+		Reload:    c.core.UpdateNetworkView,
+		Choose: func(g *gocui.Gui, entry picker.Entry) error {
+			return c.chooseRecipient(g, entry, sessionHalt)
+		},
+		Actions: []picker.Action{
+			{
+				Key:         gocui.KeyCtrlF,
+				Description: "Ctrl-F: toggle favourite",
+				Handle:      c.toggleFavourite,
+			},
+		},
+	})
 }
@@ -0,0 +1,434 @@
+// Package chatconfig defines the configuration of the chat application itself,
+// as opposed to the configuration of the base mixnet client it runs on top of.
+package chatconfig
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nymtech/nym-mixnet/logger"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultConfigFileName = "chat.toml"
+
+	defaultStoreDirectory = "chat-application"
+	defaultStoreFile      = "chatstore"
+
+	defaultUnknownSenders  = "hold"
+	defaultMinPollInterval = 10 * time.Millisecond
+	defaultMaxPollInterval = 500 * time.Millisecond
+
+	defaultTimeFormat   = "[15:04:05]"
+	defaultTimeColour   = "white"
+	defaultNoticeColour = "yellow"
+	defaultInfoColour   = "white"
+
+	defaultPresenceRefreshInterval = 30 * time.Second
+
+	defaultSocketFile = "chat.sock"
+
+	defaultLogFile       = "chat.log"
+	defaultLogLevel      = "info"
+	defaultLogMaxSize    = 10
+	defaultLogMaxBackups = 3
+)
+
+//nolint: gochecknoglobals
+var (
+	unknownSendersPolicies = []string{"show", "hold", "drop"}
+
+	colours = map[string]int{
+		"black":   logger.ColorBlack,
+		"red":     logger.ColorRed,
+		"green":   logger.ColorGreen,
+		"yellow":  logger.ColorYellow,
+		"blue":    logger.ColorBlue,
+		"magenta": logger.ColorMagenta,
+		"cyan":    logger.ColorCyan,
+		"white":   logger.ColorWhite,
+	}
+)
+
+// DefaultConfigPath returns path to the chat configuration file located next to the configuration of the base client.
+func DefaultConfigPath(clientConfigPath string) string {
+	return filepath.Join(filepath.Dir(clientConfigPath), defaultConfigFileName)
+}
+
+// Duration is time.Duration that can be written in the config file in its textual form, such as "500ms".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText is an implementation of a method on the
+// TextUnmarshaler interface defined in https://golang.org/pkg/encoding/
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// MarshalText is an implementation of a method on the
+// TextMarshaler interface defined in https://golang.org/pkg/encoding/
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Storage is the configuration of the chat store.
+type Storage struct {
+	// Directory specifies the directory holding all chat-specific data, including the store itself.
+	// If relative, it is resolved against the mixapps directory of the base client.
+	// If absolute, it is shared by all local clients, each of them using its own subdirectory named after its ID.
+	Directory string `toml:"directory"`
+
+	// File specifies the name of the store inside Directory.
+	File string `toml:"file"`
+}
+
+// FullDirectory returns the full path to the chat application directory of the particular client.
+func (cfg *Storage) FullDirectory(mixAppsDir, clientID string) string {
+	if filepath.IsAbs(cfg.Directory) {
+		// so that different identities would never end up with the same store
+		return filepath.Join(cfg.Directory, clientID)
+	}
+	return rootify(cfg.Directory, mixAppsDir)
+}
+
+func (cfg *Storage) applyDefaults() {
+	if len(cfg.Directory) == 0 {
+		cfg.Directory = defaultStoreDirectory
+	}
+	if len(cfg.File) == 0 {
+		cfg.File = defaultStoreFile
+	}
+}
+
+// DefaultStorageConfig returns default storage configuration.
+func DefaultStorageConfig() *Storage {
+	return &Storage{
+		Directory: defaultStoreDirectory,
+		File:      defaultStoreFile,
+	}
+}
+
+// Receiving is the configuration of the handling of received messages.
+type Receiving struct {
+	// UnknownSenders specifies what happens to messages from senders without an alias: "show", "hold" or "drop".
+	UnknownSenders string `toml:"unknown_senders"`
+
+	// MinPollInterval specifies how often the base client is checked for received messages when there is traffic.
+	MinPollInterval Duration `toml:"min_poll_interval"`
+
+	// MaxPollInterval specifies the limit to which the poll interval grows when nothing is being received.
+	MaxPollInterval Duration `toml:"max_poll_interval"`
+}
+
+func (cfg *Receiving) validateAndApplyDefaults() error {
+	if len(cfg.UnknownSenders) == 0 {
+		cfg.UnknownSenders = defaultUnknownSenders
+	}
+	cfg.UnknownSenders = strings.ToLower(cfg.UnknownSenders)
+	if !contains(unknownSendersPolicies, cfg.UnknownSenders) {
+		return fmt.Errorf("config: invalid policy for unknown senders: %s (expected one of: %s)",
+			cfg.UnknownSenders,
+			strings.Join(unknownSendersPolicies, ", "),
+		)
+	}
+
+	if cfg.MinPollInterval.Duration == 0 {
+		cfg.MinPollInterval.Duration = defaultMinPollInterval
+	}
+	if cfg.MaxPollInterval.Duration == 0 {
+		cfg.MaxPollInterval.Duration = defaultMaxPollInterval
+	}
+	if cfg.MinPollInterval.Duration < 0 {
+		return errors.New("config: minimum poll interval must be positive")
+	}
+	if cfg.MaxPollInterval.Duration < cfg.MinPollInterval.Duration {
+		return errors.New("config: maximum poll interval can't be lower than the minimum one")
+	}
+	return nil
+}
+
+// DefaultReceivingConfig returns default configuration of the handling of received messages.
+func DefaultReceivingConfig() *Receiving {
+	return &Receiving{
+		UnknownSenders:  defaultUnknownSenders,
+		MinPollInterval: Duration{defaultMinPollInterval},
+		MaxPollInterval: Duration{defaultMaxPollInterval},
+	}
+}
+
+// Display is the configuration of the terminal UI.
+type Display struct {
+	// TimeFormat specifies the format, in the notation of the time package, of the time displayed next to messages.
+	TimeFormat string `toml:"time_format"`
+
+	// TimeColour specifies the colour of the displayed time.
+	TimeColour string `toml:"time_colour"`
+
+	// NoticeColour specifies the colour of notices, such as reminders and errors.
+	NoticeColour string `toml:"notice_colour"`
+
+	// InfoColour specifies the colour of informational output, such as the results of commands.
+	InfoColour string `toml:"info_colour"`
+
+	// HideHelpOnStart specifies whether the list of available commands should not be shown
+	// at the beginning of every chat session.
+	HideHelpOnStart bool `toml:"hide_help_on_start"`
+}
+
+// ColourCode returns the ANSI code of the provided colour name.
+func (cfg *Display) ColourCode(colour string) int {
+	return colours[colour]
+}
+
+func (cfg *Display) validateAndApplyDefaults() error {
+	if len(cfg.TimeFormat) == 0 {
+		cfg.TimeFormat = defaultTimeFormat
+	}
+
+	for _, colour := range []*string{&cfg.TimeColour, &cfg.NoticeColour, &cfg.InfoColour} {
+		*colour = strings.ToLower(*colour)
+	}
+	if len(cfg.TimeColour) == 0 {
+		cfg.TimeColour = defaultTimeColour
+	}
+	if len(cfg.NoticeColour) == 0 {
+		cfg.NoticeColour = defaultNoticeColour
+	}
+	if len(cfg.InfoColour) == 0 {
+		cfg.InfoColour = defaultInfoColour
+	}
+
+	for _, colour := range []string{cfg.TimeColour, cfg.NoticeColour, cfg.InfoColour} {
+		if _, ok := colours[colour]; !ok {
+			return fmt.Errorf("config: invalid colour: %s (expected one of: %s)", colour, strings.Join(colourNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// DefaultDisplayConfig returns default configuration of the terminal UI.
+func DefaultDisplayConfig() *Display {
+	return &Display{
+		TimeFormat:      defaultTimeFormat,
+		TimeColour:      defaultTimeColour,
+		NoticeColour:    defaultNoticeColour,
+		InfoColour:      defaultInfoColour,
+		HideHelpOnStart: false,
+	}
+}
+
+// Presence is the configuration of tracking which contacts are online.
+type Presence struct {
+	// Disable disables refreshing the network view in the background, so that presence is only updated
+	// whenever it is fetched for other reasons, such as choosing the recipient.
+	Disable bool `toml:"disable"`
+
+	// RefreshInterval specifies how often the network view is fetched from the directory server.
+	RefreshInterval Duration `toml:"refresh_interval"`
+}
+
+func (cfg *Presence) validateAndApplyDefaults() error {
+	if cfg.RefreshInterval.Duration == 0 {
+		cfg.RefreshInterval.Duration = defaultPresenceRefreshInterval
+	}
+	if cfg.RefreshInterval.Duration < time.Second {
+		return errors.New("config: presence refresh interval must be at least a second")
+	}
+	return nil
+}
+
+// DefaultPresenceConfig returns default configuration of tracking which contacts are online.
+func DefaultPresenceConfig() *Presence {
+	return &Presence{
+		Disable:         false,
+		RefreshInterval: Duration{defaultPresenceRefreshInterval},
+	}
+}
+
+// Headless is the configuration of the headless mode.
+type Headless struct {
+	// Socket specifies path to the unix socket of the JSON-RPC API.
+	// If relative, it is resolved against the chat application directory.
+	Socket string `toml:"socket"`
+
+	// MetricsAddress specifies the TCP address, such as "127.0.0.1:9120", on which the traffic statistics
+	// are served at /metrics in the Prometheus text format. The metrics are not served over HTTP if it is empty.
+	MetricsAddress string `toml:"metrics_address"`
+}
+
+// FullSocket returns the full path to the unix socket of the JSON-RPC API.
+func (cfg *Headless) FullSocket(appDir string) string {
+	return rootify(cfg.Socket, appDir)
+}
+
+func (cfg *Headless) applyDefaults() {
+	if len(cfg.Socket) == 0 {
+		cfg.Socket = defaultSocketFile
+	}
+}
+
+// DefaultHeadlessConfig returns default configuration of the headless mode.
+func DefaultHeadlessConfig() *Headless {
+	return &Headless{
+		Socket: defaultSocketFile,
+	}
+}
+
+// Logging is the logging configuration of the chat client.
+// It is independent of the logging of the base mixnet client.
+type Logging struct {
+	// Disable disables logging entirely.
+	Disable bool `toml:"disable"`
+
+	// File specifies the log file. If relative, it is resolved against the chat application directory.
+	File string `toml:"file"`
+
+	// Level specifies the log level.
+	Level string `toml:"level"`
+
+	// MaxSize specifies the size, in megabytes, at which the log file is rotated.
+	MaxSize int `toml:"max_size"`
+
+	// MaxBackups specifies how many of the rotated log files are kept.
+	MaxBackups int `toml:"max_backups"`
+}
+
+// FullFile returns the full path to the log file.
+func (cfg *Logging) FullFile(appDir string) string {
+	return rootify(cfg.File, appDir)
+}
+
+// MaxSizeBytes returns the size, in bytes, at which the log file is rotated.
+func (cfg *Logging) MaxSizeBytes() int64 {
+	return int64(cfg.MaxSize) * 1024 * 1024
+}
+
+func (cfg *Logging) validateAndApplyDefaults() error {
+	if len(cfg.File) == 0 {
+		cfg.File = defaultLogFile
+	}
+	if len(cfg.Level) == 0 {
+		cfg.Level = defaultLogLevel
+	}
+	if _, err := logrus.ParseLevel(cfg.Level); err != nil {
+		return fmt.Errorf("config: invalid logging level: %s (%v)", cfg.Level, err)
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultLogMaxSize
+	}
+	if cfg.MaxSize < 0 {
+		return errors.New("config: maximum log file size must be positive")
+	}
+	if cfg.MaxBackups == 0 {
+		cfg.MaxBackups = defaultLogMaxBackups
+	}
+	if cfg.MaxBackups < 0 {
+		return errors.New("config: number of kept log files must be positive")
+	}
+	return nil
+}
+
+// DefaultLoggingConfig returns default logging configuration.
+func DefaultLoggingConfig() *Logging {
+	return &Logging{
+		Disable:    false,
+		File:       defaultLogFile,
+		Level:      defaultLogLevel,
+		MaxSize:    defaultLogMaxSize,
+		MaxBackups: defaultLogMaxBackups,
+	}
+}
+
+// Config is the top level chat configuration.
+type Config struct {
+	Storage   *Storage   `toml:"storage"`
+	Receiving *Receiving `toml:"receiving"`
+	Display   *Display   `toml:"display"`
+	Presence  *Presence  `toml:"presence"`
+	Headless  *Headless  `toml:"headless"`
+	Logging   *Logging   `toml:"logging"`
+}
+
+// DefaultConfig returns full default chat configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Storage:   DefaultStorageConfig(),
+		Receiving: DefaultReceivingConfig(),
+		Display:   DefaultDisplayConfig(),
+		Presence:  DefaultPresenceConfig(),
+		Headless:  DefaultHeadlessConfig(),
+		Logging:   DefaultLoggingConfig(),
+	}
+}
+
+// Validate checks the configuration, filling any missing values with the defaults.
+// It should be called again whenever the configuration was modified, for example by command line flags.
+func (cfg *Config) Validate() error {
+	if cfg.Storage == nil {
+		cfg.Storage = &Storage{}
+	}
+	cfg.Storage.applyDefaults()
+
+	if cfg.Receiving == nil {
+		cfg.Receiving = &Receiving{}
+	}
+	if err := cfg.Receiving.validateAndApplyDefaults(); err != nil {
+		return err
+	}
+
+	if cfg.Display == nil {
+		cfg.Display = &Display{}
+	}
+	if err := cfg.Display.validateAndApplyDefaults(); err != nil {
+		return err
+	}
+
+	if cfg.Presence == nil {
+		cfg.Presence = &Presence{}
+	}
+	if err := cfg.Presence.validateAndApplyDefaults(); err != nil {
+		return err
+	}
+
+	if cfg.Headless == nil {
+		cfg.Headless = &Headless{}
+	}
+	cfg.Headless.applyDefaults()
+
+	if cfg.Logging == nil {
+		cfg.Logging = DefaultLoggingConfig()
+	}
+	if err := cfg.Logging.validateAndApplyDefaults(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func colourNames() []string {
+	names := make([]string, 0, len(colours))
+	for name := range colours {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,162 @@
+package chatconfig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmptyConfigIsDefault(t *testing.T) {
+	cfg, unknownKeys, err := LoadBinary(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknownKeys) != 0 {
+		t.Errorf("unexpected unknown keys: %v", unknownKeys)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("the empty config is %+v instead of the default one", cfg)
+	}
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("the validation has changed the default config to %+v", cfg)
+	}
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	cfg := DefaultConfig()
+	// the values that need escaping must survive the round trip
+	cfg.Storage.Directory = `C:\Users\"chat"` + "\ttabbed"
+	cfg.Receiving.UnknownSenders = "drop"
+	cfg.Receiving.MaxPollInterval = Duration{2 * time.Second}
+	cfg.Display.TimeColour = "cyan"
+	cfg.Display.HideHelpOnStart = true
+	cfg.Presence.RefreshInterval = Duration{time.Minute}
+	cfg.Headless.MetricsAddress = "127.0.0.1:9120"
+	cfg.Logging.Level = "debug"
+
+	var buffer bytes.Buffer
+	if err := Write(&buffer, cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, unknownKeys, err := LoadBinary(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknownKeys) != 0 {
+		t.Errorf("the written config has unknown keys: %v", unknownKeys)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("loaded %+v instead of %+v", loaded, cfg)
+	}
+}
+
+func TestLoadNormalisesCase(t *testing.T) {
+	cfg, _, err := LoadBinary([]byte(`
+[receiving]
+unknown_senders = "Show"
+
+[display]
+notice_colour = "RED"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Receiving.UnknownSenders != "show" {
+		t.Errorf("the policy for unknown senders is %q instead of %q", cfg.Receiving.UnknownSenders, "show")
+	}
+	if cfg.Display.NoticeColour != "red" {
+		t.Errorf("the notice colour is %q instead of %q", cfg.Display.NoticeColour, "red")
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	_, unknownKeys, err := LoadBinary([]byte(`
+[receiving]
+unknown_senders = "hold"
+unknown_sender = "show"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unknownKeys, []string{"receiving.unknown_sender"}) {
+		t.Errorf("unexpected unknown keys: %v", unknownKeys)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"malformed", `[receiving`},
+		{"invalid duration", "[receiving]\nmin_poll_interval = \"often\""},
+		{"unknown policy", "[receiving]\nunknown_senders = \"ignore\""},
+		{"negative poll interval", "[receiving]\nmin_poll_interval = \"-1s\""},
+		{"poll intervals swapped", "[receiving]\nmin_poll_interval = \"2s\"\nmax_poll_interval = \"1s\""},
+		{"unknown colour", "[display]\ntime_colour = \"purple\""},
+		{"presence refreshed too often", "[presence]\nrefresh_interval = \"500ms\""},
+		{"unknown log level", "[logging]\nlevel = \"verbose\""},
+		{"negative log size", "[logging]\nmax_size = -1"},
+		{"negative log backups", "[logging]\nmax_backups = -1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := LoadBinary([]byte(test.config)); err == nil {
+				t.Error("the invalid config has been accepted")
+			}
+		})
+	}
+}
+
+func TestLoadFileOrDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chatconfig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, defaultConfigFileName)
+
+	cfg, _, err := LoadFileOrDefault(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("the missing config is %+v instead of the default one", cfg)
+	}
+
+	cfg.Logging.Disable = true
+	if err := WriteConfigFile(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := LoadFileOrDefault(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Logging.Disable {
+		t.Error("the written config has not been loaded")
+	}
+}
+
+func TestFullPaths(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "mixapps")
+	storage := DefaultStorageConfig()
+	if dir := storage.FullDirectory(root, "alice"); dir != filepath.Join(root, defaultStoreDirectory) {
+		t.Errorf("the relative store directory is %v", dir)
+	}
+	storage.Directory = filepath.Join(string(filepath.Separator), "shared")
+	if dir := storage.FullDirectory(root, "alice"); !strings.HasSuffix(dir, filepath.Join("shared", "alice")) {
+		t.Errorf("the absolute store directory is %v, not specific to the client", dir)
+	}
+}
@@ -0,0 +1,194 @@
+package chatconfig
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//nolint: gochecknoglobals
+var configTemplate = template.Must(template.New("chatConfigTemplate").
+	Funcs(template.FuncMap{"quote": quote}).
+	Parse(defaultConfigTemplate))
+
+// quote renders the value as a TOML basic string, so that values containing quotes, backslashes
+// or control characters, such as windows paths, are written back exactly as they were loaded
+func quote(v interface{}) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range fmt.Sprint(v) {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// LoadBinary loads, parses and validates the provided buffer b (as a config) and returns the Config
+// alongside all keys that are not recognised.
+func LoadBinary(b []byte) (*Config, []string, error) {
+	cfg := new(Config)
+	meta, err := toml.Decode(string(b), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	undecoded := meta.Undecoded()
+	unknownKeys := make([]string, len(undecoded))
+	for i, key := range undecoded {
+		unknownKeys[i] = key.String()
+	}
+
+	return cfg, unknownKeys, nil
+}
+
+// LoadFile loads, parses and validates the provided file and returns the Config
+// alongside all keys that are not recognised.
+func LoadFile(f string) (*Config, []string, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	return LoadBinary(b)
+}
+
+// LoadFileOrDefault behaves like LoadFile, but returns the default config if the file does not exist.
+func LoadFileOrDefault(f string) (*Config, []string, error) {
+	if _, err := os.Stat(f); os.IsNotExist(err) {
+		return DefaultConfig(), nil, nil
+	}
+	return LoadFile(f)
+}
+
+// Write renders config using the template, i.e. with all options documented.
+func Write(w io.Writer, config *Config) error {
+	return configTemplate.Execute(w, config)
+}
+
+// WriteConfigFile renders config using the template and writes it to specified file path.
+func WriteConfigFile(path string, config *Config) error {
+	var buffer bytes.Buffer
+
+	if err := Write(&buffer, config); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+func rootify(path, root string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// Note: any changes to the template must be reflected in the appropriate structs and tags.
+const defaultConfigTemplate = `# This is a TOML config file of the chat client.
+# For more information, see https://github.com/toml-lang/toml
+
+##### chat store options #####
+[storage]
+
+# Directory holding all chat-specific data, including the store itself.
+# If relative, it is resolved against the mixapps directory of the base client.
+# If absolute, it is shared by all local clients, each of them using its own subdirectory named after its ID.
+directory = {{ quote .Storage.Directory }}
+
+# Name of the store inside the directory.
+file = {{ quote .Storage.File }}
+
+##### received messages options #####
+[receiving]
+
+# What happens to messages from senders without an alias. The available options include:
+# show - display them as any other message
+# hold - keep them in the requests inbox until the sender is accepted or ignored
+# drop - silently discard them
+unknown_senders = {{ quote .Receiving.UnknownSenders }}
+
+# How often the base client is checked for received messages when there is traffic.
+min_poll_interval = {{ quote .Receiving.MinPollInterval }}
+
+# The limit to which the poll interval grows when nothing is being received.
+# It is also the longest delay it adds to the delivery of the first message after a quiet period.
+max_poll_interval = {{ quote .Receiving.MaxPollInterval }}
+
+##### terminal UI options #####
+[display]
+
+# Format of the time displayed next to messages, written as the reference time
+# Mon Jan 2 15:04:05 MST 2006 would be, see https://golang.org/pkg/time/#pkg-constants
+time_format = {{ quote .Display.TimeFormat }}
+
+# Colours of the displayed time, notices and informational output. The available options include:
+# black, red, green, yellow, blue, magenta, cyan, white
+time_colour = {{ quote .Display.TimeColour }}
+notice_colour = {{ quote .Display.NoticeColour }}
+info_colour = {{ quote .Display.InfoColour }}
+
+# Whether to skip showing the list of available commands at the beginning of every chat session.
+hide_help_on_start = {{ .Display.HideHelpOnStart }}
+
+##### presence options #####
+[presence]
+
+# Whether to stop refreshing the network view in the background. Presence of the contacts
+# is then only updated whenever the view is fetched anyway, e.g. when choosing the recipient.
+disable = {{ .Presence.Disable }}
+
+# How often the network view is fetched from the directory server to find out who is online.
+refresh_interval = {{ quote .Presence.RefreshInterval }}
+
+##### headless mode options #####
+[headless]
+
+# Path to the unix socket of the JSON-RPC API.
+# If relative, it is resolved against the chat application directory.
+socket = {{ quote .Headless.Socket }}
+
+# The TCP address, such as "127.0.0.1:9120", on which the traffic statistics are served at /metrics
+# in the Prometheus text format. Leave it empty to not serve them over HTTP at all.
+metrics_address = {{ quote .Headless.MetricsAddress }}
+
+##### logging options #####
+[logging]
+
+# Whether to disable logging entirely.
+disable = {{ .Logging.Disable }}
+
+# The log file. If relative, it is resolved against the chat application directory.
+file = {{ quote .Logging.File }}
+
+# The logging level of the chat client. The available options include:
+# trace, debug, info, warning, error, panic, fatal
+level = {{ quote .Logging.Level }}
+
+# The size, in megabytes, at which the log file is rotated.
+max_size = {{ .Logging.MaxSize }}
+
+# How many of the rotated log files are kept.
+max_backups = {{ .Logging.MaxBackups }}
+`
@@ -0,0 +1,100 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/trust"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/core"
+	"github.com/nymtech/demo-mixnet-chat-client/invite"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"os"
+	"strings"
+)
+
+// validateAliasAnswer makes sure the alias typed in by the user can be used in the commands
+func validateAliasAnswer(answer interface{}) error {
+	name, _ := answer.(string)
+	switch {
+	case name == "":
+		return errors.New("the alias can't be empty")
+	case len(strings.Fields(name)) != 1 || strings.TrimSpace(name) != name:
+		return errors.New("the alias can't contain any whitespace")
+	case !alias.IsValidName(name) || invite.IsInvite(name):
+		return errors.New("this name is reserved")
+	}
+	return nil
+}
+
+// importInvite verifies the invite and stores its client as a contact. Unless told otherwise, the user is asked
+// for the alias of the contact, with the name suggested by the invite as the default.
+// It terminates the process if the invite can't be imported.
+func importInvite(chatClient *core.Client, encoded string, interactive bool) {
+	inv, err := invite.Parse(encoded)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid invite: %v\n", err)
+		os.Exit(1)
+	}
+	if bytes.Equal(inv.PublicKey.Bytes(), chatClient.PublicKey()) {
+		fmt.Fprintf(os.Stderr, "Invalid invite: it is your own\n")
+		os.Exit(1)
+	}
+
+	b64Key := base64.URLEncoding.EncodeToString(inv.PublicKey.Bytes())
+	b64ProviderKey := base64.URLEncoding.EncodeToString(inv.ProviderPublicKey.Bytes())
+	existing := chatClient.LookupAlias(inv.PublicKey.Bytes(), inv.ProviderPublicKey.Bytes())
+
+	if change := chatClient.CheckClaimedName(inv.Name, inv.PublicKey.Bytes(), inv.ProviderPublicKey.Bytes(), types.KeySourceInvite); change != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", trust.Describe(change))
+		if !interactive {
+			fmt.Fprintf(os.Stderr, "Refusing to add the contact without asking, run the client and type /trust to review it\n")
+			os.Exit(1)
+		}
+	}
+
+	name := inv.SuggestedAlias()
+	if interactive {
+		fmt.Fprintf(os.Stdout, "Verified invite of %s (provider %s)\n", b64Key, b64ProviderKey)
+		if existing != nil && existing.AssignedName != "" {
+			fmt.Fprintf(os.Stdout, "The client is already known as %s\n", existing.AssignedName)
+			name = existing.AssignedName
+		}
+		prompt := &survey.Input{
+			Message: "Choose the alias of the contact:",
+			Default: name,
+		}
+		if err := survey.AskOne(prompt, &name, survey.WithValidator(validateAliasAnswer)); err != nil {
+			os.Exit(1)
+		}
+	} else if err := validateAliasAnswer(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't use the suggested name '%s' of the invite as the alias: %v\n", inv.Name, err)
+		os.Exit(1)
+	}
+
+	if err := chatClient.AddAlias(types.Contact{
+		Alias:             name,
+		PublicKey:         b64Key,
+		ProviderPublicKey: b64ProviderKey,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not add the contact: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stdout, "Added %s to the contacts\n", name)
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package commands
+
+import (
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/chatconfig"
+	"os"
+)
+
+const (
+	showConfigSubcommand = "show"
+)
+
+//nolint: lll
+func ConfigCmd(args []string, usage string) {
+	opts := newOpts("config [OPTIONS] show", usage)
+	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client whose chat configuration we want to see", defaultID)
+	customConfigPath := opts.Flags("--customCfg").Label("CUSTOMCFG").String("Path to custom configuration file of the mixnet client", "")
+	chatConfigPath := opts.Flags("--chatCfg").Label("CHATCFG").String("Path to the configuration file of the chat client (default: chat.toml next to the configuration file of the mixnet client)", "")
+
+	params := opts.Parse(args)
+	if len(params) != 1 || params[0] != showConfigSubcommand {
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+
+	chatCfg := loadChatConfig(clientConfigPath(*id, *customConfigPath), *chatConfigPath)
+	if err := chatconfig.Write(os.Stdout, chatCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not show the chat configuration: %v\n", err)
+		os.Exit(1)
+	}
+}
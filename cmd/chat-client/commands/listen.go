@@ -0,0 +1,130 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/groups"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/trust"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const (
+	textFormat = "text"
+	jsonFormat = "json"
+)
+
+//nolint: lll
+func ListenCmd(args []string, usage string) {
+	opts := newOpts("listen [OPTIONS]", usage)
+	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client we will use to listen", defaultID)
+	customConfigPath := opts.Flags("--customCfg").Label("CUSTOMCFG").String("Path to custom configuration file of the mixnet client", "")
+	chatConfigPath := opts.Flags("--chatCfg").Label("CHATCFG").String("Path to the configuration file of the chat client (default: chat.toml next to the configuration file of the mixnet client)", "")
+	format := opts.Flags("--format").Label("FORMAT").String("Output format of received messages: text or json", textFormat)
+	unknownSenders := opts.Flags("--unknownSenders").Label("POLICY").String("What to do with messages from senders without an alias: show, hold or drop (default: as set in the chat configuration)", "")
+
+	params := opts.Parse(args)
+	if len(params) != 0 || (*format != textFormat && *format != jsonFormat) {
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+
+	cfgPath := clientConfigPath(*id, *customConfigPath)
+	cfg := loadClientConfig(cfgPath)
+	chatCfg := loadChatConfig(cfgPath, *chatConfigPath)
+
+	if len(*unknownSenders) > 0 {
+		chatCfg.Receiving.UnknownSenders = *unknownSenders
+		if err := chatCfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid policy for unknown senders: %v\n", *unknownSenders)
+			os.Exit(1)
+		}
+	}
+
+	chatClient, err := newQuietChatClient(cfg, chatCfg)
+	if err != nil {
+		panic(err)
+	}
+
+	received, unsubscribe := chatClient.Subscribe()
+	if err := chatClient.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run client instance: %v\n", err)
+		os.Exit(-1)
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		unsubscribe()
+		chatClient.Shutdown()
+	}()
+
+	encoder := json.NewEncoder(os.Stdout)
+	// the channel is closed once we unsubscribe
+	for r := range received {
+		if r.Kind == types.ReceivedPresence {
+			if r.Presence.Online {
+				fmt.Fprintf(os.Stderr, "%s is now online\n", r.Presence.Alias)
+			}
+			continue
+		}
+		if r.Kind == types.ReceivedKeyChange {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", trust.Describe(r.KeyChange))
+			continue
+		}
+		if r.Kind == types.ReceivedProfile {
+			name := r.Profile.Alias
+			if name == "" {
+				name = r.Profile.PublicKey[:8] + "..."
+			}
+			if suggested := alias.SuggestedName(r.Profile.Name); suggested != "" && suggested != r.Profile.Alias {
+				fmt.Fprintf(os.Stderr, "%s would like to be called %q\n", name, r.Profile.Name)
+			}
+			if r.Profile.Status != "" {
+				fmt.Fprintf(os.Stderr, "%s's status: %s\n", name, r.Profile.Status)
+			}
+			continue
+		}
+		if r.Kind == types.ReceivedGroupChange {
+			fmt.Fprint(os.Stderr, groups.DescribeChange(r.GroupChange, base64.URLEncoding.EncodeToString(chatClient.PublicKey())))
+			continue
+		}
+		msg := r.Message
+		if r.Kind == types.ReceivedContactRequest {
+			// keep the stdout for the actual messages only
+			fmt.Fprintf(os.Stderr, "New contact %s is trying to reach you\n", msg.Sender)
+			continue
+		}
+		if *format == jsonFormat {
+			if err := encoder.Encode(msg); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write the message: %v\n", err)
+			}
+		} else if r.Kind == types.ReceivedGroupMessage && r.Group != nil {
+			fmt.Fprintf(os.Stdout, "%s [%s] %s: %s\n", msg.SentAt.Format(chatCfg.Display.TimeFormat), r.Group.Name, msg.Sender, strings.TrimRight(msg.Content, "\n"))
+		} else {
+			fmt.Fprintf(os.Stdout, "%s %s: %s\n", msg.SentAt.Format(chatCfg.Display.TimeFormat), msg.Sender, strings.TrimRight(msg.Content, "\n"))
+		}
+	}
+
+	chatClient.Wait()
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/nymtech/demo-mixnet-chat-client/profiles"
+	clientConfig "github.com/nymtech/nym-mixnet/client/config"
+	"os"
+)
+
+const (
+	listProfilesSubcommand = "list"
+	copyContactsSubcommand = "copy-contacts"
+)
+
+//nolint: lll
+func ProfilesCmd(args []string, usage string) {
+	opts := newOpts("profiles [OPTIONS] [list | copy-contacts]", usage)
+	from := opts.Flags("--from").Label("ID").String("Id of the client whose contacts are going to be copied", "")
+	to := opts.Flags("--to").Label("ID").String("Id of the client receiving the contacts", "")
+	overwrite := opts.Flags("--overwrite").Label("OVERWRITE").Bool("Replace the names of contacts already known by the receiving client")
+
+	params := opts.Parse(args)
+	if len(params) == 0 {
+		params = []string{listProfilesSubcommand}
+	}
+	if len(params) != 1 {
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+
+	switch params[0] {
+	case listProfilesSubcommand:
+		listProfiles()
+	case copyContactsSubcommand:
+		if len(*from) == 0 || len(*to) == 0 {
+			opts.PrintUsage()
+			os.Exit(1)
+		}
+		copyContacts(*from, *to, *overwrite)
+	default:
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+}
+
+func listProfiles() {
+	ids, err := profiles.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list the profiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, id := range ids {
+		b64Key := "<unknown public key>"
+		if configPath, err := clientConfig.DefaultConfigPath(id); err == nil {
+			if cfg, err := clientConfig.LoadFile(configPath); err == nil {
+				if pubKey, err := profiles.PublicKey(cfg); err == nil {
+					b64Key = base64.URLEncoding.EncodeToString(pubKey.Bytes())
+				}
+			}
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", id, b64Key)
+	}
+}
+
+func copyContacts(from, to string, overwrite bool) {
+	if from == to {
+		fmt.Fprintf(os.Stderr, "Could not copy the contacts: %v\n", profiles.ErrSameProfile)
+		os.Exit(1)
+	}
+
+	fromCfgPath := clientConfigPath(from, "")
+	fromCfg := loadClientConfig(fromCfgPath)
+	fromStore, err := profiles.OpenStore(fromCfg, loadChatConfig(fromCfgPath, ""))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open the chat store of %v (is it running?): %v\n", from, err)
+		os.Exit(1)
+	}
+	defer fromStore.Close()
+
+	toCfgPath := clientConfigPath(to, "")
+	toCfg := loadClientConfig(toCfgPath)
+	toStore, err := profiles.OpenStore(toCfg, loadChatConfig(toCfgPath, ""))
+	if err != nil {
+		fromStore.Close()
+		fmt.Fprintf(os.Stderr, "Could not open the chat store of %v (is it running?): %v\n", to, err)
+		os.Exit(1)
+	}
+	defer toStore.Close()
+
+	// it is not an error if the key can't be loaded, at worst the client is going to have itself as a contact
+	toPubKey, _ := profiles.PublicKey(toCfg)
+
+	copied, skipped := profiles.CopyContacts(fromStore, toStore, toPubKey, overwrite)
+	fmt.Fprintf(os.Stdout, "Copied %d contact(s) from %v to %v, skipped %d\n", copied, from, to, skipped)
+}
+
+// chooseProfile lets the user pick one of the local clients if there is more than one of them.
+// It returns an empty string if there is nothing to choose from.
+func chooseProfile() string {
+	ids, err := profiles.List()
+	if err != nil || len(ids) == 0 {
+		return ""
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+
+	var chosenID string
+	prompt := &survey.Select{
+		Message: "Choose the identity to run the client as:",
+		Options: ids,
+	}
+	if err := survey.AskOne(prompt, &chosenID, nil); err != nil {
+		os.Exit(1)
+	}
+	return chosenID
+}
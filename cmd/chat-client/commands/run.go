@@ -17,10 +17,15 @@ package commands
 import (
 	"fmt"
 	"github.com/nymtech/demo-mixnet-chat-client/chat-client"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/core"
+	"github.com/nymtech/demo-mixnet-chat-client/chatconfig"
+	"github.com/nymtech/demo-mixnet-chat-client/daemon"
 	clientConfig "github.com/nymtech/nym-mixnet/client/config"
 	"github.com/nymtech/nym-mixnet/helpers"
 	"github.com/tav/golly/optparse"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 const (
@@ -30,8 +35,14 @@ const (
 //nolint: lll
 func RunCmd(args []string, usage string) {
 	opts := newOpts("run [OPTIONS]", usage)
-	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client we will use to run", defaultID)
+	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client we will use to run (default: chosen interactively if there are multiple local clients)", "")
 	customConfigPath := opts.Flags("--customCfg").Label("CUSTOMCFG").String("Path to custom configuration file of the mixnet client", "")
+	chatConfigPath := opts.Flags("--chatCfg").Label("CHATCFG").String("Path to the configuration file of the chat client (default: chat.toml next to the configuration file of the mixnet client)", "")
+	headless := opts.Flags("--headless").Label("HEADLESS").Bool("Run without the terminal UI and expose a JSON-RPC API on a unix socket instead")
+	socketPath := opts.Flags("--socket").Label("SOCKET").String("Path to the unix socket of the JSON-RPC API in the headless mode (default: as set in the chat configuration)", "")
+	logLevel := opts.Flags("--log-level").Label("LEVEL").String("Verbosity of the chat client log: trace, debug, info, warning, error, panic or fatal (default: as set in the chat configuration)", "")
+	addContact := opts.Flags("--addContact").Label("INVITE").String("Invite of the client to add to the contacts before starting, the alias is asked for unless running headless", "")
+	unknownSenders := opts.Flags("--unknownSenders").Label("POLICY").String("What to do with messages from senders without an alias: show, hold or drop (default: as set in the chat configuration)", "")
 
 	params := opts.Parse(args)
 	if len(params) != 0 {
@@ -39,17 +50,73 @@ func RunCmd(args []string, usage string) {
 		os.Exit(1)
 	}
 
-	var configPath string
-	var err error
-	if len(*customConfigPath) > 0 {
-		configPath = *customConfigPath
-	} else {
-		configPath, err = clientConfig.DefaultConfigPath(*id)
-		if err != nil {
-			panic(err)
+	if len(*id) == 0 && len(*customConfigPath) == 0 {
+		// there is nobody to ask in the headless mode
+		if !*headless {
+			*id = chooseProfile()
+		}
+		if len(*id) == 0 {
+			*id = defaultID
 		}
 	}
 
+	cfgPath := clientConfigPath(*id, *customConfigPath)
+	cfg := loadClientConfig(cfgPath)
+	chatCfg := loadChatConfig(cfgPath, *chatConfigPath)
+
+	// flags take precedence over the configuration file
+	if len(*unknownSenders) > 0 {
+		chatCfg.Receiving.UnknownSenders = *unknownSenders
+	}
+	if len(*socketPath) > 0 {
+		chatCfg.Headless.Socket = *socketPath
+	}
+	if len(*logLevel) > 0 {
+		chatCfg.Logging.Level = *logLevel
+	}
+	if err := chatCfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid chat configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	chatCore, err := core.New(cfg, chatCfg)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(*addContact) > 0 {
+		// there is nobody to ask in the headless mode, so the suggested name has to do
+		importInvite(chatCore, *addContact, !*headless)
+	}
+
+	if *headless {
+		runHeadless(chatCore, chatCfg.Headless.FullSocket(chatCore.AppDir()), chatCfg.Headless.MetricsAddress)
+		return
+	}
+
+	chatClient := chat_client.New(chatCore, chatCfg.Display)
+	if err := chatClient.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run client instance: %v\n", err)
+		os.Exit(-1)
+	}
+
+	chatClient.Wait()
+}
+
+// clientConfigPath returns the path to the configuration file of the base mixnet client
+func clientConfigPath(id, customConfigPath string) string {
+	if len(customConfigPath) > 0 {
+		return customConfigPath
+	}
+	configPath, err := clientConfig.DefaultConfigPath(id)
+	if err != nil {
+		panic(err)
+	}
+	return configPath
+}
+
+// loadClientConfig loads the configuration of the base mixnet client or terminates the process if it is not possible
+func loadClientConfig(configPath string) *clientConfig.Config {
 	cfgExists, err := helpers.DirExists(configPath)
 	if !cfgExists || err != nil {
 		fmt.Fprintf(os.Stderr, "The configuration file at %v does not seem to exist\n", configPath)
@@ -61,17 +128,83 @@ func RunCmd(args []string, usage string) {
 		fmt.Fprintf(os.Stderr, "Could not load the config file: %v\n", err)
 		os.Exit(1)
 	}
+	return cfg
+}
 
-	chatClient, err := chat_client.New(cfg)
+// loadChatConfig loads the chat configuration, either from the explicitly provided path,
+// or from the default location next to the configuration of the base client.
+// Only the explicitly provided file is required to exist, otherwise the defaults are used.
+// Unknown keys are reported, but do not prevent the client from running.
+func loadChatConfig(clientConfigPath, chatConfigPath string) *chatconfig.Config {
+	var cfg *chatconfig.Config
+	var unknownKeys []string
+	var err error
+	if len(chatConfigPath) > 0 {
+		cfg, unknownKeys, err = chatconfig.LoadFile(chatConfigPath)
+	} else {
+		chatConfigPath = chatconfig.DefaultConfigPath(clientConfigPath)
+		cfg, unknownKeys, err = chatconfig.LoadFileOrDefault(chatConfigPath)
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Could not load the chat config file: %v\n", err)
+		os.Exit(1)
 	}
 
-	if err := chatClient.Run(); err != nil {
+	for _, key := range unknownKeys {
+		fmt.Fprintf(os.Stderr, "Unknown key '%v' in %v is ignored\n", key, chatConfigPath)
+	}
+	return cfg
+}
+
+// newQuietChatClient creates the chat client while keeping the stdout clean,
+// as the base mixnet client always prints our public key on creation.
+// It is used by the commands whose output is meant to be consumed by other programs.
+func newQuietChatClient(cfg *clientConfig.Config, chatCfg *chatconfig.Config) (*core.Client, error) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	return core.New(cfg, chatCfg)
+}
+
+func runHeadless(chatClient *core.Client, socketPath string, metricsAddress string) {
+	if err := chatClient.Start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to run client instance: %v\n", err)
 		os.Exit(-1)
 	}
 
+	server, err := daemon.NewServer(chatClient, socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the API server: %v\n", err)
+		chatClient.Shutdown()
+		os.Exit(-1)
+	}
+	server.Start()
+	fmt.Fprintf(os.Stdout, "Listening for JSON-RPC requests on %v\n", socketPath)
+
+	var metricsServer *daemon.MetricsServer
+	if len(metricsAddress) > 0 {
+		metricsServer, err = daemon.NewMetricsServer(chatClient, metricsAddress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start the metrics server: %v\n", err)
+			server.Shutdown()
+			chatClient.Shutdown()
+			os.Exit(-1)
+		}
+		metricsServer.Start()
+		fmt.Fprintf(os.Stdout, "Serving metrics on http://%v/metrics\n", metricsServer.Addr())
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		if metricsServer != nil {
+			metricsServer.Shutdown()
+		}
+		server.Shutdown()
+		chatClient.Shutdown()
+	}()
+
 	chatClient.Wait()
 }
 
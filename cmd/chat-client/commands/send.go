@@ -0,0 +1,89 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// how long we wait for the message to be written to the provider before giving up
+	sendTimeout = time.Minute
+)
+
+//nolint: lll
+func SendCmd(args []string, usage string) {
+	opts := newOpts("send [OPTIONS] <message>", usage)
+	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client we will use to send the message", defaultID)
+	customConfigPath := opts.Flags("--customCfg").Label("CUSTOMCFG").String("Path to custom configuration file of the mixnet client", "")
+	chatConfigPath := opts.Flags("--chatCfg").Label("CHATCFG").String("Path to the configuration file of the chat client (default: chat.toml next to the configuration file of the mixnet client)", "")
+	to := opts.Flags("--to").Label("ALIAS").String("Alias of the recipient", "")
+	toKey := opts.Flags("--toKey").Label("KEY").String("Base64 public key of the recipient, used instead of the alias", "")
+	toProviderKey := opts.Flags("--toProviderKey").Label("KEY").String("Base64 public key of the recipient's provider, used instead of the alias", "")
+	unknownSenders := opts.Flags("--unknownSenders").Label("POLICY").String("What to do with messages from senders without an alias received in the meantime: show, hold or drop (default: as set in the chat configuration)", "")
+
+	params := opts.Parse(args)
+	if len(params) == 0 || (len(*to) == 0 && (len(*toKey) == 0 || len(*toProviderKey) == 0)) {
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+
+	cfgPath := clientConfigPath(*id, *customConfigPath)
+	cfg := loadClientConfig(cfgPath)
+	chatCfg := loadChatConfig(cfgPath, *chatConfigPath)
+
+	if len(*unknownSenders) > 0 {
+		chatCfg.Receiving.UnknownSenders = *unknownSenders
+		if err := chatCfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid policy for unknown senders: %v\n", *unknownSenders)
+			os.Exit(1)
+		}
+	}
+
+	chatClient, err := newQuietChatClient(cfg, chatCfg)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := chatClient.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run client instance: %v\n", err)
+		os.Exit(-1)
+	}
+	defer chatClient.Shutdown()
+
+	recipient := types.Contact{
+		Alias:             *to,
+		PublicKey:         *toKey,
+		ProviderPublicKey: *toProviderKey,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := chatClient.Send(ctx, recipient, strings.Join(params, " ")); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send the message: %v\n", err)
+		chatClient.Shutdown()
+		os.Exit(1)
+	}
+	// the transport only picks up the message, so make sure it is written to the provider before tearing everything down
+	if err := chatClient.Flush(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send the message: %v\n", err)
+		chatClient.Shutdown()
+		os.Exit(1)
+	}
+}
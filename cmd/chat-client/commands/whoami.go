@@ -0,0 +1,78 @@
+// Copyright 2019 The Loopix-Messaging Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/gui/qr"
+	"github.com/nymtech/demo-mixnet-chat-client/invite"
+	"github.com/nymtech/demo-mixnet-chat-client/profiles"
+	"os"
+)
+
+//nolint: lll
+func WhoamiCmd(args []string, usage string) {
+	opts := newOpts("whoami [OPTIONS]", usage)
+	id := opts.Flags("--id").Label("ID").String("Id of the loopix-mixnet-client whose details are shown", defaultID)
+	customConfigPath := opts.Flags("--customCfg").Label("CUSTOMCFG").String("Path to custom configuration file of the mixnet client", "")
+	name := opts.Flags("--name").Label("NAME").String("Name suggested to the ones adding us with the invite", "")
+	showQR := opts.Flags("--qr").Label("QR").Bool("Draw the invite as a QR code, to be scanned with a phone")
+
+	params := opts.Parse(args)
+	if len(params) != 0 {
+		opts.PrintUsage()
+		os.Exit(1)
+	}
+
+	// the keys are read from the configuration, so it works even if the client is running
+	cfg := loadClientConfig(clientConfigPath(*id, *customConfigPath))
+	pubKey, err := profiles.PublicKey(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load the public key: %v\n", err)
+		os.Exit(1)
+	}
+	providerPubKey, err := profiles.ProviderPublicKey(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load the public key of the provider: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoded, err := (&invite.Invite{
+		PublicKey:         pubKey,
+		ProviderPublicKey: providerPubKey,
+		Name:              *name,
+	}).Encode()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create the invite: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stdout, "Public key:          %s\n", base64.URLEncoding.EncodeToString(pubKey.Bytes()))
+	fmt.Fprintf(os.Stdout, "Provider public key: %s\n", base64.URLEncoding.EncodeToString(providerPubKey.Bytes()))
+	fmt.Fprintf(os.Stdout, "Invite:              %s\n", encoded)
+
+	if *showQR {
+		lines, err := qr.Render(encoded)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create the QR code: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stdout)
+		for _, line := range lines {
+			fmt.Fprintf(os.Stdout, "%s%s%s\n", qr.Colours, line, qr.ResetColours)
+		}
+	}
+}
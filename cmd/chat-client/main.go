@@ -31,12 +31,22 @@ func main() {
                                                                                        
 		  `
 	cmds := map[string]func([]string, string){
-		"run":  cmd.RunCmd,
-		"init": loopix_cmd.InitCmd,
+		"run":      cmd.RunCmd,
+		"init":     loopix_cmd.InitCmd,
+		"send":     cmd.SendCmd,
+		"listen":   cmd.ListenCmd,
+		"config":   cmd.ConfigCmd,
+		"profiles": cmd.ProfilesCmd,
+		"whoami":   cmd.WhoamiCmd,
 	}
 	info := map[string]string{
-		"run":  "Run a persistent demo-chat client process",
-		"init": "Initialise a base Loopix client",
+		"run":      "Run a persistent demo-chat client process",
+		"init":     "Initialise a base Loopix client",
+		"send":     "Send a single message and exit",
+		"listen":   "Stream received messages to stdout, one per line",
+		"config":   "Show the effective configuration of the chat client",
+		"profiles": "List local identities or copy contacts between them",
+		"whoami":   "Show our keys and invite, optionally as a QR code",
 	}
 	optparse.Commands("demo-mixnet-chat-client", "0.0.2", cmds, info, logo)
 }
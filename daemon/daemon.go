@@ -0,0 +1,573 @@
+// Package daemon exposes the chat client running in the headless mode over a local JSON-RPC 2.0 API.
+// Requests and responses are newline-delimited JSON objects exchanged over a unix socket.
+// After calling `chat.subscribe`, the connection additionally receives `chat.message` notifications
+// for every message delivered to the client, `chat.request` notifications for new contact requests,
+// which can be handled with `requests.accept` and `requests.ignore`,
+// and `contacts.presence` notifications whenever a contact comes online or goes offline.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	jsonRPCVersion = "2.0"
+
+	MethodSend            = "chat.send"
+	MethodSubscribe       = "chat.subscribe"
+	MethodUnsubscribe     = "chat.unsubscribe"
+	MethodListContacts    = "contacts.list"
+	MethodListDirectory   = "directory.list"
+	MethodAddAlias        = "aliases.add"
+	MethodRemoveAlias     = "aliases.remove"
+	MethodGetHistory      = "history.get"
+	MethodGetStats        = "stats.get"
+	MethodGetMetrics      = "stats.prometheus"
+	MethodListPresence    = "presence.list"
+	MethodListEndpoints   = "contacts.endpoints"
+	MethodMergeContacts   = "contacts.merge"
+	MethodPreferEndpoint  = "contacts.prefer"
+	MethodListKeyChanges  = "trust.list"
+	MethodAcceptKeyChange = "trust.accept"
+	MethodGetProfile      = "profile.get"
+	MethodSetProfile      = "profile.set"
+	MethodAcceptName      = "profile.acceptName"
+	MethodListGroups      = "groups.list"
+	MethodCreateGroup     = "groups.create"
+	MethodAddGroupMember  = "groups.add"
+	MethodLeaveGroup      = "groups.leave"
+	MethodSendToGroup     = "groups.send"
+	MethodGetGroupHistory = "groups.history"
+	MethodKickGroupMember = "groups.kick"
+	MethodBanGroupMember  = "groups.ban"
+	MethodPromoteMember   = "groups.promote"
+	MethodRenameGroup     = "groups.rename"
+	MethodListRequests    = "requests.list"
+	MethodAcceptRequest   = "requests.accept"
+	MethodIgnoreRequest   = "requests.ignore"
+
+	NotificationMessage   = "chat.message"
+	NotificationRequest   = "chat.request"
+	NotificationPresence  = "contacts.presence"
+	NotificationKeyChange = "contacts.keychange"
+	NotificationProfile   = "contacts.profile"
+	NotificationGroupMsg  = "groups.message"
+	NotificationGroup     = "groups.change"
+
+	// error codes as defined by the JSON-RPC 2.0 specification
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// anything returned by the backend itself
+	codeBackendError = -32000
+
+	maxRequestSize = 1 << 20
+)
+
+var (
+	// how long a subscribed client has to read a notification before it is disconnected,
+	// as the backend does not deliver anything further until every subscriber has accepted it
+	notificationWriteTimeout = 10 * time.Second
+)
+
+var (
+	ErrAlreadySubscribed = errors.New("the connection is already subscribed")
+	ErrNoSuchKeyChange   = errors.New("there is no key change with given number")
+)
+
+// Backend is the set of operations of the chat client that is exposed via the API
+type Backend interface {
+	Send(ctx context.Context, target types.Contact, content string) error
+	Subscribe() (<-chan *types.Received, func())
+	Contacts() []*types.Contact
+	Directory() ([]*types.Contact, error)
+	AddAlias(target types.Contact) error
+	RemoveAlias(target types.Contact) error
+	History(target types.Contact) ([]*types.Message, error)
+	Stats() *types.Stats
+	ContactsPresence() []*types.Presence
+	Endpoints(target types.Contact) ([]*types.Endpoint, error)
+	MergeContacts(into, from types.Contact) error
+	PreferEndpoint(endpoint types.Contact) error
+	KeyChanges() []*types.KeyChange
+	AcceptKeyChange(change *types.KeyChange) error
+	Profile() *types.Profile
+	SetProfile(name, status string) error
+	AcceptSuggestedName(target types.Contact) (string, error)
+	Groups() []*types.Group
+	CreateGroup(name string) (*types.Group, error)
+	// AddGroupMember returns false if the contact is only going to be added once its signing key arrives
+	AddGroupMember(groupID string, target types.Contact) (bool, error)
+	LeaveGroup(groupID string) error
+	SendToGroup(ctx context.Context, groupID string, content string) error
+	GroupHistory(groupID string) ([]*types.Message, error)
+	KickGroupMember(groupID string, target types.Contact) error
+	BanGroupMember(groupID string, target types.Contact) error
+	PromoteGroupMember(groupID string, target types.Contact) error
+	RenameGroup(groupID string, name string) error
+	Requests() []*types.ContactRequest
+	AcceptRequest(target types.Contact) error
+	IgnoreRequest(target types.Contact) error
+}
+
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type sendParams struct {
+	To      types.Contact `json:"to"`
+	Content string        `json:"content"`
+}
+
+type mergeParams struct {
+	Into types.Contact `json:"into"`
+	From types.Contact `json:"from"`
+}
+
+type profileParams struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// groups are referred to by their IDs, as their names do not have to be unique
+type groupParams struct {
+	Group   string        `json:"group"`
+	Name    string        `json:"name,omitempty"`
+	Member  types.Contact `json:"member,omitempty"`
+	Content string        `json:"content,omitempty"`
+}
+
+// the contact is only added to the group once its signing key arrives, unless it has sent it to us before
+type addMemberResult struct {
+	Pending bool `json:"pending"`
+}
+
+// key changes are referred to by their position in the result of trust.list, starting from 1
+type acceptKeyChangeParams struct {
+	Number int `json:"number"`
+}
+
+// Server accepts API connections on the unix socket.
+type Server struct {
+	backend    Backend
+	socketPath string
+	listener   net.Listener
+	wg         sync.WaitGroup
+	haltedCh   chan struct{}
+	haltOnce   sync.Once
+}
+
+// connection represents a single API client
+type connection struct {
+	server      *Server
+	conn        net.Conn
+	writeMu     sync.Mutex
+	encoder     *json.Encoder
+	unsubscribe func()
+}
+
+func (c *connection) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.encoder.Encode(v)
+}
+
+// writeNotification writes the notification, giving up once the write deadline passes
+func (c *connection) writeNotification(n *notification) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(notificationWriteTimeout)); err != nil {
+		return err
+	}
+	defer c.conn.SetWriteDeadline(time.Time{})
+	return c.encoder.Encode(n)
+}
+
+func (c *connection) writeResult(id *json.RawMessage, result interface{}) error {
+	// notifications, i.e. requests without id, are not answered
+	if id == nil {
+		return nil
+	}
+	return c.write(&response{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Result:  result,
+	})
+}
+
+func (c *connection) writeError(id *json.RawMessage, code int, err error) error {
+	// failed notifications are not answered either
+	if id == nil {
+		return nil
+	}
+	return c.writeErrorResponse(id, code, err)
+}
+
+// writeErrorResponse answers even the requests without id, as it is used for the ones
+// that could not be parsed, for which the id is unknown
+func (c *connection) writeErrorResponse(id *json.RawMessage, code int, err error) error {
+	return c.write(&response{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Error: &responseError{
+			Code:    code,
+			Message: err.Error(),
+		},
+	})
+}
+
+func (c *connection) subscribe() error {
+	if c.unsubscribe != nil {
+		return ErrAlreadySubscribed
+	}
+	received, unsubscribe := c.server.backend.Subscribe()
+	c.unsubscribe = unsubscribe
+	go func() {
+		// channel is closed by the backend once unsubscribed
+		for r := range received {
+			var params interface{} = r.Message
+			method := NotificationMessage
+			switch r.Kind {
+			case types.ReceivedContactRequest:
+				method = NotificationRequest
+			case types.ReceivedPresence:
+				method = NotificationPresence
+				params = r.Presence
+			case types.ReceivedKeyChange:
+				method = NotificationKeyChange
+				params = r.KeyChange
+			case types.ReceivedProfile:
+				method = NotificationProfile
+				params = r.Profile
+			case types.ReceivedGroupMessage:
+				method = NotificationGroupMsg
+				params = r.Message
+			case types.ReceivedGroupChange:
+				method = NotificationGroup
+				params = r.GroupChange
+			}
+			if err := c.writeNotification(&notification{
+				JSONRPC: jsonRPCVersion,
+				Method:  method,
+				Params:  params,
+			}); err != nil {
+				// the client has stopped reading, so it must not hold up the backend any longer
+				unsubscribe()
+				c.conn.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *connection) stopSubscription() {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+		c.unsubscribe = nil
+	}
+}
+
+func (c *connection) handleRequest(req *request) error {
+	backend := c.server.backend
+	switch req.Method {
+	case MethodSend:
+		var params sendParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		if err := backend.Send(context.Background(), params.To, params.Content); err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, true)
+
+	case MethodSubscribe:
+		if err := c.subscribe(); err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, true)
+
+	case MethodUnsubscribe:
+		c.stopSubscription()
+		return c.writeResult(req.ID, true)
+
+	case MethodListContacts:
+		return c.writeResult(req.ID, backend.Contacts())
+
+	case MethodListDirectory:
+		contacts, err := backend.Directory()
+		if err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, contacts)
+
+	case MethodGetStats:
+		return c.writeResult(req.ID, backend.Stats())
+
+	case MethodGetMetrics:
+		// the result is a single string in the Prometheus text exposition format
+		return c.writeResult(req.ID, formatPrometheus(backend.Stats()))
+
+	case MethodListPresence:
+		return c.writeResult(req.ID, backend.ContactsPresence())
+
+	case MethodMergeContacts:
+		var params mergeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		if err := backend.MergeContacts(params.Into, params.From); err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, true)
+
+	case MethodListKeyChanges:
+		return c.writeResult(req.ID, backend.KeyChanges())
+
+	case MethodAcceptKeyChange:
+		var params acceptKeyChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		changes := backend.KeyChanges()
+		if params.Number < 1 || params.Number > len(changes) {
+			return c.writeError(req.ID, codeInvalidParams, ErrNoSuchKeyChange)
+		}
+		if err := backend.AcceptKeyChange(changes[params.Number-1]); err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, true)
+
+	case MethodGetProfile:
+		return c.writeResult(req.ID, backend.Profile())
+
+	case MethodSetProfile:
+		var params profileParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		if err := backend.SetProfile(params.Name, params.Status); err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, true)
+
+	case MethodListGroups:
+		return c.writeResult(req.ID, backend.Groups())
+
+	case MethodCreateGroup, MethodAddGroupMember, MethodLeaveGroup, MethodSendToGroup, MethodGetGroupHistory,
+		MethodKickGroupMember, MethodBanGroupMember, MethodPromoteMember, MethodRenameGroup:
+		var params groupParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		var result interface{} = true
+		var err error
+		switch req.Method {
+		case MethodCreateGroup:
+			result, err = backend.CreateGroup(params.Name)
+		case MethodAddGroupMember:
+			var added bool
+			added, err = backend.AddGroupMember(params.Group, params.Member)
+			result = &addMemberResult{Pending: !added}
+		case MethodLeaveGroup:
+			err = backend.LeaveGroup(params.Group)
+		case MethodSendToGroup:
+			err = backend.SendToGroup(context.Background(), params.Group, params.Content)
+		case MethodGetGroupHistory:
+			result, err = backend.GroupHistory(params.Group)
+		case MethodKickGroupMember:
+			err = backend.KickGroupMember(params.Group, params.Member)
+		case MethodBanGroupMember:
+			err = backend.BanGroupMember(params.Group, params.Member)
+		case MethodPromoteMember:
+			err = backend.PromoteGroupMember(params.Group, params.Member)
+		case MethodRenameGroup:
+			err = backend.RenameGroup(params.Group, params.Name)
+		}
+		if err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, result)
+
+	case MethodListRequests:
+		return c.writeResult(req.ID, backend.Requests())
+
+	case MethodAddAlias, MethodRemoveAlias, MethodGetHistory, MethodListEndpoints, MethodPreferEndpoint, MethodAcceptName,
+		MethodAcceptRequest, MethodIgnoreRequest:
+		var target types.Contact
+		if err := json.Unmarshal(req.Params, &target); err != nil {
+			return c.writeError(req.ID, codeInvalidParams, err)
+		}
+		var result interface{} = true
+		var err error
+		switch req.Method {
+		case MethodAddAlias:
+			err = backend.AddAlias(target)
+		case MethodRemoveAlias:
+			err = backend.RemoveAlias(target)
+		case MethodGetHistory:
+			result, err = backend.History(target)
+		case MethodListEndpoints:
+			result, err = backend.Endpoints(target)
+		case MethodPreferEndpoint:
+			err = backend.PreferEndpoint(target)
+		case MethodAcceptName:
+			result, err = backend.AcceptSuggestedName(target)
+		case MethodAcceptRequest:
+			err = backend.AcceptRequest(target)
+		case MethodIgnoreRequest:
+			err = backend.IgnoreRequest(target)
+		}
+		if err != nil {
+			return c.writeError(req.ID, codeBackendError, err)
+		}
+		return c.writeResult(req.ID, result)
+
+	default:
+		return c.writeError(req.ID, codeMethodNotFound, errors.New("method not found"))
+	}
+}
+
+func (c *connection) serve() {
+	defer c.server.wg.Done()
+	defer c.conn.Close()
+	defer c.stopSubscription()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	for scanner.Scan() {
+		req := &request{}
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			if c.writeErrorResponse(nil, codeParseError, err) != nil {
+				return
+			}
+			continue
+		}
+		if req.JSONRPC != jsonRPCVersion || req.Method == "" {
+			if c.writeErrorResponse(req.ID, codeInvalidRequest, errors.New("invalid request")) != nil {
+				return
+			}
+			continue
+		}
+		if err := c.handleRequest(req); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) acceptConnections() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.haltedCh:
+				return
+			default:
+				continue
+			}
+		}
+		c := &connection{
+			server:  s,
+			conn:    conn,
+			encoder: json.NewEncoder(conn),
+		}
+		s.wg.Add(1)
+		go c.serve()
+	}
+}
+
+// Start starts accepting connections in the background.
+func (s *Server) Start() {
+	s.wg.Add(1)
+	go s.acceptConnections()
+}
+
+// Shutdown stops accepting new connections and removes the socket.
+// Already established connections are closed once their clients disconnect.
+func (s *Server) Shutdown() {
+	s.haltOnce.Do(func() {
+		close(s.haltedCh)
+		s.listener.Close()
+		os.Remove(s.socketPath)
+	})
+}
+
+// listenPrivately creates the unix socket only our user can connect to. The socket is created within a temporary
+// directory nobody else can enter, where its permissions are restricted before it is moved to the specified path,
+// so that there is no moment at which anybody else could connect to it.
+func listenPrivately(socketPath string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(socketPath), ".socket")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, filepath.Base(socketPath))
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by Shutdown under its final path
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, socketPath); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// NewServer creates new instance of the API server listening on the specified unix socket,
+// which only our user can connect to. Any stale socket file left at the path is removed first.
+func NewServer(backend Backend, socketPath string) (*Server, error) {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := listenPrivately(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		backend:    backend,
+		socketPath: socketPath,
+		listener:   listener,
+		haltedCh:   make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBackend implements only the operations the tests use, anything else panics
+type testBackend struct {
+	Backend
+	sent         chan sendParams
+	received     chan *types.Received
+	unsubscribed chan struct{}
+	unsubOnce    sync.Once
+}
+
+func newTestBackend() *testBackend {
+	return &testBackend{
+		sent:         make(chan sendParams, 1),
+		received:     make(chan *types.Received),
+		unsubscribed: make(chan struct{}),
+	}
+}
+
+func (b *testBackend) Send(ctx context.Context, target types.Contact, content string) error {
+	b.sent <- sendParams{To: target, Content: content}
+	return nil
+}
+
+func (b *testBackend) Subscribe() (<-chan *types.Received, func()) {
+	return b.received, func() { b.unsubOnce.Do(func() { close(b.unsubscribed) }) }
+}
+
+// deliver blocks until the notification is taken or the subscriber is gone, just like the chat client does
+func (b *testBackend) deliver(received *types.Received) bool {
+	select {
+	case b.received <- received:
+		return true
+	case <-b.unsubscribed:
+		return false
+	}
+}
+
+func startTestServer(t *testing.T, backend Backend) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "daemon-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(dir, "chat.sock")
+	server, err := NewServer(backend, socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	server.Start()
+	return socketPath, func() {
+		server.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func call(t *testing.T, conn net.Conn, reader *bufio.Reader, method string, params interface{}) *response {
+	t.Helper()
+	id := json.RawMessage(`1`)
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewEncoder(conn).Encode(&request{JSONRPC: jsonRPCVersion, ID: &id, Method: method, Params: rawParams}); err != nil {
+		t.Fatal(err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &response{}
+	if err := json.Unmarshal(line, resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSocketIsPrivate(t *testing.T) {
+	socketPath, shutdown := startTestServer(t, newTestBackend())
+	defer shutdown()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("the socket has mode %v", info.Mode())
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(socketPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("the temporary directory of the socket has been left behind: %v entries", len(entries))
+	}
+}
+
+func TestSendAndSubscribe(t *testing.T) {
+	backend := newTestBackend()
+	socketPath, shutdown := startTestServer(t, backend)
+	defer shutdown()
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	to := types.Contact{Alias: "bob"}
+	if resp := call(t, conn, reader, MethodSend, sendParams{To: to, Content: "hello"}); resp.Error != nil || resp.Result != true {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if sent := <-backend.sent; sent.To != to || sent.Content != "hello" {
+		t.Errorf("sent %+v", sent)
+	}
+
+	if resp := call(t, conn, reader, MethodSubscribe, nil); resp.Error != nil || resp.Result != true {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	go backend.deliver(&types.Received{Kind: types.ReceivedMessage, Message: &types.Message{Sender: "bob", Content: "hi"}})
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received struct {
+		Method string         `json:"method"`
+		Params *types.Message `json:"params"`
+	}
+	if err := json.Unmarshal(line, &received); err != nil {
+		t.Fatal(err)
+	}
+	if received.Method != NotificationMessage || received.Params == nil || received.Params.Content != "hi" {
+		t.Errorf("unexpected notification %s", line)
+	}
+}
+
+func TestSubscriberNotReadingIsDisconnected(t *testing.T) {
+	defer func(timeout time.Duration) { notificationWriteTimeout = timeout }(notificationWriteTimeout)
+	notificationWriteTimeout = 100 * time.Millisecond
+
+	backend := newTestBackend()
+	socketPath, shutdown := startTestServer(t, backend)
+	defer shutdown()
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if resp := call(t, conn, bufio.NewReader(conn), MethodSubscribe, nil); resp.Error != nil {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	// nothing is read from now on, so the socket buffers fill up and the writes block
+	content := strings.Repeat("x", 64*1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for backend.deliver(&types.Received{Kind: types.ReceivedMessage, Message: &types.Message{Content: content}}) {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscriber that stopped reading is still holding up the backend")
+	}
+}
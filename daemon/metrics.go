@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	metricsPath        = "/metrics"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// scrapes are tiny, there is no reason for anybody to hold the connection for long
+	metricsTimeout = 10 * time.Second
+)
+
+// StatsSource is the part of the chat client the metrics are read from
+type StatsSource interface {
+	Stats() *types.Stats
+}
+
+// MetricsServer exposes the traffic statistics over HTTP in the Prometheus text format, so they can be scraped directly.
+type MetricsServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+func metricsHandler(source StatsSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", metricsContentType)
+		io.WriteString(w, formatPrometheus(source.Stats())) //nolint: errcheck
+	}
+}
+
+// Addr returns the address the server is actually listening on, which matters if the port was chosen by the system.
+func (m *MetricsServer) Addr() net.Addr {
+	return m.listener.Addr()
+}
+
+// Start starts serving the scrapes in the background.
+func (m *MetricsServer) Start() {
+	go m.server.Serve(m.listener) //nolint: errcheck
+}
+
+// Shutdown stops the server, closing all of its connections.
+func (m *MetricsServer) Shutdown() {
+	m.server.Close()
+}
+
+// NewMetricsServer creates new instance of the metrics server listening on the specified TCP address.
+func NewMetricsServer(source StatsSource, address string) (*MetricsServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	m := &MetricsServer{listener: listener}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metricsHandler(source))
+	m.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  metricsTimeout,
+		WriteTimeout: metricsTimeout,
+	}
+	return m, nil
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"strings"
+	"time"
+)
+
+const (
+	metricsPrefix = "chat_"
+)
+
+//nolint: gochecknoglobals
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(*types.ContactStats) float64
+}
+
+//nolint: gochecknoglobals
+var contactMetrics = []metric{
+	{"messages_sent_total", "Number of messages sent to the contact.", "counter",
+		func(s *types.ContactStats) float64 { return float64(s.MessagesSent) }},
+	{"messages_received_total", "Number of messages received from the contact.", "counter",
+		func(s *types.ContactStats) float64 { return float64(s.MessagesReceived) }},
+	{"send_failures_total", "Number of messages that could not be sent to the contact.", "counter",
+		func(s *types.ContactStats) float64 { return float64(s.FailedSends) }},
+	{"sent_bytes_total", "Size of all messages sent to the contact.", "counter",
+		func(s *types.ContactStats) float64 { return float64(s.BytesSent) }},
+	{"received_bytes_total", "Size of all messages received from the contact.", "counter",
+		func(s *types.ContactStats) float64 { return float64(s.BytesReceived) }},
+	{"send_duration_avg_seconds", "Average time it took for messages to the contact to be picked up by the transport.", "gauge",
+		func(s *types.ContactStats) float64 { return s.AvgSendDuration.Seconds() }},
+	{"one_way_delay_min_seconds", "Minimum one-way delay of received messages. Clock-dependent, as it includes the offset of the sender's clock, negative samples are clamped to zero.", "gauge",
+		func(s *types.ContactStats) float64 { return s.MinDelay.Seconds() }},
+	{"one_way_delay_avg_seconds", "Average one-way delay of received messages. Clock-dependent, as it includes the offset of the sender's clock, negative samples are clamped to zero.", "gauge",
+		func(s *types.ContactStats) float64 { return s.AvgDelay.Seconds() }},
+	{"one_way_delay_extra_avg_seconds", "Average one-way delay of received messages over the minimum one, independent of the clock offset.", "gauge",
+		func(s *types.ContactStats) float64 { return s.AvgExtraDelay.Seconds() }},
+}
+
+func contactLabels(s *types.ContactStats) string {
+	return fmt.Sprintf(`{contact="%s",public_key="%s",provider_public_key="%s"}`,
+		labelValueEscaper.Replace(s.Alias),
+		labelValueEscaper.Replace(s.PublicKey),
+		labelValueEscaper.Replace(s.ProviderPublicKey),
+	)
+}
+
+// formatPrometheus renders the statistics in the Prometheus text exposition format
+func formatPrometheus(stats *types.Stats) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# HELP %suptime_seconds Time since the chat client was started.\n", metricsPrefix)
+	fmt.Fprintf(&buf, "# TYPE %suptime_seconds gauge\n", metricsPrefix)
+	fmt.Fprintf(&buf, "%suptime_seconds %g\n", metricsPrefix, stats.Uptime.Round(time.Millisecond).Seconds())
+
+	for _, m := range contactMetrics {
+		fmt.Fprintf(&buf, "# HELP %s%s %s\n", metricsPrefix, m.name, m.help)
+		fmt.Fprintf(&buf, "# TYPE %s%s %s\n", metricsPrefix, m.name, m.kind)
+		for _, contactStats := range stats.Contacts {
+			fmt.Fprintf(&buf, "%s%s%s %g\n", metricsPrefix, m.name, contactLabels(contactStats), m.value(contactStats))
+		}
+	}
+	return buf.String()
+}
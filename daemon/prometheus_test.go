@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testStatsSource struct {
+	stats *types.Stats
+}
+
+func (s *testStatsSource) Stats() *types.Stats {
+	return s.stats
+}
+
+func testStats() *types.Stats {
+	return &types.Stats{
+		Uptime: 90*time.Second + 1234*time.Microsecond,
+		Contacts: []*types.ContactStats{{
+			Contact: types.Contact{
+				Alias:             `al"ice\` + "\n",
+				PublicKey:         "pub",
+				ProviderPublicKey: "provider",
+			},
+			MessagesSent:     3,
+			MessagesReceived: 2,
+			BytesSent:        1500,
+			AvgSendDuration:  250 * time.Millisecond,
+			MinDelay:         2 * time.Second,
+		}},
+	}
+}
+
+func TestFormatPrometheus(t *testing.T) {
+	formatted := formatPrometheus(testStats())
+	labels := `{contact="al\"ice\\\n",public_key="pub",provider_public_key="provider"}`
+	for _, expected := range []string{
+		"# TYPE chat_uptime_seconds gauge\nchat_uptime_seconds 90.001\n",
+		"# TYPE chat_messages_sent_total counter\nchat_messages_sent_total" + labels + " 3\n",
+		"chat_messages_received_total" + labels + " 2\n",
+		"chat_send_failures_total" + labels + " 0\n",
+		"chat_sent_bytes_total" + labels + " 1500\n",
+		"chat_send_duration_avg_seconds" + labels + " 0.25\n",
+		"# TYPE chat_one_way_delay_min_seconds gauge\nchat_one_way_delay_min_seconds" + labels + " 2\n",
+	} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("%q is missing from:\n%s", expected, formatted)
+		}
+	}
+	// every sample is on its own line, even with the line break in the alias
+	for _, line := range strings.Split(strings.TrimSuffix(formatted, "\n"), "\n") {
+		if !strings.HasPrefix(line, "# ") && !strings.HasPrefix(line, metricsPrefix) {
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+
+	// the metrics are described even without any contacts
+	if empty := formatPrometheus(&types.Stats{}); !strings.Contains(empty, "# TYPE chat_messages_sent_total counter\n") {
+		t.Errorf("the metrics are not described without any contacts:\n%s", empty)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	server := httptest.NewServer(metricsHandler(&testStatsSource{stats: testStats()}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != metricsContentType {
+		t.Errorf("got status %v and content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if string(body) != formatPrometheus(testStats()) {
+		t.Errorf("unexpected metrics:\n%s", body)
+	}
+
+	resp, err = http.Post(server.URL+metricsPath, "text/plain", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Errorf("got status %v and allowed methods %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
@@ -0,0 +1,124 @@
+// Package fingerprint computes safety numbers, which let two users confirm out of band,
+// for example by reading them aloud, that they really talk to each other rather than to somebody in the middle.
+// The number is made of the public keys of both clients and is the same whichever of them computes it.
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	version = 0
+	// hashing the key many times makes it expensive to find another key with the same fingerprint
+	iterations = 5200
+	// the digits of each of the keys are split into groups of 5, each made of 5 bytes of its fingerprint
+	groupsPerKey  = 6
+	bytesPerGroup = 5
+	groupModulus  = 100000
+	// the number of words, each one made of a single byte of the combined fingerprint
+	wordsCount = 8
+
+	groupsPerLine = 4
+)
+
+// SafetyNumber identifies the pair of clients talking to each other
+type SafetyNumber struct {
+	// Digits are the groups of 5 digits, the ones of the lower key go first
+	Digits []string
+	// Words are the shorter, easier to read aloud, alternative to the digits
+	Words []string
+}
+
+// keyFingerprint iteratively hashes the key, so that the digits derived from it could not be easily matched by other keys
+func keyFingerprint(key []byte) []byte {
+	versionBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(versionBytes, version)
+
+	hash := sha512.Sum512(append(versionBytes, key...))
+	for i := 1; i < iterations; i++ {
+		hash = sha512.Sum512(append(hash[:], key...))
+	}
+	return hash[:groupsPerKey*bytesPerGroup]
+}
+
+func digitGroups(fingerprint []byte) []string {
+	groups := make([]string, groupsPerKey)
+	for i := range groups {
+		chunk := make([]byte, 8)
+		copy(chunk[8-bytesPerGroup:], fingerprint[i*bytesPerGroup:(i+1)*bytesPerGroup])
+		groups[i] = fmt.Sprintf("%05d", binary.BigEndian.Uint64(chunk)%groupModulus)
+	}
+	return groups
+}
+
+// Compute returns the safety number of the conversation between the clients with given public keys.
+func Compute(ourKey, theirKey []byte) *SafetyNumber {
+	first, second := ourKey, theirKey
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	firstFingerprint, secondFingerprint := keyFingerprint(first), keyFingerprint(second)
+
+	combined := sha256.Sum256(append(append([]byte{}, firstFingerprint...), secondFingerprint...))
+	words := make([]string, wordsCount)
+	for i := range words {
+		words[i] = wordList[combined[i]]
+	}
+
+	return &SafetyNumber{
+		Digits: append(digitGroups(firstFingerprint), digitGroups(secondFingerprint)...),
+		Words:  words,
+	}
+}
+
+// DigitsString formats the digits in lines of 4 groups, which are easier to compare
+func (s *SafetyNumber) DigitsString() string {
+	lines := make([]string, 0, len(s.Digits)/groupsPerLine+1)
+	for i := 0; i < len(s.Digits); i += groupsPerLine {
+		end := i + groupsPerLine
+		if end > len(s.Digits) {
+			end = len(s.Digits)
+		}
+		lines = append(lines, strings.Join(s.Digits[i:end], " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WordsString returns the words separated by spaces
+func (s *SafetyNumber) WordsString() string {
+	return strings.Join(s.Words, " ")
+}
+
+// wordList has exactly 256 words, one for every value of a byte. No two of them start with the same three letters.
+//nolint: gochecknoglobals
+var wordList = [256]string{
+	"acid", "acorn", "actor", "adult", "agent", "alarm", "album", "alert", "alley", "amber", "angle", "ankle",
+	"apple", "apron", "arena", "armor", "arrow", "atlas", "attic", "aunt", "autumn", "award", "bacon", "badge",
+	"bagel", "baker", "bamboo", "banjo", "barn", "basil", "beach", "beetle", "bell", "bench", "berry", "bike",
+	"bison", "blade", "blossom", "board", "bonus", "boot", "bottle", "brain", "bread", "brick", "broom",
+	"bucket", "buffalo", "bunny", "butter", "cabin", "cactus", "camel", "candle", "carpet", "castle", "cattle",
+	"cedar", "chalk", "cheese", "chimney", "cider", "cinema", "circus", "claw", "cliff", "clock", "coach",
+	"cobra", "cocoa", "comet", "copper", "coral", "cotton", "cougar", "crane", "cricket", "crown", "cube",
+	"cupcake", "curtain", "cushion", "daisy", "dancer", "denim", "desert", "diamond", "dinner", "dolphin",
+	"donkey", "dragon", "drum", "eagle", "echo", "eclipse", "elbow", "emerald", "engine", "falcon", "feather",
+	"fence", "ferry", "fiddle", "finger", "flame", "flute", "forest", "fossil", "fountain", "fox", "garden",
+	"geyser", "giant", "ginger", "glacier", "globe", "goat", "gold", "grape", "guitar", "hammer", "harbor",
+	"hazel", "helmet", "heron", "hockey", "honey", "hornet", "hotel", "iceberg", "igloo", "island", "ivory",
+	"jacket", "jaguar", "jasmine", "jelly", "jewel", "jungle", "kayak", "kettle", "kitten", "kiwi", "koala",
+	"ladder", "lagoon", "lamp", "lantern", "lemon", "leopard", "lettuce", "lily", "lion", "lizard", "lobster",
+	"magnet", "mango", "maple", "marble", "meadow", "melon", "mirror", "mitten", "monkey", "moose", "mosaic",
+	"motor", "muffin", "mustard", "napkin", "needle", "nest", "noodle", "nugget", "oasis", "ocean", "olive",
+	"onion", "orange", "orbit", "orchid", "otter", "owl", "paddle", "palace", "panda", "parrot", "peach",
+	"pebble", "pencil", "pepper", "piano", "pigeon", "pillow", "pirate", "planet", "plum", "pocket", "pony",
+	"poppy", "potato", "puzzle", "quartz", "quilt", "rabbit", "radar", "raven", "rocket", "rose", "ruby",
+	"saddle", "salmon", "satin", "scarf", "shadow", "shell", "silver", "sketch", "sled", "socket", "spider",
+	"spoon", "squid", "stamp", "sugar", "summit", "sunset", "swan", "table", "tango", "tiger", "toast",
+	"tomato", "topaz", "tractor", "trumpet", "tulip", "tunnel", "turtle", "umbrella", "valley", "velvet",
+	"violin", "volcano", "wagon", "walnut", "whale", "willow", "window", "wizard", "wolf", "yacht", "yogurt",
+	"zebra", "zipper",
+}
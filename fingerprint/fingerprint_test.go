@@ -0,0 +1,86 @@
+package fingerprint
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name         string
+		ourKey       []byte
+		theirKey     []byte
+		digits       []string
+		words        []string
+		digitsString string
+		wordsString  string
+	}{
+		{
+			name:         "ones and twos",
+			ourKey:       bytes.Repeat([]byte{1}, 32),
+			theirKey:     bytes.Repeat([]byte{2}, 32),
+			digits:       []string{"50446", "49183", "98409", "82398", "85123", "08684", "42361", "52097", "11848", "60812", "34684", "67577"},
+			words:        []string{"wizard", "shell", "cattle", "walnut", "bike", "whale", "moose", "atlas"},
+			digitsString: "50446 49183 98409 82398\n85123 08684 42361 52097\n11848 60812 34684 67577",
+			wordsString:  "wizard shell cattle walnut bike whale moose atlas",
+		},
+		{
+			name:         "zeros and ones",
+			ourKey:       make([]byte, 32),
+			theirKey:     bytes.Repeat([]byte{0xff}, 32),
+			digits:       []string{"58586", "75596", "60150", "02931", "72414", "24798", "08490", "64311", "06310", "57364", "07313", "45318"},
+			words:        []string{"yacht", "turtle", "sketch", "scarf", "rocket", "arena", "sled", "jewel"},
+			digitsString: "58586 75596 60150 02931\n72414 24798 08490 64311\n06310 57364 07313 45318",
+			wordsString:  "yacht turtle sketch scarf rocket arena sled jewel",
+		},
+	}
+	for _, test := range tests {
+		// both sides of the conversation have to see the same number
+		for _, number := range []*SafetyNumber{Compute(test.ourKey, test.theirKey), Compute(test.theirKey, test.ourKey)} {
+			if !reflect.DeepEqual(number.Digits, test.digits) {
+				t.Errorf("%s: got digits %q instead of %q", test.name, number.Digits, test.digits)
+			}
+			if !reflect.DeepEqual(number.Words, test.words) {
+				t.Errorf("%s: got words %q instead of %q", test.name, number.Words, test.words)
+			}
+			if number.DigitsString() != test.digitsString {
+				t.Errorf("%s: got digits %q instead of %q", test.name, number.DigitsString(), test.digitsString)
+			}
+			if number.WordsString() != test.wordsString {
+				t.Errorf("%s: got words %q instead of %q", test.name, number.WordsString(), test.wordsString)
+			}
+		}
+	}
+}
+
+func TestDifferentKeysDifferentNumbers(t *testing.T) {
+	ourKey, theirKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	otherKey := append(bytes.Repeat([]byte{2}, 31), 3)
+	number, other := Compute(ourKey, theirKey), Compute(ourKey, otherKey)
+	if reflect.DeepEqual(number.Digits[len(number.Digits)/2:], other.Digits[len(other.Digits)/2:]) {
+		t.Error("the digits of different keys are the same")
+	}
+	if reflect.DeepEqual(number.Words, other.Words) {
+		t.Error("the words of different pairs of keys are the same")
+	}
+	// the digits of our key stay the same whoever we talk to
+	if !reflect.DeepEqual(number.Digits[:len(number.Digits)/2], other.Digits[:len(other.Digits)/2]) {
+		t.Error("the digits of our key depend on the other key")
+	}
+}
+
+func TestWordList(t *testing.T) {
+	prefixes := make(map[string]string)
+	for _, word := range wordList {
+		if len(word) < 3 || strings.ToLower(word) != word {
+			t.Errorf("%q is not a lowercase word of at least 3 letters", word)
+			continue
+		}
+		if previous, ok := prefixes[word[:3]]; ok {
+			t.Errorf("%q and %q start with the same three letters", previous, word)
+		}
+		prefixes[word[:3]] = word
+	}
+}
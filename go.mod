@@ -4,6 +4,7 @@ go 1.12
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.0.4
+	github.com/BurntSushi/toml v0.3.1
 	github.com/golang/protobuf v1.3.2
 	github.com/jroimartin/gocui v0.4.0
 	github.com/mattn/go-colorable v0.1.4 // indirect
@@ -11,7 +12,8 @@ require (
 	github.com/nsf/termbox-go v0.0.0-20190817171036-93860e161317 // indirect
 	github.com/nymtech/nym-mixnet v0.1.5
 	github.com/sirupsen/logrus v1.4.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/syndtr/goleveldb v1.0.0
 	github.com/tav/golly v0.0.0-20180823113506-ad032321f11e
-	golang.org/x/crypto v0.0.0-20191002192127-34f69633bfdc // indirect
+	golang.org/x/crypto v0.0.0-20191002192127-34f69633bfdc
 )
@@ -5,17 +5,47 @@ import (
 	"github.com/jroimartin/gocui"
 	"github.com/nymtech/demo-mixnet-chat-client/gui/layout"
 	"github.com/nymtech/nym-mixnet/logger"
+	"sync"
 	"time"
 )
 
 const (
 	defaultNoticePrefix = "NOTICE"
+	warningPrefix       = "WARNING"
 )
 
+// Style defines how the content is presented in the messages view
+type Style struct {
+	// TimeFormat is the layout of the time displayed next to messages and notices
+	TimeFormat string
+	// TimeColour, NoticeColour and InfoColour are the ANSI colour codes of the respective parts of the output
+	TimeColour   int
+	NoticeColour int
+	InfoColour   int
+}
+
+//nolint: gochecknoglobals
+var style = Style{
+	TimeFormat:   layout.TimeFormatting,
+	TimeColour:   logger.ColorWhite,
+	NoticeColour: logger.ColorYellow,
+	InfoColour:   logger.ColorWhite,
+}
+
+// SetStyle changes the style of all guis. It should be called before any gui is created.
+func SetStyle(newStyle Style) {
+	style = newStyle
+}
+
 func initControlKeybindings(g *gocui.Gui) error {
 	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); err != nil {
 		return err
 	}
+	for _, key := range []gocui.Key{gocui.KeyEsc, gocui.KeyEnter} {
+		if err := g.SetKeybinding(layout.OverlayViewName, key, gocui.ModNone, layout.HideOverlay); err != nil {
+			return err
+		}
+	}
 
 	// switch between views with tab
 	// allow scrolling with arrows
@@ -47,8 +77,8 @@ func WriteMessage(msg, senderID string, g *gocui.Gui) {
 		currentTime := time.Now()
 
 		formattedTime := fmt.Sprintf("\x1b[%dm%s\x1b[0m",
-			logger.ColorWhite,
-			currentTime.Format(layout.TimeFormatting),
+			style.TimeColour,
+			currentTime.Format(style.TimeFormat),
 		)
 
 		formattedSender := fmt.Sprintf("\x1b[1m%s:\x1b[0m",
@@ -70,6 +100,19 @@ func WriteMessage(msg, senderID string, g *gocui.Gui) {
 }
 
 func WriteNotice(content string, g *gocui.Gui, noticePrefix ...string) {
+	noticeText := defaultNoticePrefix
+	if len(noticePrefix) == 1 {
+		noticeText = noticePrefix[0]
+	}
+	writeNotice(content, g, noticeText, fmt.Sprintf("%d", style.NoticeColour))
+}
+
+// WriteWarning writes the notice in bold red, so that it could not be missed. It is meant for security issues only.
+func WriteWarning(content string, g *gocui.Gui) {
+	writeNotice(content, g, warningPrefix, fmt.Sprintf("1;%d", logger.ColorRed))
+}
+
+func writeNotice(content string, g *gocui.Gui, noticeText string, colour string) {
 	g.Update(func(gui *gocui.Gui) error {
 		messagesView, err := g.View(layout.MessagesViewName)
 		if err != nil {
@@ -79,17 +122,13 @@ func WriteNotice(content string, g *gocui.Gui, noticePrefix ...string) {
 		currentTime := time.Now()
 
 		formattedTime := fmt.Sprintf("\x1b[%dm%s\x1b[0m",
-			logger.ColorWhite,
-			currentTime.Format(layout.TimeFormatting),
+			style.TimeColour,
+			currentTime.Format(style.TimeFormat),
 		)
 
-		noticeText := defaultNoticePrefix
-		if len(noticePrefix) == 1 {
-			noticeText = noticePrefix[0]
-		}
-		formattedMessage := fmt.Sprintf("%s \x1b[%dm%s: %s\x1b[0m",
+		formattedMessage := fmt.Sprintf("%s \x1b[%sm%s: %s\x1b[0m",
 			formattedTime,
-			logger.ColorYellow,
+			colour,
 			noticeText,
 			content,
 		)
@@ -114,7 +153,7 @@ func WriteInfo(content string, g *gocui.Gui, infoPrefix ...string) {
 			infoText = infoPrefix[0]
 		}
 		formattedMessage := fmt.Sprintf("\x1b[%dm%s: %s\x1b[0m",
-			logger.ColorWhite,
+			style.InfoColour,
 			infoText,
 			content,
 		)
@@ -127,20 +166,79 @@ func WriteInfo(content string, g *gocui.Gui, infoPrefix ...string) {
 	})
 }
 
+// WriteDebug appends the lines to the debug view if it is currently displayed.
+func WriteDebug(g *gocui.Gui, lines ...string) {
+	writeDebug(g, false, func() []string { return lines })
+}
+
+// ResetDebug replaces the content of the debug view, if it is currently displayed, with the provided lines.
+// They are obtained only once the view is ready to be written to.
+func ResetDebug(g *gocui.Gui, getLines func() []string) {
+	writeDebug(g, true, getLines)
+}
+
+func writeDebug(g *gocui.Gui, clear bool, getLines func() []string) {
+	g.Update(func(gui *gocui.Gui) error {
+		debugView, err := g.View(layout.DebugViewName)
+		if err != nil {
+			// it's not an error if the view is hidden
+			return nil
+		}
+
+		if clear {
+			debugView.Clear()
+		}
+		for _, line := range getLines() {
+			if _, err := debugView.Write([]byte(line + "\n")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ShowOverlay displays the lines on top of the chat views until the user dismisses them with Esc or Enter.
+func ShowOverlay(g *gocui.Gui, title string, lines []string) {
+	g.Update(func(g *gocui.Gui) error {
+		return layout.ShowOverlay(g, fmt.Sprintf(" %s (Esc to close) ", title), lines)
+	})
+}
+
+// CreateGUI creates new gui without any views. They are created once its layout is set, for example by ShowChat.
 func CreateGUI() (*gocui.Gui, error) {
 	g, err := gocui.NewGui(gocui.OutputNormal)
 	//g, err := gocui.NewGui(gocui.Output256)
 	if err != nil {
 		return nil, err
 	}
+	// otherwise a lone Esc is taken as the beginning of an Alt combination, which we do not use
+	g.InputEsc = true
+	return g, nil
+}
 
-	g.SetManagerFunc(layout.Layout)
+// ShowChat replaces anything displayed in the gui with the chat views, removing all keybindings apart from the control ones.
+// It returns the channel that is closed once all of the views are created, i.e. when it is safe to start writing to them.
+func ShowChat(g *gocui.Gui) (<-chan struct{}, error) {
+	ready := make(chan struct{})
+	var readyOnce sync.Once
+	g.SetManagerFunc(func(g *gocui.Gui) error {
+		if err := layout.Layout(g); err != nil {
+			return err
+		}
+		readyOnce.Do(func() { close(ready) })
+		return nil
+	})
 
 	if err := initControlKeybindings(g); err != nil {
 		return nil, err
 	}
+	// do not bring back the overlay left open in the previous session
+	if err := layout.HideOverlay(g, nil); err != nil {
+		return nil, err
+	}
 
-	return g, nil
+	return ready, nil
 }
 
 func quit(g *gocui.Gui, v *gocui.View) error {
@@ -2,19 +2,161 @@ package layout
 
 import (
 	"github.com/jroimartin/gocui"
+	"unicode/utf8"
 )
 
 const (
 	InputViewName = "input"
 	MessagesViewName = "messages"
+	DebugViewName = "debug"
+	OverlayViewName = "overlay"
 	TimeFormatting = "[15:04:05]"
 )
 
+// whether the debug view is displayed, it is only ever accessed from the gocui main loop
+var showDebug = false
+
+// ToggleDebugView shows or hides the debug view below the messages.
+func ToggleDebugView(g *gocui.Gui, v *gocui.View) error {
+	showDebug = !showDebug
+	if !showDebug {
+		if err := g.DeleteView(DebugViewName); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlay is the content displayed on top of the other views, it is nil if there is nothing to display.
+// Like showDebug, it is only ever accessed from the gocui main loop.
+var overlay *overlayContent
+
+type overlayContent struct {
+	title string
+	lines []string
+}
+
+// ShowOverlay displays the lines in a box on top of the other views until HideOverlay is called.
+// The box takes the keyboard focus, so that the content could not be accidentally typed over.
+func ShowOverlay(g *gocui.Gui, title string, lines []string) error {
+	// the content is only written when the view is created, so replace any previous one
+	if err := g.DeleteView(OverlayViewName); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	overlay = &overlayContent{
+		title: title,
+		lines: lines,
+	}
+	return nil
+}
+
+// HideOverlay removes the overlay, returning the focus to the input.
+func HideOverlay(g *gocui.Gui, v *gocui.View) error {
+	overlay = nil
+	if err := g.DeleteView(OverlayViewName); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if _, err := g.SetCurrentView(InputViewName); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	return nil
+}
+
+// OverlayFits tells whether the lines can be displayed in the overlay without being cut off,
+// if need be without the frame.
+func OverlayFits(g *gocui.Gui, lines []string) bool {
+	maxX, maxY := g.Size()
+	width, height := overlaySize(lines, false)
+	return width <= maxX && height <= maxY
+}
+
+// overlaySize returns the size of the overlay box, including its frame if it has one
+func overlaySize(lines []string, framed bool) (int, int) {
+	width := 0
+	for _, line := range lines {
+		if lineWidth := utf8.RuneCountInString(line); lineWidth > width {
+			width = lineWidth
+		}
+	}
+	if !framed {
+		return width, len(lines)
+	}
+	return width + 2, len(lines) + 2
+}
+
+func layoutOverlay(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	// the frame is only a nicety, the whole content is more important on small screens
+	width, height := overlaySize(overlay.lines, true)
+	framed := width <= maxX && height <= maxY
+	if !framed {
+		width, height = overlaySize(overlay.lines, false)
+	}
+	// views without frames still reserve the space for them
+	offset := 0
+	if !framed {
+		offset = 1
+	}
+	// center it, cutting off whatever does not fit the screen
+	x0, y0 := (maxX-width)/2, (maxY-height)/2
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	x1, y1 := x0+width-1, y0+height-1
+	if x1 > maxX-1 {
+		x1 = maxX - 1
+	}
+	if y1 > maxY-1 {
+		y1 = maxY - 1
+	}
+
+	v, err := g.SetView(OverlayViewName, x0-offset, y0-offset, x1+offset, y1+offset)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = overlay.title
+		v.FgColor = gocui.ColorWhite
+		v.BgColor = gocui.ColorBlack
+		for _, line := range overlay.lines {
+			if _, err := v.Write([]byte(line + "\n")); err != nil {
+				return err
+			}
+		}
+	}
+	// the screen might have been resized since the view was created
+	v.Frame = framed
+	if _, err := g.SetViewOnTop(OverlayViewName); err != nil {
+		return err
+	}
+	if _, err := g.SetCurrentView(OverlayViewName); err != nil {
+		return err
+	}
+	return nil
+}
+
 func Layout(g *gocui.Gui) error {
 	maxX, maxY := g.Size()
 	g.Cursor = true
 
-	if messages, err := g.SetView(MessagesViewName, 0, 0, maxX-1, maxY-5); err != nil {
+	messagesMaxY := maxY - 5
+	if showDebug {
+		// give the debug view the bottom third of the space above the input
+		messagesMaxY = (maxY - 5) * 2 / 3
+		if debug, err := g.SetView(DebugViewName, 0, messagesMaxY+1, maxX-1, maxY-5); err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			debug.Title = " debug (F2 to hide): "
+			debug.Autoscroll = true
+			debug.Wrap = true
+		}
+	}
+
+	if messages, err := g.SetView(MessagesViewName, 0, 0, maxX-1, messagesMaxY); err != nil {
 		if err != gocui.ErrUnknownView {
 			return err
 		}
@@ -38,5 +180,9 @@ func Layout(g *gocui.Gui) error {
 		input.Editable = true
 
 	}
+
+	if overlay != nil {
+		return layoutOverlay(g)
+	}
 	return nil
 }
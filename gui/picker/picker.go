@@ -0,0 +1,520 @@
+// Package picker implements a searchable list displayed in the gui, from which the user chooses a single entry.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SearchViewName = "picker_search"
+	ListViewName   = "picker_list"
+	StatusViewName = "picker_status"
+
+	// how often the entries are reloaded from the source
+	refreshInterval = 2 * time.Second
+	// how often Reload is called while a choice is being retried
+	retryReloadInterval = 10 * time.Second
+
+	matchesSection = "matches"
+)
+
+var (
+	ErrNothingSelected = errors.New("nothing is selected")
+)
+
+// Entry is a single item of the list.
+type Entry struct {
+	// ID identifies the entry between reloads, so that it would stay selected
+	ID string
+	// Section groups the entries while nothing is being searched for.
+	// The entries are expected to be already sorted by their sections.
+	Section string
+	// Label is the displayed text
+	Label string
+	// Keywords are matched against the search query fuzzily and case-insensitively,
+	// i.e. their characters do not need to be adjacent
+	Keywords []string
+	// Prefixes only match the query if they start with it, which suits long identifiers such as keys.
+	// They are matched case-sensitively, as base64 keys differing only in case are different keys.
+	Prefixes []string
+	// Value is whatever the user of the picker needs to handle the entry
+	Value interface{}
+}
+
+// RetryError can be returned by Choose if the entry can't be chosen just yet, but it is likely to become possible later.
+// The choice is then repeated whenever the entries are reloaded, until it succeeds or another entry is chosen.
+type RetryError struct {
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+// Action is an additional operation on the selected entry bound to a key
+type Action struct {
+	Key         gocui.Key
+	Description string
+	Handle      func(g *gocui.Gui, entry Entry) error
+}
+
+// Config defines the content of the picker and what happens with the chosen entry.
+type Config struct {
+	Title string
+	// Source returns all entries. It is called from within the main loop of the gui, so it should return quickly.
+	Source func() []Entry
+	// FromQuery, if set, returns the entries made of the search query itself, for example of a pasted identifier.
+	// They are listed in front of the matching entries.
+	FromQuery func(query string) []Entry
+	// Reload, if set, is called in the background when the user explicitly asks for the list to be refreshed,
+	// for example to fetch the entries from the network, and periodically while a choice is being retried.
+	Reload func() error
+	// Choose is called from within the main loop of the gui once an entry is chosen.
+	// If it returns an error, it is displayed and the picker stays open.
+	Choose  func(g *gocui.Gui, entry Entry) error
+	Actions []Action
+}
+
+// row is a single line of the list, either a section header or an entry
+type row struct {
+	header string
+	match  int
+}
+
+// Picker is the gocui manager of the picker views
+type Picker struct {
+	cfg      Config
+	entries  []Entry
+	matches  []Entry
+	selected int
+	query    string
+	status   string
+
+	// the entry whose choice is being repeated
+	retrying        *Entry
+	reloading       bool
+	lastRetryReload time.Time
+
+	stopOnce sync.Once
+	haltedCh chan struct{}
+}
+
+// New creates the picker. Show has to be called to display it.
+func New(cfg Config) *Picker {
+	return &Picker{
+		cfg:      cfg,
+		haltedCh: make(chan struct{}),
+	}
+}
+
+// Show replaces anything displayed in the gui with the picker, removing all keybindings,
+// and starts refreshing its entries periodically.
+func (p *Picker) Show(g *gocui.Gui) error {
+	g.SetManager(p)
+	if err := p.initKeybindings(g); err != nil {
+		return err
+	}
+	p.reload(g)
+	go p.refreshInBackground(g)
+	return nil
+}
+
+// Stop stops refreshing the entries. It is called automatically once an entry is chosen.
+func (p *Picker) Stop() {
+	p.stopOnce.Do(func() { close(p.haltedCh) })
+}
+
+func (p *Picker) refreshInBackground(g *gocui.Gui) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.haltedCh:
+			return
+		case <-ticker.C:
+			p.update(g, func() {
+				// while retrying, do not wait for the view to be refreshed by somebody else
+				if p.retrying != nil && p.cfg.Reload != nil && time.Since(p.lastRetryReload) >= retryReloadInterval {
+					p.lastRetryReload = time.Now()
+					p.startReload(g)
+					return
+				}
+				p.reload(g)
+			})
+		}
+	}
+}
+
+// update runs the function within the main loop unless the picker was stopped in the meantime
+func (p *Picker) update(g *gocui.Gui, f func()) {
+	g.Update(func(g *gocui.Gui) error {
+		select {
+		case <-p.haltedCh:
+		default:
+			f()
+		}
+		return nil
+	})
+}
+
+func (p *Picker) initKeybindings(g *gocui.Gui) error {
+	bindings := map[gocui.Key]func(g *gocui.Gui, v *gocui.View) error{
+		gocui.KeyCtrlC:     quit,
+		gocui.KeyEnter:     p.choose,
+		gocui.KeyArrowUp:   p.move(-1),
+		gocui.KeyArrowDown: p.move(1),
+		gocui.KeyPgup:      p.movePage(-1),
+		gocui.KeyPgdn:      p.movePage(1),
+		gocui.KeyCtrlR:     p.forceReload,
+	}
+	for _, action := range p.cfg.Actions {
+		bindings[action.Key] = p.handleAction(action)
+	}
+	for key, handler := range bindings {
+		if err := g.SetKeybinding("", key, gocui.ModNone, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quit(g *gocui.Gui, v *gocui.View) error {
+	return gocui.ErrQuit
+}
+
+func (p *Picker) selectedEntry() (Entry, error) {
+	if p.selected < 0 || p.selected >= len(p.matches) {
+		return Entry{}, ErrNothingSelected
+	}
+	return p.matches[p.selected], nil
+}
+
+func (p *Picker) choose(g *gocui.Gui, v *gocui.View) error {
+	entry, err := p.selectedEntry()
+	if err != nil {
+		p.status = err.Error()
+		return nil
+	}
+	p.retrying = nil
+	p.tryChoosing(g, entry)
+	return nil
+}
+
+func (p *Picker) tryChoosing(g *gocui.Gui, entry Entry) {
+	err := p.cfg.Choose(g, entry)
+	if err == nil {
+		p.retrying = nil
+		p.Stop()
+		return
+	}
+	if _, ok := err.(*RetryError); ok {
+		p.retrying = &entry
+		p.status = fmt.Sprintf("%v - it will be retried automatically, choose anything else to cancel", err)
+		return
+	}
+	p.retrying = nil
+	p.status = err.Error()
+}
+
+func (p *Picker) handleAction(action Action) func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		entry, err := p.selectedEntry()
+		if err != nil {
+			p.status = err.Error()
+			return nil
+		}
+		if err := action.Handle(g, entry); err != nil {
+			p.status = err.Error()
+			return nil
+		}
+		p.reload(g)
+		return nil
+	}
+}
+
+func (p *Picker) move(delta int) func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		p.selected += delta
+		p.clampSelection()
+		return nil
+	}
+}
+
+func (p *Picker) movePage(delta int) func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		pageSize := 1
+		if list, err := g.View(ListViewName); err == nil {
+			_, pageSize = list.Size()
+		}
+		p.selected += delta * pageSize
+		p.clampSelection()
+		return nil
+	}
+}
+
+func (p *Picker) clampSelection() {
+	if p.selected >= len(p.matches) {
+		p.selected = len(p.matches) - 1
+	}
+	if p.selected < 0 {
+		p.selected = 0
+	}
+}
+
+func (p *Picker) forceReload(g *gocui.Gui, v *gocui.View) error {
+	if p.cfg.Reload == nil {
+		p.reload(g)
+		return nil
+	}
+	p.status = "refreshing..."
+	p.startReload(g)
+	return nil
+}
+
+// startReload calls Reload in the background and reloads the entries once it is done
+func (p *Picker) startReload(g *gocui.Gui) {
+	if p.reloading {
+		return
+	}
+	p.reloading = true
+	go func() {
+		err := p.cfg.Reload()
+		p.update(g, func() {
+			p.reloading = false
+			if err != nil {
+				p.status = fmt.Sprintf("failed to refresh: %v", err)
+			} else if p.retrying == nil {
+				p.status = ""
+			}
+			p.reload(g)
+		})
+	}()
+}
+
+// reload fetches the entries from the source and repeats the choice that is being retried, if any
+func (p *Picker) reload(g *gocui.Gui) {
+	p.entries = p.cfg.Source()
+	p.filter()
+	if p.retrying != nil {
+		p.tryChoosing(g, *p.retrying)
+	}
+}
+
+// filter finds the entries matching the current query, keeping the selection on the same entry if possible
+func (p *Picker) filter() {
+	selectedID := ""
+	if entry, err := p.selectedEntry(); err == nil {
+		selectedID = entry.ID
+	}
+
+	if p.query == "" {
+		p.matches = p.entries
+	} else {
+		type scoredEntry struct {
+			entry Entry
+			score int
+		}
+		scored := make([]scoredEntry, 0, len(p.entries))
+		for _, entry := range p.entries {
+			if score := matchScore(p.query, entry); score >= 0 {
+				scored = append(scored, scoredEntry{entry, score})
+			}
+		}
+		sort.SliceStable(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+		p.matches = make([]Entry, 0, len(scored))
+		if p.cfg.FromQuery != nil {
+			p.matches = append(p.matches, p.cfg.FromQuery(p.query)...)
+		}
+		for _, s := range scored {
+			p.matches = append(p.matches, s.entry)
+		}
+	}
+
+	p.selected = 0
+	for i, entry := range p.matches {
+		if entry.ID == selectedID {
+			p.selected = i
+			break
+		}
+	}
+}
+
+func (p *Picker) edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	// the search is a single line
+	if key == gocui.KeyEnter || key == gocui.KeyTab {
+		return
+	}
+	gocui.DefaultEditor.Edit(v, key, ch, mod)
+	if query := strings.TrimSpace(v.Buffer()); query != p.query {
+		p.query = query
+		p.status = ""
+		p.filter()
+	}
+}
+
+// rows lays out the matches into lines, putting headers in front of every section
+func (p *Picker) rows() []row {
+	rows := make([]row, 0, len(p.matches))
+	section := ""
+	for i, entry := range p.matches {
+		entrySection := entry.Section
+		if p.query != "" {
+			entrySection = matchesSection
+		}
+		if i == 0 || entrySection != section {
+			section = entrySection
+			rows = append(rows, row{header: section, match: -1})
+		}
+		rows = append(rows, row{match: i})
+	}
+	return rows
+}
+
+func (p *Picker) renderList(v *gocui.View) {
+	v.Clear()
+	width, height := v.Size()
+	if height < 1 {
+		return
+	}
+	rows := p.rows()
+	selectedRow := 0
+	for i, r := range rows {
+		if r.match == p.selected {
+			selectedRow = i
+			break
+		}
+	}
+	pages := (len(rows) + height - 1) / height
+	if pages == 0 {
+		pages = 1
+	}
+	page := selectedRow / height
+	v.Title = fmt.Sprintf(" %s (%d of %d, page %d/%d) ", p.cfg.Title, len(p.matches), len(p.entries), page+1, pages)
+
+	if len(p.matches) == 0 {
+		fmt.Fprintln(v, " nothing matches your search")
+		return
+	}
+	end := (page + 1) * height
+	if end > len(rows) {
+		end = len(rows)
+	}
+	for _, r := range rows[page*height : end] {
+		switch {
+		case r.match < 0:
+			fmt.Fprintf(v, "\x1b[1m%s:\x1b[0m\n", strings.Title(r.header))
+		case r.match == p.selected:
+			line := "  " + p.matches[r.match].Label
+			if padding := width - len([]rune(line)); padding > 0 {
+				line += strings.Repeat(" ", padding)
+			}
+			fmt.Fprintf(v, "\x1b[7m%s\x1b[0m\n", line)
+		default:
+			fmt.Fprintf(v, "  %s\n", p.matches[r.match].Label)
+		}
+	}
+}
+
+func (p *Picker) renderStatus(v *gocui.View) {
+	v.Clear()
+	help := "type to search, up/down: move, PgUp/PgDn: page, Enter: choose, Ctrl-R: refresh"
+	for _, action := range p.cfg.Actions {
+		help += ", " + action.Description
+	}
+	help += ", Ctrl-C: quit"
+	if p.status != "" {
+		fmt.Fprintf(v, "%s | %s", p.status, help)
+		return
+	}
+	fmt.Fprint(v, help)
+}
+
+// Layout implements gocui.Manager
+func (p *Picker) Layout(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	g.Cursor = true
+
+	search, err := g.SetView(SearchViewName, 0, 0, maxX-1, 2)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		search.Title = " search: "
+		search.Editable = true
+		search.Editor = gocui.EditorFunc(p.edit)
+		if _, err := g.SetCurrentView(SearchViewName); err != nil {
+			return err
+		}
+	}
+
+	list, err := g.SetView(ListViewName, 0, 3, maxX-1, maxY-4)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	p.renderList(list)
+
+	status, err := g.SetView(StatusViewName, 0, maxY-3, maxX-1, maxY-1)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		status.Wrap = true
+	}
+	p.renderStatus(status)
+	return nil
+}
+
+// matchScore returns how well the entry matches the query or -1 if it does not match at all.
+// Earlier keywords are preferred over the later ones.
+func matchScore(query string, entry Entry) int {
+	best := -1
+	for i, keyword := range entry.Keywords {
+		if score := fuzzyScore(query, keyword); score >= 0 {
+			score += 2 * (len(entry.Keywords) - i)
+			if score > best {
+				best = score
+			}
+		}
+	}
+	for _, prefix := range entry.Prefixes {
+		if strings.HasPrefix(prefix, query) {
+			// treat it as good as consecutive characters at the start of a keyword
+			if score := fuzzyScore(query, prefix); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// fuzzyScore checks whether all characters of the pattern appear in the text in the same order.
+// Runs of consecutive characters, especially at the beginning of the text, are scored higher.
+// It returns -1 if the text does not match.
+func fuzzyScore(pattern, text string) int {
+	patternRunes := []rune(strings.ToLower(pattern))
+	textRunes := []rune(strings.ToLower(text))
+	score, run, matched := 0, 0, 0
+	for i := 0; i < len(textRunes) && matched < len(patternRunes); i++ {
+		if textRunes[i] != patternRunes[matched] {
+			run = 0
+			continue
+		}
+		run++
+		score += run
+		if i == matched {
+			// still matching the beginning of the text
+			score += 2
+		}
+		matched++
+	}
+	if matched < len(patternRunes) {
+		return -1
+	}
+	return score
+}
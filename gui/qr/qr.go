@@ -0,0 +1,60 @@
+// Package qr draws QR codes in the terminal with Unicode block characters, two modules per character cell.
+package qr
+
+import (
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// quietZone is the number of light modules around the code. The standard asks for 4,
+	// but the scanners cope with less and the terminal space is precious.
+	quietZone = 2
+
+	// ResetColours restores the default colours of the terminal after Colours
+	ResetColours = "\x1b[0m"
+	// Colours are the ANSI codes of the bright white foreground on the black background
+	// the code has to be drawn with, as the terminal default ones are not known
+	Colours = "\x1b[97;40m"
+)
+
+// Render returns the lines of the QR code encoding the content. Light modules are drawn as the foreground,
+// so the code has to be displayed with a light foreground on a dark background to be scanned.
+func Render(content string) ([]string, error) {
+	code, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	size := len(bitmap) + 2*quietZone
+	// anything out of the bitmap belongs to the quiet zone, which is light
+	isLight := func(x, y int) bool {
+		x, y = x-quietZone, y-quietZone
+		if y < 0 || y >= len(bitmap) || x < 0 || x >= len(bitmap) {
+			return true
+		}
+		return !bitmap[y][x]
+	}
+
+	lines := make([]string, 0, (size+1)/2)
+	for y := 0; y < size; y += 2 {
+		line := make([]rune, size)
+		for x := 0; x < size; x++ {
+			// the last row of an odd-sized code has nothing below it
+			top, bottom := isLight(x, y), y+1 < size && isLight(x, y+1)
+			switch {
+			case top && bottom:
+				line[x] = '█'
+			case top:
+				line[x] = '▀'
+			case bottom:
+				line[x] = '▄'
+			default:
+				line[x] = ' '
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines, nil
+}
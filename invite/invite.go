@@ -0,0 +1,119 @@
+// Package invite handles the invite codes the users share to become each other's contacts.
+// An invite bundles the public key of the client, the public key of its provider and an optional suggested name
+// into a single string, such as "nymchat:AW2m...", protected by a checksum against typos and truncated copies.
+package invite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Scheme is the prefix of every invite
+	Scheme = "nymchat:"
+	// MaxNameLength is the maximum length of the suggested name, in bytes
+	MaxNameLength = 64
+
+	version        = 1
+	checksumLength = 4
+	// version, both keys and the checksum
+	minPayloadLength = 1 + 2*sphinx.PublicKeySize + checksumLength
+)
+
+var (
+	ErrNotAnInvite        = errors.New("not an invite, it has to start with " + Scheme)
+	ErrMalformedInvite    = errors.New("malformed invite")
+	ErrChecksumMismatch   = errors.New("invite checksum does not match, it might have been mistyped or cut off")
+	ErrUnsupportedVersion = errors.New("invite was created by an unsupported version of the client")
+	ErrNameTooLong        = errors.New("suggested name of the invite is too long")
+	ErrInvalidName        = errors.New("suggested name of the invite is not valid UTF-8")
+)
+
+// Invite is everything needed to start talking to a client
+type Invite struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+	// Name is the name the client suggests to be known by, it might be empty
+	Name string
+}
+
+func checksum(data []byte) []byte {
+	sum := sha256.Sum256(append([]byte(Scheme), data...))
+	return sum[:checksumLength]
+}
+
+// Encode creates the invite string
+func (i *Invite) Encode() (string, error) {
+	if len(i.Name) > MaxNameLength {
+		return "", ErrNameTooLong
+	}
+	if !utf8.ValidString(i.Name) {
+		return "", ErrInvalidName
+	}
+	payload := make([]byte, 0, minPayloadLength+len(i.Name))
+	payload = append(payload, version)
+	payload = append(payload, i.PublicKey.Bytes()...)
+	payload = append(payload, i.ProviderPublicKey.Bytes()...)
+	payload = append(payload, i.Name...)
+	payload = append(payload, checksum(payload)...)
+	return Scheme + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// IsInvite tells whether the string looks like an invite, without verifying it
+func IsInvite(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), Scheme)
+}
+
+// Parse decodes and verifies the invite string
+func Parse(s string) (*Invite, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, Scheme) {
+		return nil, ErrNotAnInvite
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, Scheme))
+	if err != nil || len(payload) < minPayloadLength {
+		return nil, ErrMalformedInvite
+	}
+
+	data, sum := payload[:len(payload)-checksumLength], payload[len(payload)-checksumLength:]
+	if !bytes.Equal(checksum(data), sum) {
+		return nil, ErrChecksumMismatch
+	}
+	if data[0] != version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	publicKey := new(sphinx.PublicKey)
+	if err := publicKey.UnmarshalBinary(data[1 : 1+sphinx.PublicKeySize]); err != nil {
+		return nil, ErrMalformedInvite
+	}
+	providerPublicKey := new(sphinx.PublicKey)
+	if err := providerPublicKey.UnmarshalBinary(data[1+sphinx.PublicKeySize : 1+2*sphinx.PublicKeySize]); err != nil {
+		return nil, ErrMalformedInvite
+	}
+
+	name := data[1+2*sphinx.PublicKeySize:]
+	if len(name) > MaxNameLength {
+		return nil, ErrNameTooLong
+	}
+	if !utf8.Valid(name) {
+		return nil, ErrInvalidName
+	}
+
+	return &Invite{
+		PublicKey:         publicKey,
+		ProviderPublicKey: providerPublicKey,
+		Name:              string(name),
+	}, nil
+}
+
+// SuggestedAlias turns the suggested name into something that can be used as an alias in the commands,
+// i.e. without any whitespace. It is empty if no name was suggested.
+func (i *Invite) SuggestedAlias() string {
+	return strings.Join(strings.Fields(i.Name), "_")
+}
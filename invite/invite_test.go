@@ -0,0 +1,125 @@
+package invite
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"strings"
+	"testing"
+)
+
+const (
+	testInvite = "nymchat:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgJBbGljZSBTbWl0aHZJnCg"
+	testName   = "Alice Smith"
+)
+
+var (
+	testPublicKey         = bytes.Repeat([]byte{1}, sphinx.PublicKeySize)
+	testProviderPublicKey = bytes.Repeat([]byte{2}, sphinx.PublicKeySize)
+)
+
+// encodePayload builds the invite from the raw payload, adding the valid checksum,
+// so that everything but the checksum can be broken on purpose
+func encodePayload(version byte, name []byte) string {
+	payload := append([]byte{version}, testPublicKey...)
+	payload = append(payload, testProviderPublicKey...)
+	payload = append(payload, name...)
+	return Scheme + base64.RawURLEncoding.EncodeToString(append(payload, checksum(payload)...))
+}
+
+func TestEncode(t *testing.T) {
+	inv := &Invite{
+		PublicKey:         sphinx.BytesToPublicKey(testPublicKey),
+		ProviderPublicKey: sphinx.BytesToPublicKey(testProviderPublicKey),
+		Name:              testName,
+	}
+	encoded, err := inv.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != testInvite {
+		t.Errorf("encoded %v instead of %v", encoded, testInvite)
+	}
+
+	inv.Name = strings.Repeat("a", MaxNameLength+1)
+	if _, err := inv.Encode(); err != ErrNameTooLong {
+		t.Errorf("got error %v instead of %v", err, ErrNameTooLong)
+	}
+	inv.Name = "\xff"
+	if _, err := inv.Encode(); err != ErrInvalidName {
+		t.Errorf("got error %v instead of %v", err, ErrInvalidName)
+	}
+}
+
+func TestParse(t *testing.T) {
+	// a single character of the keys changed
+	mistyped := []byte(testInvite)
+	mistyped[len(Scheme)+10] = 'B'
+	longestName := strings.Repeat("a", MaxNameLength)
+
+	tests := []struct {
+		name    string
+		invite  string
+		err     error
+		invName string
+	}{
+		{"valid", testInvite, nil, testName},
+		{"surrounded by whitespace", " \t" + testInvite + "\n", nil, testName},
+		{"without name", encodePayload(version, nil), nil, ""},
+		{"longest name", encodePayload(version, []byte(longestName)), nil, longestName},
+		{"other scheme", "nymchat2:" + strings.TrimPrefix(testInvite, Scheme), ErrNotAnInvite, ""},
+		{"empty", "", ErrNotAnInvite, ""},
+		{"just the scheme", Scheme, ErrMalformedInvite, ""},
+		{"not base64", Scheme + "not+base64/at*all", ErrMalformedInvite, ""},
+		{"padded base64", testInvite + "==", ErrMalformedInvite, ""},
+		{"too short", Scheme + base64.RawURLEncoding.EncodeToString(make([]byte, minPayloadLength-1)), ErrMalformedInvite, ""},
+		{"cut off", testInvite[:len(testInvite)-8], ErrChecksumMismatch, ""},
+		{"mistyped", string(mistyped), ErrChecksumMismatch, ""},
+		{"newer version", encodePayload(version+1, []byte(testName)), ErrUnsupportedVersion, ""},
+		{"name too long", encodePayload(version, []byte(longestName+"a")), ErrNameTooLong, ""},
+		{"name not UTF-8", encodePayload(version, []byte{0xff, 0xfe}), ErrInvalidName, ""},
+	}
+	for _, test := range tests {
+		inv, err := Parse(test.invite)
+		if err != test.err {
+			t.Errorf("%s: got error %v instead of %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(inv.PublicKey.Bytes(), testPublicKey) || !bytes.Equal(inv.ProviderPublicKey.Bytes(), testProviderPublicKey) {
+			t.Errorf("%s: parsed keys %v and %v", test.name, inv.PublicKey.Bytes(), inv.ProviderPublicKey.Bytes())
+		}
+		if inv.Name != test.invName {
+			t.Errorf("%s: parsed name %q instead of %q", test.name, inv.Name, test.invName)
+		}
+	}
+}
+
+func TestIsInvite(t *testing.T) {
+	for s, expected := range map[string]bool{
+		testInvite:              true,
+		"  " + Scheme + "junk":  true,
+		"hello":                 false,
+		"see " + testInvite:     false,
+		strings.ToUpper(Scheme): false,
+	} {
+		if IsInvite(s) != expected {
+			t.Errorf("%q: got %v instead of %v", s, !expected, expected)
+		}
+	}
+}
+
+func TestSuggestedAlias(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":                  "",
+		"alice":             "alice",
+		"Alice Smith":       "Alice_Smith",
+		"  Alice \t Smith ": "Alice_Smith",
+	} {
+		if alias := (&Invite{Name: name}).SuggestedAlias(); alias != expected {
+			t.Errorf("%q: got alias %q instead of %q", name, alias, expected)
+		}
+	}
+}
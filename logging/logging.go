@@ -0,0 +1,96 @@
+// Package logging provides levelled, structured logging of the chat client to a rotating file,
+// while keeping the recent lines in memory so that they could be shown live in the terminal UI.
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	recentLinesLimit = 200
+	timestampFormat  = "2006-01-02 15:04:05.000"
+)
+
+// Logger holds all necessary data to create module-specific loggers
+type Logger struct {
+	base   *logrus.Logger
+	out    io.Closer
+	recent *Recent
+}
+
+// GetLogger returns a per-module logger that writes to the backend.
+func (l *Logger) GetLogger(module string) *logrus.Entry {
+	return l.base.WithField("module", module)
+}
+
+// Recent returns the buffer of the most recently logged lines.
+func (l *Logger) Recent() *Recent {
+	return l.recent
+}
+
+// SetLevel changes the logging level of all module loggers.
+func (l *Logger) SetLevel(level logrus.Level) {
+	l.base.SetLevel(level)
+}
+
+// Close closes the underlying log file. Anything logged afterwards is discarded.
+func (l *Logger) Close() error {
+	if l.out == nil {
+		return nil
+	}
+	return l.out.Close()
+}
+
+func newLogger(out io.Writer, level logrus.Level) *Logger {
+	base := logrus.New()
+	base.Formatter = &logrus.TextFormatter{
+		DisableColors:   true,
+		FullTimestamp:   true,
+		TimestampFormat: timestampFormat,
+	}
+	base.Out = out
+	base.Level = level
+
+	recent := newRecent(recentLinesLimit)
+	base.AddHook(recent)
+
+	logger := &Logger{
+		base:   base,
+		recent: recent,
+	}
+	if closer, ok := out.(io.Closer); ok {
+		logger.out = closer
+	}
+	return logger
+}
+
+// New returns new instance of logger writing to the file f, which is rotated once it reaches maxSize bytes,
+// with up to maxBackups of the old files being kept.
+func New(f string, level string, disable bool, maxSize int64, maxBackups int) (*Logger, error) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	if disable {
+		return newLogger(ioutil.Discard, lvl), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f), 0700); err != nil {
+		return nil, err
+	}
+	out, err := NewRotatingFile(f, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return newLogger(out, lvl), nil
+}
+
+// Discard returns logger which does not write anything, but still keeps the recent lines in memory.
+func Discard() *Logger {
+	return newLogger(ioutil.Discard, logrus.InfoLevel)
+}
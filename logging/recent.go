@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+)
+
+// Recent is a logrus hook keeping the last lines that were logged and passing new ones to the listeners.
+type Recent struct {
+	sync.Mutex
+	lines     []string
+	limit     int
+	listeners map[int]func(string)
+	nextID    int
+}
+
+func newRecent(limit int) *Recent {
+	return &Recent{
+		lines:     make([]string, 0, limit),
+		limit:     limit,
+		listeners: make(map[int]func(string)),
+	}
+}
+
+func (r *Recent) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (r *Recent) Fire(entry *logrus.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\n")
+
+	r.Lock()
+	defer r.Unlock()
+	if len(r.lines) == r.limit {
+		copy(r.lines, r.lines[1:])
+		r.lines = r.lines[:len(r.lines)-1]
+	}
+	r.lines = append(r.lines, line)
+
+	for _, listener := range r.listeners {
+		listener(line)
+	}
+	return nil
+}
+
+// Lines returns the copy of the kept lines, starting with the oldest one.
+func (r *Recent) Lines() []string {
+	r.Lock()
+	defer r.Unlock()
+	lines := make([]string, len(r.lines))
+	copy(lines, r.lines)
+	return lines
+}
+
+// Listen registers the function called with every new line. It must not block nor log anything itself.
+// The returned function removes the listener.
+func (r *Recent) Listen(listener func(line string)) func() {
+	r.Lock()
+	defer r.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.listeners[id] = listener
+
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		delete(r.listeners, id)
+	}
+}
@@ -20,17 +20,166 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// Kind defines how the message should be handled by the receiving client
+type Kind int32
+
+const (
+	// regular chat message shown to the user
+	Kind_TEXT Kind = 0
+	// probe that should be automatically answered with PONG, without showing anything to the user
+	Kind_PING Kind = 1
+	// answer to the PING
+	Kind_PONG Kind = 2
+	// update of the sender's profile, it is stored without showing anything but a notice to the user
+	Kind_PROFILE Kind = 3
+	// chat message encrypted with the sender key of the sender, sent to every member of the group separately
+	Kind_GROUP_TEXT Kind = 4
+	// change of the membership of the group, announced to all of its members
+	Kind_GROUP_UPDATE Kind = 5
+	// sender key the sender encrypts its messages to the group with, sent to every member separately
+	Kind_GROUP_KEY Kind = 6
+)
+
+var Kind_name = map[int32]string{
+	0: "TEXT",
+	1: "PING",
+	2: "PONG",
+	3: "PROFILE",
+	4: "GROUP_TEXT",
+	5: "GROUP_UPDATE",
+	6: "GROUP_KEY",
+}
+
+var Kind_value = map[string]int32{
+	"TEXT":         0,
+	"PING":         1,
+	"PONG":         2,
+	"PROFILE":      3,
+	"GROUP_TEXT":   4,
+	"GROUP_UPDATE": 5,
+	"GROUP_KEY":    6,
+}
+
+func (x Kind) String() string {
+	return proto.EnumName(Kind_name, int32(x))
+}
+
+func (Kind) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{0}
+}
+
+// GroupOp is the kind of the change of the membership
+type GroupOp int32
+
+const (
+	// the subject has been added to the group by the sender
+	GroupOp_ADD GroupOp = 0
+	// the subject, i.e. the sender itself, has left the group
+	GroupOp_LEAVE GroupOp = 1
+	// the subject has been removed from the group by the sender
+	GroupOp_REMOVE GroupOp = 2
+	// the subject has been removed from the group by the sender and can not be added again
+	GroupOp_BAN GroupOp = 3
+	// the subject has been made an admin of the group by the sender
+	GroupOp_PROMOTE GroupOp = 4
+	// the group has been renamed by the sender
+	GroupOp_RENAME GroupOp = 5
+	// the subject, i.e. the sender itself, sends its signing key directly to the contact it is about to add to the group, which answers with its own
+	GroupOp_KEY GroupOp = 6
+	// the whole group as the owner, i.e. the sender, knows it, replacing whatever the others have after conflicting changes
+	GroupOp_SYNC GroupOp = 7
+	// the subject, i.e. the sender itself, asks the owner for SYNC, as the changes it gets do not match its group
+	GroupOp_SYNC_REQUEST GroupOp = 8
+)
+
+var GroupOp_name = map[int32]string{
+	0: "ADD",
+	1: "LEAVE",
+	2: "REMOVE",
+	3: "BAN",
+	4: "PROMOTE",
+	5: "RENAME",
+	6: "KEY",
+	7: "SYNC",
+	8: "SYNC_REQUEST",
+}
+
+var GroupOp_value = map[string]int32{
+	"ADD":          0,
+	"LEAVE":        1,
+	"REMOVE":       2,
+	"BAN":          3,
+	"PROMOTE":      4,
+	"RENAME":       5,
+	"KEY":          6,
+	"SYNC":         7,
+	"SYNC_REQUEST": 8,
+}
+
+func (x GroupOp) String() string {
+	return proto.EnumName(GroupOp_name, int32(x))
+}
+
+func (GroupOp) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{1}
+}
+
+// GroupRole defines what the member is allowed to do within the group
+type GroupRole int32
+
+const (
+	GroupRole_MEMBER GroupRole = 0
+	// creator of the group
+	GroupRole_OWNER GroupRole = 1
+	// allowed to add, remove and ban members and to rename the group
+	GroupRole_ADMIN GroupRole = 2
+)
+
+var GroupRole_name = map[int32]string{
+	0: "MEMBER",
+	1: "OWNER",
+	2: "ADMIN",
+}
+
+var GroupRole_value = map[string]int32{
+	"MEMBER": 0,
+	"OWNER":  1,
+	"ADMIN":  2,
+}
+
+func (x GroupRole) String() string {
+	return proto.EnumName(GroupRole_name, int32(x))
+}
+
+func (GroupRole) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{2}
+}
+
 // fields are actually ordered in the order of priority for implementation
 type ChatMessage struct {
-	Content                 []byte   `protobuf:"bytes,1,opt,name=Content,json=content,proto3" json:"Content,omitempty"`
-	SenderPublicKey         []byte   `protobuf:"bytes,2,opt,name=SenderPublicKey,json=senderPublicKey,proto3" json:"SenderPublicKey,omitempty"`
-	SenderProviderPublicKey []byte   `protobuf:"bytes,3,opt,name=SenderProviderPublicKey,json=senderProviderPublicKey,proto3" json:"SenderProviderPublicKey,omitempty"`
-	MessageNonce            int64    `protobuf:"varint,4,opt,name=MessageNonce,json=messageNonce,proto3" json:"MessageNonce,omitempty"`
-	SenderTimestamp         int64    `protobuf:"varint,5,opt,name=SenderTimestamp,json=senderTimestamp,proto3" json:"SenderTimestamp,omitempty"`
-	Signature               []byte   `protobuf:"bytes,6,opt,name=Signature,json=signature,proto3" json:"Signature,omitempty"`
-	XXX_NoUnkeyedLiteral    struct{} `json:"-"`
-	XXX_unrecognized        []byte   `json:"-"`
-	XXX_sizecache           int32    `json:"-"`
+	Content                 []byte `protobuf:"bytes,1,opt,name=Content,json=content,proto3" json:"Content,omitempty"`
+	SenderPublicKey         []byte `protobuf:"bytes,2,opt,name=SenderPublicKey,json=senderPublicKey,proto3" json:"SenderPublicKey,omitempty"`
+	SenderProviderPublicKey []byte `protobuf:"bytes,3,opt,name=SenderProviderPublicKey,json=senderProviderPublicKey,proto3" json:"SenderProviderPublicKey,omitempty"`
+	MessageNonce            int64  `protobuf:"varint,4,opt,name=MessageNonce,json=messageNonce,proto3" json:"MessageNonce,omitempty"`
+	SenderTimestamp         int64  `protobuf:"varint,5,opt,name=SenderTimestamp,json=senderTimestamp,proto3" json:"SenderTimestamp,omitempty"`
+	// only set in GROUP_TEXT messages, ed25519 signature of the message without this field by the signing key of the sender
+	Signature []byte `protobuf:"bytes,6,opt,name=Signature,json=signature,proto3" json:"Signature,omitempty"`
+	Kind      Kind   `protobuf:"varint,7,opt,name=Kind,json=kind,proto3,enum=message.Kind" json:"Kind,omitempty"`
+	// nonce of the message this one responds to, used by the probes
+	ReplyTo int64 `protobuf:"varint,8,opt,name=ReplyTo,json=replyTo,proto3" json:"ReplyTo,omitempty"`
+	// profile of the sender, only set in PROFILE messages
+	Profile *Profile `protobuf:"bytes,9,opt,name=Profile,json=profile,proto3" json:"Profile,omitempty"`
+	// group the message belongs to, only set in GROUP_* messages
+	GroupId []byte `protobuf:"bytes,10,opt,name=GroupId,json=groupId,proto3" json:"GroupId,omitempty"`
+	// only set in GROUP_UPDATE messages
+	GroupUpdate *GroupUpdate `protobuf:"bytes,11,opt,name=GroupUpdate,json=groupUpdate,proto3" json:"GroupUpdate,omitempty"`
+	// only set in GROUP_KEY messages
+	SenderKey *SenderKey `protobuf:"bytes,12,opt,name=SenderKey,json=senderKey,proto3" json:"SenderKey,omitempty"`
+	// generation of the sender key the content of GROUP_TEXT messages is encrypted with
+	KeyGeneration        uint32   `protobuf:"varint,13,opt,name=KeyGeneration,json=keyGeneration,proto3" json:"KeyGeneration,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ChatMessage) Reset()         { *m = ChatMessage{} }
@@ -100,25 +249,400 @@ func (m *ChatMessage) GetSignature() []byte {
 	return nil
 }
 
+func (m *ChatMessage) GetKind() Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return Kind_TEXT
+}
+
+func (m *ChatMessage) GetReplyTo() int64 {
+	if m != nil {
+		return m.ReplyTo
+	}
+	return 0
+}
+
+func (m *ChatMessage) GetProfile() *Profile {
+	if m != nil {
+		return m.Profile
+	}
+	return nil
+}
+
+func (m *ChatMessage) GetGroupId() []byte {
+	if m != nil {
+		return m.GroupId
+	}
+	return nil
+}
+
+func (m *ChatMessage) GetGroupUpdate() *GroupUpdate {
+	if m != nil {
+		return m.GroupUpdate
+	}
+	return nil
+}
+
+func (m *ChatMessage) GetSenderKey() *SenderKey {
+	if m != nil {
+		return m.SenderKey
+	}
+	return nil
+}
+
+func (m *ChatMessage) GetKeyGeneration() uint32 {
+	if m != nil {
+		return m.KeyGeneration
+	}
+	return 0
+}
+
+// Profile is how the sender would like to be presented to the others
+type Profile struct {
+	// name the sender suggests the others use as its alias
+	Name   string `protobuf:"bytes,1,opt,name=Name,json=name,proto3" json:"Name,omitempty"`
+	Status string `protobuf:"bytes,2,opt,name=Status,json=status,proto3" json:"Status,omitempty"`
+	// incremented with every change, as the mixnet does not preserve the order of the messages
+	Version              uint64   `protobuf:"varint,3,opt,name=Version,json=version,proto3" json:"Version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Profile) Reset()         { *m = Profile{} }
+func (m *Profile) String() string { return proto.CompactTextString(m) }
+func (*Profile) ProtoMessage()    {}
+func (*Profile) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{1}
+}
+
+func (m *Profile) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Profile.Unmarshal(m, b)
+}
+func (m *Profile) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Profile.Marshal(b, m, deterministic)
+}
+func (m *Profile) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Profile.Merge(m, src)
+}
+func (m *Profile) XXX_Size() int {
+	return xxx_messageInfo_Profile.Size(m)
+}
+func (m *Profile) XXX_DiscardUnknown() {
+	xxx_messageInfo_Profile.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Profile proto.InternalMessageInfo
+
+func (m *Profile) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Profile) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Profile) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// GroupMember is one of the clients the messages of the group are sent to
+type GroupMember struct {
+	PublicKey         []byte    `protobuf:"bytes,1,opt,name=PublicKey,json=publicKey,proto3" json:"PublicKey,omitempty"`
+	ProviderPublicKey []byte    `protobuf:"bytes,2,opt,name=ProviderPublicKey,json=providerPublicKey,proto3" json:"ProviderPublicKey,omitempty"`
+	Role              GroupRole `protobuf:"varint,3,opt,name=Role,json=role,proto3,enum=message.GroupRole" json:"Role,omitempty"`
+	// key the member signs the changes of the group with, once it is known
+	SigningKey           []byte   `protobuf:"bytes,4,opt,name=SigningKey,json=signingKey,proto3" json:"SigningKey,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GroupMember) Reset()         { *m = GroupMember{} }
+func (m *GroupMember) String() string { return proto.CompactTextString(m) }
+func (*GroupMember) ProtoMessage()    {}
+func (*GroupMember) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{2}
+}
+
+func (m *GroupMember) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GroupMember.Unmarshal(m, b)
+}
+func (m *GroupMember) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GroupMember.Marshal(b, m, deterministic)
+}
+func (m *GroupMember) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GroupMember.Merge(m, src)
+}
+func (m *GroupMember) XXX_Size() int {
+	return xxx_messageInfo_GroupMember.Size(m)
+}
+func (m *GroupMember) XXX_DiscardUnknown() {
+	xxx_messageInfo_GroupMember.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GroupMember proto.InternalMessageInfo
+
+func (m *GroupMember) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *GroupMember) GetProviderPublicKey() []byte {
+	if m != nil {
+		return m.ProviderPublicKey
+	}
+	return nil
+}
+
+func (m *GroupMember) GetRole() GroupRole {
+	if m != nil {
+		return m.Role
+	}
+	return GroupRole_MEMBER
+}
+
+func (m *GroupMember) GetSigningKey() []byte {
+	if m != nil {
+		return m.SigningKey
+	}
+	return nil
+}
+
+// GroupUpdate describes a single change of the membership of the group
+type GroupUpdate struct {
+	Op GroupOp `protobuf:"varint,1,opt,name=Op,json=op,proto3,enum=message.GroupOp" json:"Op,omitempty"`
+	// name of the group, so that new members learn it
+	Name string `protobuf:"bytes,2,opt,name=Name,json=name,proto3" json:"Name,omitempty"`
+	// member the change is about
+	Subject *GroupMember `protobuf:"bytes,3,opt,name=Subject,json=subject,proto3" json:"Subject,omitempty"`
+	// all members of the group including the sender after the change, only set in the changes signed by an admin
+	Members []*GroupMember `protobuf:"bytes,4,rep,name=Members,json=members,proto3" json:"Members,omitempty"`
+	// incremented with every change signed by an admin, older changes are ignored
+	Version uint64 `protobuf:"varint,5,opt,name=Version,json=version,proto3" json:"Version,omitempty"`
+	// clients that can not be added to the group again, only set in the changes signed by an admin
+	Banned []*GroupMember `protobuf:"bytes,6,rep,name=Banned,json=banned,proto3" json:"Banned,omitempty"`
+	// ed25519 signature of the group ID followed by the update without this field, by the signing key of the sender
+	Signature []byte `protobuf:"bytes,7,opt,name=Signature,json=signature,proto3" json:"Signature,omitempty"`
+	// only set in KEY updates, HMAC-SHA256 of the group ID, the public key and the signing key of the subject with the key
+	// shared by the sender and the recipient, proving that the signing key belongs to the owner of the sender's public key
+	Proof                []byte   `protobuf:"bytes,8,opt,name=Proof,json=proof,proto3" json:"Proof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GroupUpdate) Reset()         { *m = GroupUpdate{} }
+func (m *GroupUpdate) String() string { return proto.CompactTextString(m) }
+func (*GroupUpdate) ProtoMessage()    {}
+func (*GroupUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{3}
+}
+
+func (m *GroupUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GroupUpdate.Unmarshal(m, b)
+}
+func (m *GroupUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GroupUpdate.Marshal(b, m, deterministic)
+}
+func (m *GroupUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GroupUpdate.Merge(m, src)
+}
+func (m *GroupUpdate) XXX_Size() int {
+	return xxx_messageInfo_GroupUpdate.Size(m)
+}
+func (m *GroupUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_GroupUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GroupUpdate proto.InternalMessageInfo
+
+func (m *GroupUpdate) GetOp() GroupOp {
+	if m != nil {
+		return m.Op
+	}
+	return GroupOp_ADD
+}
+
+func (m *GroupUpdate) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *GroupUpdate) GetSubject() *GroupMember {
+	if m != nil {
+		return m.Subject
+	}
+	return nil
+}
+
+func (m *GroupUpdate) GetMembers() []*GroupMember {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *GroupUpdate) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *GroupUpdate) GetBanned() []*GroupMember {
+	if m != nil {
+		return m.Banned
+	}
+	return nil
+}
+
+func (m *GroupUpdate) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *GroupUpdate) GetProof() []byte {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// SenderKey is the symmetric key the sender encrypts its messages to the group with
+type SenderKey struct {
+	Key []byte `protobuf:"bytes,1,opt,name=Key,json=key,proto3" json:"Key,omitempty"`
+	// incremented whenever the key is replaced, i.e. when somebody is removed from the group
+	Generation uint32 `protobuf:"varint,2,opt,name=Generation,json=generation,proto3" json:"Generation,omitempty"`
+	// ed25519 signature of the group ID and the public key of the sender followed by the key without this field, by the signing key of the sender
+	Signature            []byte   `protobuf:"bytes,3,opt,name=Signature,json=signature,proto3" json:"Signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SenderKey) Reset()         { *m = SenderKey{} }
+func (m *SenderKey) String() string { return proto.CompactTextString(m) }
+func (*SenderKey) ProtoMessage()    {}
+func (*SenderKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ebceca9e8703e37f, []int{4}
+}
+
+func (m *SenderKey) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SenderKey.Unmarshal(m, b)
+}
+func (m *SenderKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SenderKey.Marshal(b, m, deterministic)
+}
+func (m *SenderKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SenderKey.Merge(m, src)
+}
+func (m *SenderKey) XXX_Size() int {
+	return xxx_messageInfo_SenderKey.Size(m)
+}
+func (m *SenderKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_SenderKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SenderKey proto.InternalMessageInfo
+
+func (m *SenderKey) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *SenderKey) GetGeneration() uint32 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *SenderKey) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("message.Kind", Kind_name, Kind_value)
+	proto.RegisterEnum("message.GroupOp", GroupOp_name, GroupOp_value)
+	proto.RegisterEnum("message.GroupRole", GroupRole_name, GroupRole_value)
 	proto.RegisterType((*ChatMessage)(nil), "message.ChatMessage")
+	proto.RegisterType((*Profile)(nil), "message.Profile")
+	proto.RegisterType((*GroupMember)(nil), "message.GroupMember")
+	proto.RegisterType((*GroupUpdate)(nil), "message.GroupUpdate")
+	proto.RegisterType((*SenderKey)(nil), "message.SenderKey")
 }
 
 func init() { proto.RegisterFile("message/message.proto", fileDescriptor_ebceca9e8703e37f) }
 
 var fileDescriptor_ebceca9e8703e37f = []byte{
-	// 193 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0xcd, 0x4d, 0x2d, 0x2e,
-	0x4e, 0x4c, 0x4f, 0xd5, 0x87, 0xd2, 0x7a, 0x05, 0x45, 0xf9, 0x25, 0xf9, 0x42, 0xec, 0x50, 0xae,
-	0xd2, 0x77, 0x46, 0x2e, 0x6e, 0xe7, 0x8c, 0xc4, 0x12, 0x5f, 0x08, 0x5f, 0x48, 0x82, 0x8b, 0xdd,
-	0x39, 0x3f, 0xaf, 0x24, 0x35, 0xaf, 0x44, 0x82, 0x51, 0x81, 0x51, 0x83, 0x27, 0x88, 0x3d, 0x19,
-	0xc2, 0x15, 0xd2, 0xe0, 0xe2, 0x0f, 0x4e, 0xcd, 0x4b, 0x49, 0x2d, 0x0a, 0x28, 0x4d, 0xca, 0xc9,
-	0x4c, 0xf6, 0x4e, 0xad, 0x94, 0x60, 0x02, 0xab, 0xe0, 0x2f, 0x46, 0x15, 0x16, 0xb2, 0xe0, 0x12,
-	0x87, 0xaa, 0x2c, 0xca, 0x2f, 0xcb, 0x44, 0xd1, 0xc1, 0x0c, 0xd6, 0x21, 0x5e, 0x8c, 0x5d, 0x5a,
-	0x48, 0x89, 0x8b, 0x07, 0xea, 0x10, 0xbf, 0xfc, 0xbc, 0xe4, 0x54, 0x09, 0x16, 0x05, 0x46, 0x0d,
-	0xe6, 0x20, 0x9e, 0x5c, 0x24, 0x31, 0x84, 0x3b, 0x42, 0x32, 0x73, 0x53, 0x8b, 0x4b, 0x12, 0x73,
-	0x0b, 0x24, 0x58, 0xc1, 0xca, 0xa0, 0xee, 0x80, 0x0b, 0x0b, 0xc9, 0x70, 0x71, 0x06, 0x67, 0xa6,
-	0xe7, 0x25, 0x96, 0x94, 0x16, 0xa5, 0x4a, 0xb0, 0x81, 0x6d, 0xe6, 0x2c, 0x86, 0x09, 0x24, 0xb1,
-	0x81, 0x43, 0xc2, 0x18, 0x10, 0x00, 0x00, 0xff, 0xff, 0x3d, 0xa3, 0xcc, 0x16, 0x22, 0x01, 0x00,
-	0x00,
+	// 742 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0xdb, 0x6e, 0xd3, 0x4c,
+	0x10, 0xae, 0x0f, 0xb1, 0xeb, 0xc9, 0xa1, 0xdb, 0x55, 0xff, 0xbf, 0xbe, 0x40, 0x28, 0x44, 0x08,
+	0x45, 0x51, 0x29, 0xa8, 0x48, 0x88, 0xdb, 0xb4, 0x31, 0x51, 0x94, 0xda, 0x0e, 0x9b, 0xa4, 0x50,
+	0x71, 0x51, 0x39, 0xc9, 0x36, 0x98, 0xc6, 0x07, 0xd9, 0x4e, 0xa5, 0xbc, 0x02, 0x2f, 0x81, 0x78,
+	0x53, 0xb4, 0xbb, 0xae, 0xe3, 0x94, 0xc2, 0x95, 0x67, 0xbe, 0x39, 0xed, 0x7e, 0xf3, 0xad, 0xe1,
+	0xbf, 0x80, 0xa6, 0xa9, 0xb7, 0xa4, 0x6f, 0xf2, 0xef, 0x69, 0x9c, 0x44, 0x59, 0x84, 0xf5, 0xdc,
+	0x6d, 0xfd, 0x50, 0xa1, 0x7a, 0xf1, 0xcd, 0xcb, 0x6c, 0xe1, 0x63, 0x13, 0xf4, 0x8b, 0x28, 0xcc,
+	0x68, 0x98, 0x99, 0x52, 0x53, 0x6a, 0xd7, 0x88, 0x3e, 0x17, 0x2e, 0x6e, 0xc3, 0xc1, 0x98, 0x86,
+	0x0b, 0x9a, 0x8c, 0xd6, 0xb3, 0x95, 0x3f, 0x1f, 0xd2, 0x8d, 0x29, 0xf3, 0x8c, 0x83, 0x74, 0x17,
+	0xc6, 0x1f, 0xe0, 0x38, 0xcf, 0x4c, 0xa2, 0x7b, 0x7f, 0xa7, 0x42, 0xe1, 0x15, 0xc7, 0xe9, 0xd3,
+	0x61, 0xdc, 0x82, 0x5a, 0x7e, 0x10, 0x27, 0x0a, 0xe7, 0xd4, 0x54, 0x9b, 0x52, 0x5b, 0x21, 0xb5,
+	0xa0, 0x84, 0x6d, 0xcf, 0x31, 0xf1, 0x03, 0x9a, 0x66, 0x5e, 0x10, 0x9b, 0x15, 0x9e, 0x76, 0x90,
+	0xee, 0xc2, 0xf8, 0x19, 0x18, 0x63, 0x7f, 0x19, 0x7a, 0xd9, 0x3a, 0xa1, 0xa6, 0xc6, 0x27, 0x1b,
+	0xe9, 0x03, 0x80, 0x5f, 0x80, 0x3a, 0xf4, 0xc3, 0x85, 0xa9, 0x37, 0xa5, 0x76, 0xe3, 0xac, 0x7e,
+	0xfa, 0x40, 0x10, 0x03, 0x89, 0x7a, 0xe7, 0x87, 0x0b, 0x46, 0x06, 0xa1, 0xf1, 0x6a, 0x33, 0x89,
+	0xcc, 0x7d, 0x3e, 0x42, 0x4f, 0x84, 0x8b, 0x3b, 0xa0, 0x8f, 0x92, 0xe8, 0xd6, 0x5f, 0x51, 0xd3,
+	0x68, 0x4a, 0xed, 0xea, 0x19, 0x2a, 0xea, 0x73, 0x9c, 0xe8, 0xb1, 0x30, 0x58, 0x97, 0x7e, 0x12,
+	0xad, 0xe3, 0xc1, 0xc2, 0x04, 0x41, 0xe9, 0x52, 0xb8, 0xf8, 0x3d, 0x54, 0x79, 0x64, 0x1a, 0x2f,
+	0xbc, 0x8c, 0x9a, 0x55, 0xde, 0xe9, 0xa8, 0xe8, 0x54, 0x8a, 0x91, 0xea, 0x72, 0xeb, 0xe0, 0xb7,
+	0x60, 0x08, 0x0a, 0x18, 0xa5, 0x35, 0x5e, 0x85, 0x8b, 0xaa, 0x22, 0x42, 0x8c, 0xf4, 0xc1, 0xc4,
+	0x2f, 0xa1, 0x3e, 0xa4, 0x9b, 0x3e, 0x0d, 0x69, 0xe2, 0x65, 0x7e, 0x14, 0x9a, 0xf5, 0xa6, 0xd4,
+	0xae, 0x93, 0xfa, 0x5d, 0x19, 0x6c, 0xb9, 0xc5, 0xad, 0x30, 0x06, 0xd5, 0xf1, 0x02, 0xca, 0x45,
+	0x60, 0x10, 0x35, 0xf4, 0x02, 0x8a, 0xff, 0x07, 0x6d, 0x9c, 0x79, 0xd9, 0x3a, 0xe5, 0x8b, 0x37,
+	0x88, 0x96, 0x72, 0x8f, 0x5d, 0xf0, 0x8a, 0x26, 0x29, 0x6b, 0xcb, 0xf6, 0xab, 0x12, 0xfd, 0x5e,
+	0xb8, 0xad, 0x5f, 0x52, 0x7e, 0x43, 0x9b, 0x06, 0x33, 0x9a, 0xb0, 0x8d, 0x6c, 0xb5, 0x20, 0xf4,
+	0x65, 0xc4, 0xc5, 0xf6, 0x4f, 0xe0, 0xf0, 0x4f, 0xc5, 0x08, 0x8d, 0x1d, 0xc6, 0x8f, 0x03, 0xf8,
+	0x15, 0xa8, 0x24, 0x5a, 0x51, 0x3e, 0xb2, 0x51, 0xba, 0x3f, 0x9f, 0xc7, 0x22, 0x44, 0x4d, 0xa2,
+	0x15, 0xc5, 0xcf, 0x01, 0x98, 0x0a, 0xfc, 0x70, 0xc9, 0xda, 0xa9, 0xbc, 0x1d, 0xa4, 0x05, 0xd2,
+	0xfa, 0x29, 0xef, 0x6c, 0x01, 0x37, 0x41, 0x76, 0x63, 0x7e, 0xb8, 0x46, 0x69, 0xab, 0x3c, 0xc3,
+	0x8d, 0x89, 0x1c, 0xc5, 0x05, 0x37, 0x72, 0x89, 0x9b, 0x53, 0xd0, 0xc7, 0xeb, 0xd9, 0x77, 0x3a,
+	0xcf, 0x4c, 0xe5, 0xa9, 0x35, 0x0a, 0x02, 0x88, 0x9e, 0x8a, 0x24, 0x96, 0x2f, 0xa0, 0xd4, 0x54,
+	0x9b, 0xca, 0xdf, 0xf3, 0x03, 0x91, 0x54, 0xe6, 0xb8, 0xb2, 0xc3, 0x31, 0x3e, 0x01, 0xed, 0xdc,
+	0x0b, 0x43, 0xba, 0x30, 0xb5, 0x7f, 0x34, 0xd2, 0x66, 0x3c, 0x67, 0xf7, 0x4d, 0xe8, 0x8f, 0xdf,
+	0xc4, 0x11, 0x54, 0x46, 0x49, 0x14, 0xdd, 0x72, 0xb9, 0xd7, 0x48, 0x25, 0x66, 0x4e, 0xeb, 0x6b,
+	0x49, 0x6e, 0x18, 0x81, 0xb2, 0x5d, 0x9e, 0x72, 0x47, 0x37, 0x8c, 0xe0, 0x92, 0xb0, 0x64, 0x2e,
+	0x2c, 0x58, 0x16, 0xc8, 0xee, 0x48, 0xe5, 0xd1, 0xc8, 0xce, 0x4c, 0x3c, 0x43, 0xbc, 0x0f, 0xea,
+	0xc4, 0xfa, 0x32, 0x41, 0x7b, 0xcc, 0x1a, 0x0d, 0x9c, 0x3e, 0x92, 0xb8, 0xe5, 0x3a, 0x7d, 0x24,
+	0xe3, 0x2a, 0xe8, 0x23, 0xe2, 0x7e, 0x1c, 0x5c, 0x5a, 0x48, 0xc1, 0x0d, 0x80, 0x3e, 0x71, 0xa7,
+	0xa3, 0x1b, 0x5e, 0xa0, 0x62, 0x04, 0x35, 0xe1, 0x4f, 0x47, 0xbd, 0xee, 0xc4, 0x42, 0x15, 0x5c,
+	0x07, 0x43, 0x20, 0x43, 0xeb, 0x1a, 0x69, 0x9d, 0x38, 0x7f, 0x81, 0x6e, 0x8c, 0x75, 0x50, 0xba,
+	0xbd, 0x1e, 0xda, 0xc3, 0x06, 0x54, 0x2e, 0xad, 0xee, 0x95, 0x85, 0x24, 0x0c, 0xa0, 0x11, 0xcb,
+	0x76, 0xaf, 0x2c, 0x24, 0xb3, 0xf8, 0x79, 0xd7, 0x41, 0x4a, 0x3e, 0xd1, 0x76, 0x27, 0x16, 0x52,
+	0x45, 0x86, 0xd3, 0xb5, 0x59, 0x6f, 0x1d, 0x14, 0xde, 0x95, 0x9d, 0x6e, 0x7c, 0xed, 0x5c, 0x20,
+	0x9d, 0x1d, 0x80, 0x59, 0x37, 0xc4, 0xfa, 0x34, 0xb5, 0xc6, 0x13, 0xb4, 0xdf, 0x79, 0x0d, 0x46,
+	0xa1, 0x43, 0x56, 0x6d, 0x5b, 0xf6, 0xb9, 0x45, 0xc4, 0x58, 0xf7, 0xb3, 0x63, 0x11, 0x24, 0x31,
+	0xb3, 0xdb, 0xb3, 0x07, 0x0e, 0x92, 0x67, 0x1a, 0xff, 0x2b, 0xbf, 0xfb, 0x3d, 0x00, 0xcb, 0xcc,
+	0x61, 0x6c, 0xae, 0x05, 0x00, 0x00,
 }
@@ -0,0 +1,125 @@
+// Package profiles handles multiple local identities,
+// i.e. all base mixnet clients initialised in the default location, each having its own chat data.
+package profiles
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chatconfig"
+	"github.com/nymtech/demo-mixnet-chat-client/storage"
+	clientConfig "github.com/nymtech/nym-mixnet/client/config"
+	"github.com/nymtech/nym-mixnet/constants"
+	"github.com/nymtech/nym-mixnet/helpers"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// used only to figure out the layout of the default client directories
+	placeholderID = "placeholder"
+)
+
+var (
+	ErrSameProfile = errors.New("source and destination profiles are the same")
+)
+
+// Home returns the directory containing the directories of all local clients, i.e. $HOME/.loopix/clients
+func Home() (string, error) {
+	// the base client does not expose its home directory, but its config path is always
+	// home/clientID/config/config.toml
+	configPath, err := clientConfig.DefaultConfigPath(placeholderID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(filepath.Dir(filepath.Dir(configPath))), nil
+}
+
+// Exists checks whether the client with the given ID has been initialised in the default location.
+func Exists(id string) bool {
+	configPath, err := clientConfig.DefaultConfigPath(id)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(configPath)
+	return err == nil && !info.IsDir()
+}
+
+// List returns sorted IDs of all clients initialised in the default location.
+func List() ([]string, error) {
+	home, err := Home()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(home)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && Exists(entry.Name()) {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// PublicKey loads the public key of the client.
+func PublicKey(cfg *clientConfig.Config) (*sphinx.PublicKey, error) {
+	pubKey := new(sphinx.PublicKey)
+	if err := helpers.FromPEMFile(pubKey, cfg.Client.PublicKeyFile(), constants.PublicKeyPEMType); err != nil {
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// ProviderPublicKey returns the public key of the provider the client is registered with.
+func ProviderPublicKey(cfg *clientConfig.Config) (*sphinx.PublicKey, error) {
+	// the provider is identified by its base64 encoded public key
+	b, err := base64.URLEncoding.DecodeString(cfg.Client.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	providerPubKey := new(sphinx.PublicKey)
+	if err := providerPubKey.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return providerPubKey, nil
+}
+
+// OpenStore opens the chat store of the client without starting the client itself.
+// It is going to fail if the client is currently running as the store can only be used by a single process.
+func OpenStore(cfg *clientConfig.Config, chatCfg *chatconfig.Config) (*storage.DbStore, error) {
+	appDir := chatCfg.Storage.FullDirectory(cfg.Client.FullMixAppsDir(), cfg.Client.ID)
+	return storage.NewDbStore(chatCfg.Storage.File, appDir)
+}
+
+// CopyContacts copies all aliases from one store to another, skipping the destination's own key.
+// Contacts already known by the destination keep their current names unless overwrite is set.
+// It returns the number of copied and skipped contacts.
+func CopyContacts(src, dst alias.AliasStore, dstPublicKey *sphinx.PublicKey, overwrite bool) (int, int) {
+	copied, skipped := 0, 0
+	for _, contact := range src.GetAllAliases() {
+		if dstPublicKey != nil && sphinx.CompareElements(contact.PublicKey, dstPublicKey) {
+			skipped++
+			continue
+		}
+		existing := dst.GetAlias(contact.PublicKey, contact.ProviderPublicKey)
+		if existing != nil && existing.AssignedName != "" && !overwrite {
+			skipped++
+			continue
+		}
+		dst.StoreAlias(contact)
+		copied++
+	}
+	return copied, skipped
+}
@@ -1,8 +1,130 @@
 package storage
 
-import "github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+import (
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/block"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/requests"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/verify"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"time"
+)
 
 // requirements for any store for the chat
 type ChatStore interface {
 	alias.AliasStore
+	alias.DetailsStore
+	requests.RequestStore
+	block.BlockStore
+	verify.VerificationStore
+	HistoryStore
+	LastSeenStore
+	PinStore
+	EndpointStore
+	ProfileStore
+	GroupStore
+}
+
+// HistoryStore keeps the messages exchanged with other clients
+type HistoryStore interface {
+	StoreMessage(peerPub, peerProviderPub *sphinx.PublicKey, msg *message.ChatMessage)
+	GetHistory(peerPub, peerProviderPub *sphinx.PublicKey) []*message.ChatMessage
+	GetConversations() []*Conversation
+	// MoveHistory moves all messages of one conversation to the other one, keeping them ordered by time
+	MoveHistory(fromPub, fromProviderPub, toPub, toProviderPub *sphinx.PublicKey)
+}
+
+// Conversation identifies the other party of a stored conversation
+type Conversation struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+	LastMessageAt     time.Time
+}
+
+// LastSeenStore remembers when the clients were last present in the network view
+type LastSeenStore interface {
+	SetLastSeen(peerPub, peerProviderPub *sphinx.PublicKey, at time.Time)
+	// GetLastSeen returns zero time if the client has never been seen
+	GetLastSeen(peerPub, peerProviderPub *sphinx.PublicKey) time.Time
+}
+
+// Pin is the public key of a contact as seen for the first time, which later keys are compared against
+type Pin struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+	Name              string
+	FirstSeen         time.Time
+}
+
+// PinStore remembers the keys first seen for each contact and all of the differing keys seen later on
+type PinStore interface {
+	SetPin(pin *Pin)
+	// GetPin returns nil if the public key has not been pinned
+	GetPin(targetPub *sphinx.PublicKey) *Pin
+	GetAllPins() []*Pin
+	// StoreKeyChange adds the key change to the audit log or updates it if it is already there
+	StoreKeyChange(change *types.KeyChange)
+	// GetKeyChanges returns the audit log, starting from the oldest change
+	GetKeyChanges() []*types.KeyChange
+}
+
+// Endpoint is one of the (public key, provider public key) pairs a contact can be reached at
+type Endpoint struct {
+	PublicKey         *sphinx.PublicKey
+	ProviderPublicKey *sphinx.PublicKey
+}
+
+// EndpointStore links additional endpoints to contacts. Everything about a contact, such as its alias or history,
+// is stored under its preferred endpoint, while the other endpoints just point to it.
+type EndpointStore interface {
+	LinkEndpoint(endpoint *Endpoint, contactPub, contactProviderPub *sphinx.PublicKey)
+	UnlinkEndpoint(endpoint *Endpoint)
+	// GetContactOf returns nils if the endpoint is not linked to any contact
+	GetContactOf(endpoint *Endpoint) (*sphinx.PublicKey, *sphinx.PublicKey)
+	// GetEndpoints returns the endpoints linked to the contact, not including its preferred one
+	GetEndpoints(contactPub, contactProviderPub *sphinx.PublicKey) []*Endpoint
+}
+
+// ProfileStore keeps our own profile and the ones received from other clients
+type ProfileStore interface {
+	SetOwnProfile(profile *types.Profile)
+	// GetOwnProfile returns an empty profile if it has never been set
+	GetOwnProfile() *types.Profile
+	SetProfile(peerPub, peerProviderPub *sphinx.PublicKey, profile *types.Profile)
+	// GetProfile returns nil if the client has never sent us its profile
+	GetProfile(peerPub, peerProviderPub *sphinx.PublicKey) *types.Profile
+	// SetProfileSent remembers when our profile was last sent to the client
+	SetProfileSent(peerPub, peerProviderPub *sphinx.PublicKey, at time.Time)
+	// GetProfileSent returns zero time if our profile has never been sent to the client
+	GetProfileSent(peerPub, peerProviderPub *sphinx.PublicKey) time.Time
+}
+
+// GroupStore keeps the groups we are a member of alongside their conversations
+type GroupStore interface {
+	StoreGroup(groupID []byte, group *types.Group)
+	// GetGroup returns nil if we are not a member of the group
+	GetGroup(groupID []byte) *types.Group
+	GetAllGroups() []*types.Group
+	// RemoveGroup removes the group alongside its conversation
+	RemoveGroup(groupID []byte)
+	StoreGroupMessage(groupID []byte, msg *message.ChatMessage)
+	GetGroupHistory(groupID []byte) []*message.ChatMessage
+	// SetSenderKey stores the key the member, possibly us, encrypts its messages to the group with
+	SetSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey, senderKey *types.SenderKey)
+	// GetSenderKey returns nil if the member has not sent us its key yet
+	GetSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey) *types.SenderKey
+	RemoveSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey)
+	// SetGroupTombstone remembers the version of the group we have left or have been removed from
+	SetGroupTombstone(groupID []byte, version uint64)
+	// GetGroupTombstone returns false if we have never left the group
+	GetGroupTombstone(groupID []byte) (uint64, bool)
+	// SetGroupCheckpoint stores the state of the group before its last change, nil removes it
+	SetGroupCheckpoint(groupID []byte, checkpoint *types.GroupCheckpoint)
+	// GetGroupCheckpoint returns nil if the last change of the group can not be replaced
+	GetGroupCheckpoint(groupID []byte) *types.GroupCheckpoint
+	// SetSigningKey stores the key the contact signs the changes of the groups with, as sent by the contact itself
+	SetSigningKey(targetPub, providerPub *sphinx.PublicKey, signingKey []byte)
+	// GetSigningKey returns nil if the contact has not sent us its signing key yet
+	GetSigningKey(targetPub, providerPub *sphinx.PublicKey) []byte
 }
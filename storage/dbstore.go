@@ -3,16 +3,45 @@ package storage
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
 	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/alias"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/block"
+	"github.com/nymtech/demo-mixnet-chat-client/chat-client/commands/requests"
+	"github.com/nymtech/demo-mixnet-chat-client/message"
+	"github.com/nymtech/demo-mixnet-chat-client/types"
+	"github.com/nymtech/demo-mixnet-chat-client/utils"
 	"github.com/nymtech/nym-mixnet/sphinx"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"path/filepath"
+	"time"
 )
 
 var (
-	aliasPrefix = []byte("ALIAS")
+	aliasPrefix    = []byte("ALIAS")
+	requestPrefix  = []byte("REQUEST")
+	blockedPrefix  = []byte("BLOCKED")
+	mutedPrefix    = []byte("MUTED")
+	historyPrefix  = []byte("HISTORY")
+	lastSeenPrefix = []byte("LASTSEEN")
+	notePrefix     = []byte("NOTE")
+	favPrefix      = []byte("FAVOURITE")
+	verifiedPrefix = []byte("VERIFIED")
+	pinPrefix      = []byte("PIN")
+	changePrefix   = []byte("KEYCHANGE")
+	endpointPrefix = []byte("ENDPOINT")
+	profilePrefix  = []byte("PROFILE")
+	sentPrefix     = []byte("PROFILESENT")
+	ownProfileKey  = []byte("OWNPROFILE")
+	groupPrefix    = []byte("CHATGROUP")
+	groupMsgPrefix = []byte("GROUPHISTORY")
+	senderPrefix   = []byte("SENDERKEY")
+	tombPrefix     = []byte("GROUPTOMBSTONE")
+	signingPrefix  = []byte("SIGNINGKEY")
+	rollbackPrefix = []byte("GROUPCHECKPOINT")
 )
 
 // DbStore represents all data required to interact with the storage.
@@ -33,6 +62,16 @@ func (db *DbStore) get(key []byte) []byte {
 	return res
 }
 
+// has checks whether particular key exists.
+func (db *DbStore) has(key []byte) bool {
+	key = nonNilBytes(key)
+	res, err := db.db.Has(key, nil)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
 // set sets particular key value pair.
 func (db *DbStore) set(key []byte, value []byte) {
 	key = nonNilBytes(key)
@@ -56,24 +95,34 @@ func (db *DbStore) delete(key []byte) {
 // each entry follows the structure of: [ ALIAS_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- ALIAS
 
 func (db *DbStore) makeAliasKeyEntry(targetPub, providerPub *sphinx.PublicKey) []byte {
+	return db.makeClientKeyEntry(aliasPrefix, targetPub, providerPub)
+}
+
+func (db *DbStore) recoverKeysFromAliasKeyField(key []byte) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	return db.recoverKeysFromClientKeyField(aliasPrefix, key)
+}
+
+// makeClientKeyEntry creates key of the structure: [ PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ]
+// that is shared by all client-specific entries
+func (db *DbStore) makeClientKeyEntry(prefix []byte, targetPub, providerPub *sphinx.PublicKey) []byte {
 	if targetPub == nil || providerPub == nil {
 		return []byte{}
 	}
-	key := make([]byte, len(aliasPrefix)+2*sphinx.PublicKeySize)
-	i := copy(key, aliasPrefix)
+	key := make([]byte, len(prefix)+2*sphinx.PublicKeySize)
+	i := copy(key, prefix)
 	i += copy(key[i:], targetPub.Bytes())
 	copy(key[i:], providerPub.Bytes())
 	return key
 }
 
-func (db *DbStore) recoverKeysFromAliasKeyField(key []byte) (*sphinx.PublicKey, *sphinx.PublicKey) {
-	if len(key) != len(aliasPrefix)+2*sphinx.PublicKeySize {
+func (db *DbStore) recoverKeysFromClientKeyField(prefix []byte, key []byte) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	if len(key) != len(prefix)+2*sphinx.PublicKeySize {
 		return nil, nil
 	}
 	targetPub := new(sphinx.PublicKey)
 	providerPub := new(sphinx.PublicKey)
 
-	i := len(aliasPrefix)
+	i := len(prefix)
 	if targetPub.UnmarshalBinary(key[i:i+sphinx.PublicKeySize]) != nil {
 		return nil, nil
 	}
@@ -161,6 +210,882 @@ func (db *DbStore) RemoveAllAliases() {
 	}
 }
 
+// --------- REQUEST RELATED -----------
+
+// Each message held from an unknown sender is stored separately, so that they can be easily appended
+// each entry follows the structure of: [ REQUEST_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY || RECEIVED_AT ] -- MESSAGE
+// where RECEIVED_AT is the big endian unix nano timestamp so that messages of each sender are kept in order
+
+func (db *DbStore) makeRequestSenderPrefix(targetPub, providerPub *sphinx.PublicKey) []byte {
+	return db.makeClientKeyEntry(requestPrefix, targetPub, providerPub)
+}
+
+func (db *DbStore) makeRequestKeyEntry(targetPub, providerPub *sphinx.PublicKey, receivedAt int64) []byte {
+	senderPrefix := db.makeRequestSenderPrefix(targetPub, providerPub)
+	key := make([]byte, len(senderPrefix)+8)
+	i := copy(key, senderPrefix)
+	binary.BigEndian.PutUint64(key[i:], uint64(receivedAt))
+	return key
+}
+
+func (db *DbStore) StoreRequestMessage(msg *message.ChatMessage) {
+	senderPub, senderProvPub := utils.KeysFromBytes(msg.SenderPublicKey, msg.SenderProviderPublicKey)
+	if senderPub == nil || senderProvPub == nil {
+		return
+	}
+	msgB, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+	db.set(db.makeRequestKeyEntry(senderPub, senderProvPub, time.Now().UnixNano()), msgB)
+}
+
+func (db *DbStore) HasRequest(targetPub, providerPub *sphinx.PublicKey) bool {
+	senderPrefix := db.makeRequestSenderPrefix(targetPub, providerPub)
+	if len(senderPrefix) == 0 {
+		return false
+	}
+	iter := db.db.NewIterator(util.BytesPrefix(senderPrefix), nil)
+	defer iter.Release()
+	return iter.Next()
+}
+
+// GetAllRequests returns the held messages grouped by their senders.
+func (db *DbStore) GetAllRequests() []*requests.Request {
+	iter := db.db.NewIterator(util.BytesPrefix(requestPrefix), nil)
+	allRequests := make([]*requests.Request, 0, 10)
+	var currentRequest *requests.Request
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(requestPrefix)+2*sphinx.PublicKeySize+8 {
+			continue
+		}
+		parsedMsg := &message.ChatMessage{}
+		if err := proto.Unmarshal(iter.Value(), parsedMsg); err != nil {
+			continue
+		}
+		// entries are sorted by key so all messages of particular sender are next to each other
+		senderPrefix := key[:len(requestPrefix)+2*sphinx.PublicKeySize]
+		if currentRequest == nil || !bytes.Equal(senderPrefix, db.makeRequestSenderPrefix(currentRequest.PublicKey, currentRequest.ProviderPublicKey)) {
+			targetPub, providerPub := db.recoverKeysFromClientKeyField(requestPrefix, senderPrefix)
+			if targetPub == nil || providerPub == nil {
+				continue
+			}
+			currentRequest = &requests.Request{
+				PublicKey:         targetPub,
+				ProviderPublicKey: providerPub,
+				Messages:          make([]*message.ChatMessage, 0, 1),
+			}
+			allRequests = append(allRequests, currentRequest)
+		}
+		currentRequest.Messages = append(currentRequest.Messages, parsedMsg)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+
+	return allRequests
+}
+
+func (db *DbStore) RemoveRequest(targetPub, providerPub *sphinx.PublicKey) {
+	senderPrefix := db.makeRequestSenderPrefix(targetPub, providerPub)
+	// the empty prefix would match the entire database
+	if len(senderPrefix) == 0 {
+		return
+	}
+	db.removeAllWithPrefix(senderPrefix)
+}
+
+// ArchiveRequest copies the messages held from the sender into the history of the conversation with it,
+// keeping the times they were received at
+func (db *DbStore) ArchiveRequest(targetPub, providerPub *sphinx.PublicKey) {
+	senderPrefix := db.makeRequestSenderPrefix(targetPub, providerPub)
+	conversationPrefix := db.makeClientKeyEntry(historyPrefix, targetPub, providerPub)
+	if len(senderPrefix) == 0 || len(conversationPrefix) == 0 {
+		return
+	}
+
+	batch := new(leveldb.Batch)
+	iter := db.db.NewIterator(util.BytesPrefix(senderPrefix), nil)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(senderPrefix)+8 {
+			continue
+		}
+		batch.Put(append(append([]byte{}, conversationPrefix...), key[len(senderPrefix):]...), append([]byte{}, iter.Value()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	if err := db.db.Write(batch, nil); err != nil {
+		panic(err)
+	}
+}
+
+func (db *DbStore) RemoveAllRequests() {
+	db.removeAllWithPrefix(requestPrefix)
+}
+
+func (db *DbStore) removeAllWithPrefix(prefix []byte) {
+	if len(prefix) == 0 {
+		return
+	}
+	iter := db.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		db.delete(iter.Key())
+	}
+
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+}
+
+// --------- BLOCK RELATED -----------
+
+// Both blocked and muted clients are stored in the same manner with the empty value
+// each entry follows the structure of: [ BLOCKED_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- {}
+// or: [ MUTED_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- {}
+
+func (db *DbStore) getAllClientEntries(prefix []byte) []*block.Entry {
+	iter := db.db.NewIterator(util.BytesPrefix(prefix), nil)
+	entries := make([]*block.Entry, 0, 10)
+	for iter.Next() {
+		targetPub, providerPub := db.recoverKeysFromClientKeyField(prefix, iter.Key())
+		if targetPub != nil && providerPub != nil {
+			entries = append(entries, &block.Entry{
+				PublicKey:         targetPub,
+				ProviderPublicKey: providerPub,
+			})
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+
+	return entries
+}
+
+func (db *DbStore) Block(targetPub, providerPub *sphinx.PublicKey) {
+	db.set(db.makeClientKeyEntry(blockedPrefix, targetPub, providerPub), nil)
+	// there is no point in keeping their contact requests anymore
+	db.RemoveRequest(targetPub, providerPub)
+}
+
+func (db *DbStore) Unblock(targetPub, providerPub *sphinx.PublicKey) {
+	db.delete(db.makeClientKeyEntry(blockedPrefix, targetPub, providerPub))
+}
+
+func (db *DbStore) IsBlocked(targetPub, providerPub *sphinx.PublicKey) bool {
+	return db.has(db.makeClientKeyEntry(blockedPrefix, targetPub, providerPub))
+}
+
+func (db *DbStore) GetAllBlocked() []*block.Entry {
+	return db.getAllClientEntries(blockedPrefix)
+}
+
+func (db *DbStore) UnblockAll() {
+	db.removeAllWithPrefix(blockedPrefix)
+}
+
+func (db *DbStore) Mute(targetPub, providerPub *sphinx.PublicKey) {
+	db.set(db.makeClientKeyEntry(mutedPrefix, targetPub, providerPub), nil)
+}
+
+func (db *DbStore) Unmute(targetPub, providerPub *sphinx.PublicKey) {
+	db.delete(db.makeClientKeyEntry(mutedPrefix, targetPub, providerPub))
+}
+
+func (db *DbStore) IsMuted(targetPub, providerPub *sphinx.PublicKey) bool {
+	return db.has(db.makeClientKeyEntry(mutedPrefix, targetPub, providerPub))
+}
+
+func (db *DbStore) GetAllMuted() []*block.Entry {
+	return db.getAllClientEntries(mutedPrefix)
+}
+
+func (db *DbStore) UnmuteAll() {
+	db.removeAllWithPrefix(mutedPrefix)
+}
+
+// --------- HISTORY RELATED -----------
+
+// Messages are grouped by the other party of the conversation
+// each entry follows the structure of: [ HISTORY_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY || STORED_AT ] -- MESSAGE
+
+func (db *DbStore) StoreMessage(peerPub, peerProviderPub *sphinx.PublicKey, msg *message.ChatMessage) {
+	conversationPrefix := db.makeClientKeyEntry(historyPrefix, peerPub, peerProviderPub)
+	if len(conversationPrefix) == 0 {
+		return
+	}
+	msgB, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+	key := make([]byte, len(conversationPrefix)+8)
+	i := copy(key, conversationPrefix)
+	binary.BigEndian.PutUint64(key[i:], uint64(time.Now().UnixNano()))
+	db.set(key, msgB)
+}
+
+func (db *DbStore) GetHistory(peerPub, peerProviderPub *sphinx.PublicKey) []*message.ChatMessage {
+	msgs := make([]*message.ChatMessage, 0, 10)
+	conversationPrefix := db.makeClientKeyEntry(historyPrefix, peerPub, peerProviderPub)
+	if len(conversationPrefix) == 0 {
+		return msgs
+	}
+	iter := db.db.NewIterator(util.BytesPrefix(conversationPrefix), nil)
+	for iter.Next() {
+		parsedMsg := &message.ChatMessage{}
+		if err := proto.Unmarshal(iter.Value(), parsedMsg); err == nil {
+			msgs = append(msgs, parsedMsg)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+
+	return msgs
+}
+
+// GetConversations returns the other parties of all stored conversations alongside the time of their last message
+func (db *DbStore) GetConversations() []*Conversation {
+	conversations := make([]*Conversation, 0)
+	peerPrefixLen := len(historyPrefix) + 2*sphinx.PublicKeySize
+	iter := db.db.NewIterator(util.BytesPrefix(historyPrefix), nil)
+	for ok := iter.First(); ok; ok = iter.Next() {
+		if len(iter.Key()) != peerPrefixLen+8 {
+			continue
+		}
+		peerPrefix := append([]byte{}, iter.Key()[:peerPrefixLen]...)
+		// skip straight to the last message of the conversation
+		if iter.Seek(util.BytesPrefix(peerPrefix).Limit) {
+			iter.Prev()
+		} else {
+			iter.Last()
+		}
+		key := iter.Key()
+		if len(key) != peerPrefixLen+8 || !bytes.HasPrefix(key, peerPrefix) {
+			continue
+		}
+		targetPub, providerPub := db.recoverKeysFromClientKeyField(historyPrefix, peerPrefix)
+		if targetPub == nil || providerPub == nil {
+			continue
+		}
+		conversations = append(conversations, &Conversation{
+			PublicKey:         targetPub,
+			ProviderPublicKey: providerPub,
+			LastMessageAt:     time.Unix(0, int64(binary.BigEndian.Uint64(key[peerPrefixLen:]))),
+		})
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+
+	return conversations
+}
+
+func (db *DbStore) MoveHistory(fromPub, fromProviderPub, toPub, toProviderPub *sphinx.PublicKey) {
+	fromPrefix := db.makeClientKeyEntry(historyPrefix, fromPub, fromProviderPub)
+	toPrefix := db.makeClientKeyEntry(historyPrefix, toPub, toProviderPub)
+	if len(fromPrefix) == 0 || len(toPrefix) == 0 || bytes.Equal(fromPrefix, toPrefix) {
+		return
+	}
+
+	// the keys end with the time the messages were stored at, so they interleave with the existing ones
+	batch := new(leveldb.Batch)
+	iter := db.db.NewIterator(util.BytesPrefix(fromPrefix), nil)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(fromPrefix)+8 {
+			continue
+		}
+		newKey := append(append([]byte{}, toPrefix...), key[len(fromPrefix):]...)
+		batch.Put(newKey, append([]byte{}, iter.Value()...))
+		batch.Delete(append([]byte{}, key...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	if err := db.db.Write(batch, nil); err != nil {
+		panic(err)
+	}
+}
+
+// --------- DETAILS RELATED -----------
+
+// each note follows the structure of: [ NOTE_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- NOTE
+// while favourites are just: [ FAVOURITE_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- nil
+
+func (db *DbStore) SetNote(targetPub, providerPub *sphinx.PublicKey, note string) {
+	key := db.makeClientKeyEntry(notePrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return
+	}
+	if note == "" {
+		db.delete(key)
+		return
+	}
+	db.set(key, []byte(note))
+}
+
+func (db *DbStore) GetNote(targetPub, providerPub *sphinx.PublicKey) string {
+	key := db.makeClientKeyEntry(notePrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return ""
+	}
+	return string(db.get(key))
+}
+
+func (db *DbStore) SetFavourite(targetPub, providerPub *sphinx.PublicKey, favourite bool) {
+	key := db.makeClientKeyEntry(favPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return
+	}
+	if favourite {
+		db.set(key, nil)
+	} else {
+		db.delete(key)
+	}
+}
+
+func (db *DbStore) IsFavourite(targetPub, providerPub *sphinx.PublicKey) bool {
+	key := db.makeClientKeyEntry(favPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return false
+	}
+	return db.has(key)
+}
+
+// --------- VERIFICATION RELATED -----------
+
+// each verified client is just: [ VERIFIED_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- nil
+
+func (db *DbStore) SetVerified(targetPub, providerPub *sphinx.PublicKey, verified bool) {
+	key := db.makeClientKeyEntry(verifiedPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return
+	}
+	if verified {
+		db.set(key, nil)
+	} else {
+		db.delete(key)
+	}
+}
+
+func (db *DbStore) IsVerified(targetPub, providerPub *sphinx.PublicKey) bool {
+	key := db.makeClientKeyEntry(verifiedPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return false
+	}
+	return db.has(key)
+}
+
+// --------- LAST SEEN RELATED -----------
+
+// each entry follows the structure of: [ LASTSEEN_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- UNIX_NANO
+
+func (db *DbStore) SetLastSeen(peerPub, peerProviderPub *sphinx.PublicKey, at time.Time) {
+	key := db.makeClientKeyEntry(lastSeenPrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(at.UnixNano()))
+	db.set(key, value)
+}
+
+func (db *DbStore) GetLastSeen(peerPub, peerProviderPub *sphinx.PublicKey) time.Time {
+	key := db.makeClientKeyEntry(lastSeenPrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return time.Time{}
+	}
+	value := db.get(key)
+	if len(value) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+}
+
+// --------- PINNING RELATED -----------
+
+// each pin follows the structure of: [ PIN_PREFIX || PUBLIC_KEY ] -- PROVIDER_PUBLIC_KEY || FIRST_SEEN_UNIX_NANO || NAME
+// while each key change is: [ KEYCHANGE_PREFIX || UNIX_NANO ] -- JSON_ENCODED_KEY_CHANGE
+
+func (db *DbStore) makePinKeyEntry(targetPub *sphinx.PublicKey) []byte {
+	if targetPub == nil {
+		return []byte{}
+	}
+	return append(append([]byte{}, pinPrefix...), targetPub.Bytes()...)
+}
+
+func (db *DbStore) decodePin(key, value []byte) *Pin {
+	if len(key) != len(pinPrefix)+sphinx.PublicKeySize || len(value) < sphinx.PublicKeySize+8 {
+		return nil
+	}
+	targetPub, providerPub := utils.KeysFromBytes(key[len(pinPrefix):], value[:sphinx.PublicKeySize])
+	if targetPub == nil || providerPub == nil {
+		return nil
+	}
+	return &Pin{
+		PublicKey:         targetPub,
+		ProviderPublicKey: providerPub,
+		FirstSeen:         time.Unix(0, int64(binary.BigEndian.Uint64(value[sphinx.PublicKeySize:sphinx.PublicKeySize+8]))),
+		Name:              string(value[sphinx.PublicKeySize+8:]),
+	}
+}
+
+func (db *DbStore) SetPin(pin *Pin) {
+	key := db.makePinKeyEntry(pin.PublicKey)
+	if len(key) == 0 || pin.ProviderPublicKey == nil {
+		return
+	}
+	value := make([]byte, sphinx.PublicKeySize+8, sphinx.PublicKeySize+8+len(pin.Name))
+	copy(value, pin.ProviderPublicKey.Bytes())
+	binary.BigEndian.PutUint64(value[sphinx.PublicKeySize:], uint64(pin.FirstSeen.UnixNano()))
+	db.set(key, append(value, pin.Name...))
+}
+
+func (db *DbStore) GetPin(targetPub *sphinx.PublicKey) *Pin {
+	key := db.makePinKeyEntry(targetPub)
+	if len(key) == 0 {
+		return nil
+	}
+	value := db.get(key)
+	if value == nil {
+		return nil
+	}
+	return db.decodePin(key, value)
+}
+
+func (db *DbStore) GetAllPins() []*Pin {
+	iter := db.db.NewIterator(util.BytesPrefix(pinPrefix), nil)
+	pins := make([]*Pin, 0, 10)
+	for iter.Next() {
+		if pin := db.decodePin(iter.Key(), iter.Value()); pin != nil {
+			pins = append(pins, pin)
+		}
+	}
+
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	return pins
+}
+
+func (db *DbStore) makeKeyChangeKeyEntry(at time.Time) []byte {
+	key := make([]byte, len(changePrefix)+8)
+	i := copy(key, changePrefix)
+	binary.BigEndian.PutUint64(key[i:], uint64(at.UnixNano()))
+	return key
+}
+
+func (db *DbStore) StoreKeyChange(change *types.KeyChange) {
+	value, err := json.Marshal(change)
+	if err != nil {
+		panic(err)
+	}
+	db.set(db.makeKeyChangeKeyEntry(change.At), value)
+}
+
+func (db *DbStore) GetKeyChanges() []*types.KeyChange {
+	// the keys are ordered by time, so are the changes
+	iter := db.db.NewIterator(util.BytesPrefix(changePrefix), nil)
+	changes := make([]*types.KeyChange, 0, 10)
+	for iter.Next() {
+		change := &types.KeyChange{}
+		if err := json.Unmarshal(iter.Value(), change); err != nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	return changes
+}
+
+// --------- ENDPOINT RELATED -----------
+
+// each additional endpoint of a contact follows the structure of:
+// [ ENDPOINT_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- CONTACT_PUBLIC_KEY || CONTACT_PROVIDER_PUBLIC_KEY
+
+func (db *DbStore) LinkEndpoint(endpoint *Endpoint, contactPub, contactProviderPub *sphinx.PublicKey) {
+	key := db.makeClientKeyEntry(endpointPrefix, endpoint.PublicKey, endpoint.ProviderPublicKey)
+	if len(key) == 0 || contactPub == nil || contactProviderPub == nil {
+		return
+	}
+	db.set(key, append(append([]byte{}, contactPub.Bytes()...), contactProviderPub.Bytes()...))
+}
+
+func (db *DbStore) UnlinkEndpoint(endpoint *Endpoint) {
+	key := db.makeClientKeyEntry(endpointPrefix, endpoint.PublicKey, endpoint.ProviderPublicKey)
+	if len(key) == 0 {
+		return
+	}
+	db.delete(key)
+}
+
+func (db *DbStore) decodeContactKeys(value []byte) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	if len(value) != 2*sphinx.PublicKeySize {
+		return nil, nil
+	}
+	return utils.KeysFromBytes(value[:sphinx.PublicKeySize], value[sphinx.PublicKeySize:])
+}
+
+func (db *DbStore) GetContactOf(endpoint *Endpoint) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	key := db.makeClientKeyEntry(endpointPrefix, endpoint.PublicKey, endpoint.ProviderPublicKey)
+	if len(key) == 0 {
+		return nil, nil
+	}
+	return db.decodeContactKeys(db.get(key))
+}
+
+func (db *DbStore) GetEndpoints(contactPub, contactProviderPub *sphinx.PublicKey) []*Endpoint {
+	if contactPub == nil || contactProviderPub == nil {
+		return nil
+	}
+	contactValue := append(append([]byte{}, contactPub.Bytes()...), contactProviderPub.Bytes()...)
+	iter := db.db.NewIterator(util.BytesPrefix(endpointPrefix), nil)
+	endpoints := make([]*Endpoint, 0, 2)
+	for iter.Next() {
+		if !bytes.Equal(iter.Value(), contactValue) {
+			continue
+		}
+		targetPub, providerPub := db.recoverKeysFromClientKeyField(endpointPrefix, iter.Key())
+		if targetPub == nil || providerPub == nil {
+			continue
+		}
+		endpoints = append(endpoints, &Endpoint{
+			PublicKey:         targetPub,
+			ProviderPublicKey: providerPub,
+		})
+	}
+
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	return endpoints
+}
+
+// --------- PROFILE RELATED -----------
+
+// our own profile is stored under just: [ OWNPROFILE ] -- JSON_ENCODED_PROFILE
+// while the received ones follow the structure of: [ PROFILE_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- JSON_ENCODED_PROFILE
+// and the time our profile was sent at is: [ PROFILESENT_PREFIX || PUBLIC_KEY || PROVIDER_PUBLIC_KEY ] -- UNIX_NANO
+
+func (db *DbStore) setProfile(key []byte, profile *types.Profile) {
+	value, err := json.Marshal(profile)
+	if err != nil {
+		panic(err)
+	}
+	db.set(key, value)
+}
+
+func (db *DbStore) getProfile(key []byte) *types.Profile {
+	value := db.get(key)
+	if value == nil {
+		return nil
+	}
+	profile := &types.Profile{}
+	if err := json.Unmarshal(value, profile); err != nil {
+		return nil
+	}
+	return profile
+}
+
+func (db *DbStore) SetOwnProfile(profile *types.Profile) {
+	db.setProfile(ownProfileKey, profile)
+}
+
+func (db *DbStore) GetOwnProfile() *types.Profile {
+	if profile := db.getProfile(ownProfileKey); profile != nil {
+		return profile
+	}
+	return &types.Profile{}
+}
+
+func (db *DbStore) SetProfile(peerPub, peerProviderPub *sphinx.PublicKey, profile *types.Profile) {
+	key := db.makeClientKeyEntry(profilePrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return
+	}
+	db.setProfile(key, profile)
+}
+
+func (db *DbStore) GetProfile(peerPub, peerProviderPub *sphinx.PublicKey) *types.Profile {
+	key := db.makeClientKeyEntry(profilePrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return nil
+	}
+	return db.getProfile(key)
+}
+
+func (db *DbStore) SetProfileSent(peerPub, peerProviderPub *sphinx.PublicKey, at time.Time) {
+	key := db.makeClientKeyEntry(sentPrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(at.UnixNano()))
+	db.set(key, value)
+}
+
+func (db *DbStore) GetProfileSent(peerPub, peerProviderPub *sphinx.PublicKey) time.Time {
+	key := db.makeClientKeyEntry(sentPrefix, peerPub, peerProviderPub)
+	if len(key) == 0 {
+		return time.Time{}
+	}
+	value := db.get(key)
+	if len(value) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+}
+
+// --------- GROUP RELATED -----------
+
+// each group follows the structure of: [ CHATGROUP_PREFIX || GROUP_ID ] -- JSON_ENCODED_GROUP
+// while its messages are: [ GROUPHISTORY_PREFIX || GROUP_ID || STORED_AT ] -- MESSAGE
+// sender keys of the members, including us, are: [ SENDERKEY_PREFIX || GROUP_ID || MEMBER_PUB || MEMBER_PROV ] -- JSON_ENCODED_KEY
+// the state before the last change is: [ GROUPCHECKPOINT_PREFIX || GROUP_ID ] -- JSON_ENCODED_CHECKPOINT
+// the groups we have left are: [ GROUPTOMBSTONE_PREFIX || GROUP_ID ] -- VERSION
+// signing keys of the contacts are: [ SIGNINGKEY_PREFIX || CONTACT_PUB || CONTACT_PROV ] -- SIGNING_KEY
+
+func (db *DbStore) makeGroupKeyEntry(prefix, groupID []byte) []byte {
+	if len(groupID) == 0 {
+		return []byte{}
+	}
+	return append(append([]byte{}, prefix...), groupID...)
+}
+
+func (db *DbStore) StoreGroup(groupID []byte, group *types.Group) {
+	key := db.makeGroupKeyEntry(groupPrefix, groupID)
+	if len(key) == 0 {
+		return
+	}
+	value, err := json.Marshal(group)
+	if err != nil {
+		panic(err)
+	}
+	db.set(key, value)
+}
+
+func (db *DbStore) GetGroup(groupID []byte) *types.Group {
+	key := db.makeGroupKeyEntry(groupPrefix, groupID)
+	if len(key) == 0 {
+		return nil
+	}
+	value := db.get(key)
+	if value == nil {
+		return nil
+	}
+	group := &types.Group{}
+	if err := json.Unmarshal(value, group); err != nil {
+		return nil
+	}
+	return group
+}
+
+func (db *DbStore) GetAllGroups() []*types.Group {
+	iter := db.db.NewIterator(util.BytesPrefix(groupPrefix), nil)
+	groups := make([]*types.Group, 0, 10)
+	for iter.Next() {
+		group := &types.Group{}
+		if err := json.Unmarshal(iter.Value(), group); err == nil {
+			groups = append(groups, group)
+		}
+	}
+
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+	return groups
+}
+
+func (db *DbStore) RemoveGroup(groupID []byte) {
+	key := db.makeGroupKeyEntry(groupPrefix, groupID)
+	if len(key) == 0 {
+		return
+	}
+	batch := new(leveldb.Batch)
+	batch.Delete(key)
+	batch.Delete(db.makeGroupKeyEntry(rollbackPrefix, groupID))
+	for _, prefix := range [][]byte{groupMsgPrefix, senderPrefix} {
+		iter := db.db.NewIterator(util.BytesPrefix(db.makeGroupKeyEntry(prefix, groupID)), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			panic(err)
+		}
+	}
+	if err := db.db.Write(batch, nil); err != nil {
+		panic(err)
+	}
+}
+
+func (db *DbStore) StoreGroupMessage(groupID []byte, msg *message.ChatMessage) {
+	groupMsgKeyPrefix := db.makeGroupKeyEntry(groupMsgPrefix, groupID)
+	if len(groupMsgKeyPrefix) == 0 {
+		return
+	}
+	msgB, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+	key := make([]byte, len(groupMsgKeyPrefix)+8)
+	i := copy(key, groupMsgKeyPrefix)
+	binary.BigEndian.PutUint64(key[i:], uint64(time.Now().UnixNano()))
+	db.set(key, msgB)
+}
+
+func (db *DbStore) GetGroupHistory(groupID []byte) []*message.ChatMessage {
+	msgs := make([]*message.ChatMessage, 0, 10)
+	groupMsgKeyPrefix := db.makeGroupKeyEntry(groupMsgPrefix, groupID)
+	if len(groupMsgKeyPrefix) == 0 {
+		return msgs
+	}
+	iter := db.db.NewIterator(util.BytesPrefix(groupMsgKeyPrefix), nil)
+	for iter.Next() {
+		parsedMsg := &message.ChatMessage{}
+		if err := proto.Unmarshal(iter.Value(), parsedMsg); err == nil {
+			msgs = append(msgs, parsedMsg)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		panic(err)
+	}
+
+	return msgs
+}
+
+func (db *DbStore) makeSenderKeyEntry(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey) []byte {
+	if len(groupID) == 0 {
+		return []byte{}
+	}
+	return db.makeClientKeyEntry(db.makeGroupKeyEntry(senderPrefix, groupID), memberPub, memberProviderPub)
+}
+
+func (db *DbStore) SetSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey, senderKey *types.SenderKey) {
+	key := db.makeSenderKeyEntry(groupID, memberPub, memberProviderPub)
+	if len(key) == 0 {
+		return
+	}
+	value, err := json.Marshal(senderKey)
+	if err != nil {
+		panic(err)
+	}
+	db.set(key, value)
+}
+
+func (db *DbStore) GetSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey) *types.SenderKey {
+	key := db.makeSenderKeyEntry(groupID, memberPub, memberProviderPub)
+	if len(key) == 0 {
+		return nil
+	}
+	value := db.get(key)
+	if value == nil {
+		return nil
+	}
+	senderKey := &types.SenderKey{}
+	if err := json.Unmarshal(value, senderKey); err != nil {
+		return nil
+	}
+	return senderKey
+}
+
+func (db *DbStore) RemoveSenderKey(groupID []byte, memberPub, memberProviderPub *sphinx.PublicKey) {
+	key := db.makeSenderKeyEntry(groupID, memberPub, memberProviderPub)
+	if len(key) == 0 {
+		return
+	}
+	db.delete(key)
+}
+
+func (db *DbStore) SetGroupCheckpoint(groupID []byte, checkpoint *types.GroupCheckpoint) {
+	key := db.makeGroupKeyEntry(rollbackPrefix, groupID)
+	if len(key) == 0 {
+		return
+	}
+	if checkpoint == nil {
+		db.delete(key)
+		return
+	}
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		panic(err)
+	}
+	db.set(key, value)
+}
+
+func (db *DbStore) GetGroupCheckpoint(groupID []byte) *types.GroupCheckpoint {
+	key := db.makeGroupKeyEntry(rollbackPrefix, groupID)
+	if len(key) == 0 {
+		return nil
+	}
+	value := db.get(key)
+	if value == nil {
+		return nil
+	}
+	checkpoint := &types.GroupCheckpoint{}
+	if err := json.Unmarshal(value, checkpoint); err != nil || checkpoint.Group == nil {
+		return nil
+	}
+	return checkpoint
+}
+
+func (db *DbStore) SetGroupTombstone(groupID []byte, version uint64) {
+	key := db.makeGroupKeyEntry(tombPrefix, groupID)
+	if len(key) == 0 {
+		return
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, version)
+	db.set(key, value)
+}
+
+func (db *DbStore) GetGroupTombstone(groupID []byte) (uint64, bool) {
+	key := db.makeGroupKeyEntry(tombPrefix, groupID)
+	if len(key) == 0 {
+		return 0, false
+	}
+	value := db.get(key)
+	if len(value) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(value), true
+}
+
+func (db *DbStore) SetSigningKey(targetPub, providerPub *sphinx.PublicKey, signingKey []byte) {
+	key := db.makeClientKeyEntry(signingPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return
+	}
+	db.set(key, signingKey)
+}
+
+func (db *DbStore) GetSigningKey(targetPub, providerPub *sphinx.PublicKey) []byte {
+	key := db.makeClientKeyEntry(signingPrefix, targetPub, providerPub)
+	if len(key) == 0 {
+		return nil
+	}
+	return db.get(key)
+}
+
 // Close closes the database connection. It should be called upon server shutdown.
 func (db *DbStore) Close() {
 	db.db.Close()
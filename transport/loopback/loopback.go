@@ -0,0 +1,316 @@
+// Package loopback implements an in-process network for the chat clients, so that they could
+// talk to each other without a live mixnet and directory server, for example in end-to-end tests.
+// It can simulate delay, loss and reordering of the messages.
+package loopback
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/demo-mixnet-chat-client/transport"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	providerID = "loopback-provider"
+)
+
+var (
+	ErrNotStarted       = errors.New("the client has not been started")
+	ErrUnknownRecipient = errors.New("the recipient is not connected to the network")
+)
+
+// Config specifies behaviour of the simulated network
+type Config struct {
+	// Delay is the base delay applied to every message.
+	Delay time.Duration
+	// Jitter is the maximum random delay added on top of the base one.
+	Jitter time.Duration
+	// LossRate is the probability, from 0 to 1, of a message being silently dropped.
+	LossRate float64
+	// ReorderRate is the probability, from 0 to 1, of a message being held back
+	// for an additional ReorderDelay, so that it is likely to arrive after the subsequent ones.
+	ReorderRate  float64
+	ReorderDelay time.Duration
+	// Seed is the seed used for all random decisions. Zero means the current time is used instead.
+	Seed int64
+}
+
+// Network connects all of its clients in memory
+type Network struct {
+	sync.Mutex
+	cfg         Config
+	rng         *rand.Rand
+	providerKey []byte
+	clients     []*Client
+	wg          sync.WaitGroup
+}
+
+// NewNetwork creates new empty in-memory network.
+func NewNetwork(cfg Config) (*Network, error) {
+	_, providerKey, err := sphinx.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Network{
+		cfg:         cfg,
+		rng:         rand.New(rand.NewSource(seed)),
+		providerKey: providerKey.Bytes(),
+		clients:     make([]*Client, 0, 4),
+	}, nil
+}
+
+// NewClient creates new client with freshly generated keys. The client joins the network once started.
+func (n *Network) NewClient() (*Client, error) {
+	prvKey, pubKey, err := sphinx.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		network:    n,
+		privateKey: prvKey.Bytes(),
+		publicKey:  pubKey.Bytes(),
+		buffered:   make([][]byte, 0, 20),
+		notify:     make(chan struct{}, 1),
+		received:   make(chan []byte),
+	}
+	n.Lock()
+	n.clients = append(n.clients, c)
+	n.Unlock()
+	return c, nil
+}
+
+// Wait waits until all of the messages currently in flight are either delivered or dropped.
+func (n *Network) Wait() {
+	n.wg.Wait()
+}
+
+func (n *Network) providerConfig() config.MixConfig {
+	return config.MixConfig{
+		Id:     providerID,
+		PubKey: n.providerKey,
+	}
+}
+
+func (n *Network) clientConfig(c *Client) config.ClientConfig {
+	provider := n.providerConfig()
+	return config.ClientConfig{
+		Id:       base64.URLEncoding.EncodeToString(c.publicKey),
+		PubKey:   c.publicKey,
+		Provider: &provider,
+	}
+}
+
+func (n *Network) startedClients() []config.ClientConfig {
+	n.Lock()
+	defer n.Unlock()
+	clients := make([]config.ClientConfig, 0, len(n.clients))
+	for _, c := range n.clients {
+		if c.isStarted() {
+			clients = append(clients, n.clientConfig(c))
+		}
+	}
+	return clients
+}
+
+func (n *Network) findClient(recipient config.ClientConfig) *Client {
+	n.Lock()
+	defer n.Unlock()
+	if recipient.Provider == nil || !bytes.Equal(recipient.Provider.PubKey, n.providerKey) {
+		return nil
+	}
+	for _, c := range n.clients {
+		if bytes.Equal(c.publicKey, recipient.PubKey) {
+			return c
+		}
+	}
+	return nil
+}
+
+// deliveryDelay decides the fate of the message. Returns false if the message should be dropped.
+func (n *Network) deliveryDelay() (time.Duration, bool) {
+	n.Lock()
+	defer n.Unlock()
+	if n.rng.Float64() < n.cfg.LossRate {
+		return 0, false
+	}
+	delay := n.cfg.Delay
+	if n.cfg.Jitter > 0 {
+		delay += time.Duration(n.rng.Int63n(int64(n.cfg.Jitter)))
+	}
+	if n.rng.Float64() < n.cfg.ReorderRate {
+		delay += n.cfg.ReorderDelay
+	}
+	return delay, true
+}
+
+func (n *Network) send(message []byte, recipient config.ClientConfig) error {
+	target := n.findClient(recipient)
+	if target == nil {
+		return ErrUnknownRecipient
+	}
+	delay, ok := n.deliveryDelay()
+	if !ok {
+		return nil
+	}
+	// copy the message as the sender might reuse the slice
+	msg := make([]byte, len(message))
+	copy(msg, message)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		target.addNewMessage(msg)
+	}()
+	return nil
+}
+
+// Client is a Transport connected to the in-memory network
+type Client struct {
+	network    *Network
+	privateKey []byte
+	publicKey  []byte
+	// notify is signalled whenever new message is buffered
+	notify   chan struct{}
+	received chan []byte
+
+	sync.Mutex
+	haltedCh chan struct{}
+	buffered [][]byte
+}
+
+func (c *Client) isStarted() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.haltedCh != nil
+}
+
+func (c *Client) addNewMessage(msg []byte) {
+	c.Lock()
+	// just like with the actual provider, messages to offline clients are kept until they come back
+	c.buffered = append(c.buffered, msg)
+	c.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+		// there is already a pending notification
+	}
+}
+
+func (c *Client) takeBuffered() [][]byte {
+	c.Lock()
+	defer c.Unlock()
+	msgs := c.buffered
+	c.buffered = make([][]byte, 0, 20)
+	return msgs
+}
+
+func (c *Client) forwardReceived(haltedCh chan struct{}) {
+	for {
+		select {
+		case <-haltedCh:
+			return
+		case <-c.notify:
+			msgs := c.takeBuffered()
+			for i, msg := range msgs {
+				select {
+				case c.received <- msg:
+				case <-haltedCh:
+					// put back whatever was not forwarded
+					c.Lock()
+					c.buffered = append(msgs[i:], c.buffered...)
+					c.Unlock()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Config returns the information required by other clients to send messages to this one.
+func (c *Client) Config() config.ClientConfig {
+	return c.network.clientConfig(c)
+}
+
+func (c *Client) Start() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.haltedCh != nil {
+		return nil
+	}
+	c.haltedCh = make(chan struct{})
+	go c.forwardReceived(c.haltedCh)
+	// make sure anything received while we were offline is forwarded
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *Client) Shutdown() {
+	c.Lock()
+	defer c.Unlock()
+	if c.haltedCh != nil {
+		close(c.haltedCh)
+		c.haltedCh = nil
+	}
+}
+
+func (c *Client) SendMessage(message []byte, recipient config.ClientConfig) error {
+	if !c.isStarted() {
+		return ErrNotStarted
+	}
+	return c.network.send(message, recipient)
+}
+
+// Flush returns straight away, as the messages are handed over to the network as soon as they are sent.
+func (c *Client) Flush() error {
+	return nil
+}
+
+func (c *Client) Received() <-chan []byte {
+	return c.received
+}
+
+func (c *Client) PublicKey() []byte {
+	return c.publicKey
+}
+
+func (c *Client) ProviderPublicKey() []byte {
+	return c.network.providerKey
+}
+
+func (c *Client) DeriveKey(purpose string, size int) ([]byte, error) {
+	return transport.DeriveKey(c.privateKey, purpose, size)
+}
+
+func (c *Client) SharedKey(peerPublicKey []byte, purpose string, size int) ([]byte, error) {
+	return transport.SharedKey(c.privateKey, peerPublicKey, purpose, size)
+}
+
+func (c *Client) UpdateNetworkView() error {
+	// the view is always up to date
+	return nil
+}
+
+func (c *Client) Clients() []config.ClientConfig {
+	return c.network.startedClients()
+}
+
+func (c *Client) Providers() []config.MixConfig {
+	// all clients share the single simulated provider
+	return []config.MixConfig{c.network.providerConfig()}
+}
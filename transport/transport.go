@@ -0,0 +1,258 @@
+// Package transport defines the operations the chat client requires from the underlying network,
+// so that it is not tied to the actual mixnet client.
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/nymtech/nym-mixnet/client"
+	clientConfig "github.com/nymtech/nym-mixnet/client/config"
+	"github.com/nymtech/nym-mixnet/config"
+	"github.com/nymtech/nym-mixnet/constants"
+	"github.com/nymtech/nym-mixnet/helpers"
+	"github.com/nymtech/nym-mixnet/helpers/topology"
+	"github.com/nymtech/nym-mixnet/sphinx"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMinReceivedPollInterval = 10 * time.Millisecond
+	defaultMaxReceivedPollInterval = 500 * time.Millisecond
+
+	// payload of the cover messages the base client sends to itself, which it drops once they return
+	loopCoverMessage = "LoopCoverMessage"
+)
+
+var ErrInvalidPeerKey = errors.New("the public key of the other client is not valid")
+
+// Transport is the network used by the chat client to exchange messages with other clients
+type Transport interface {
+	// Start connects to the network. No other methods, apart from the key getters, can be used before it.
+	Start() error
+	// Shutdown disconnects from the network.
+	Shutdown()
+	// SendMessage sends the message to the specified recipient.
+	SendMessage(message []byte, recipient config.ClientConfig) error
+	// Flush blocks until all messages passed to SendMessage so far have been written to the network.
+	Flush() error
+	// Received returns the channel on which all received messages are pushed as soon as they arrive.
+	// If nobody reads from it, the messages are buffered by the transport.
+	Received() <-chan []byte
+	// PublicKey returns our own public key.
+	PublicKey() []byte
+	// ProviderPublicKey returns the public key of our provider.
+	ProviderPublicKey() []byte
+	// DeriveKey returns the key of given size derived from our private key for the specified purpose,
+	// so that the keys of the chat client itself do not have to be generated and stored on their own.
+	DeriveKey(purpose string, size int) ([]byte, error)
+	// SharedKey returns the key of given size derived for the specified purpose from our private key and the public key
+	// of another client. The other client derives the same key from its private key and our public key,
+	// so nobody else but the two of us can know it.
+	SharedKey(peerPublicKey []byte, purpose string, size int) ([]byte, error)
+	// UpdateNetworkView fetches the current network view.
+	UpdateNetworkView() error
+	// Clients returns all clients present in the last fetched network view.
+	Clients() []config.ClientConfig
+	// Providers returns all providers present in the last fetched network view,
+	// including the ones the registration of some clients has not propagated to yet.
+	Providers() []config.MixConfig
+}
+
+// DeriveKey derives the key of given size for the specified purpose from the private key with HKDF-SHA256.
+// Different purposes always result in unrelated keys.
+func DeriveKey(privateKey []byte, purpose string, size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, privateKey, nil, []byte(purpose)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SharedKey derives the key of given size for the specified purpose from the X25519 shared secret
+// of the private key and the public key of the peer with HKDF-SHA256.
+func SharedKey(privateKey, peerPublicKey []byte, purpose string, size int) ([]byte, error) {
+	if len(privateKey) != sphinx.PrivateKeySize || len(peerPublicKey) != sphinx.PublicKeySize {
+		return nil, ErrInvalidPeerKey
+	}
+	var secret, scalar, point [32]byte
+	copy(scalar[:], privateKey)
+	copy(point[:], peerPublicKey)
+	curve25519.ScalarMult(&secret, &scalar, &point)
+	// the public keys of low order result in the secret anybody can compute
+	if secret == [32]byte{} {
+		return nil, ErrInvalidPeerKey
+	}
+	return DeriveKey(secret[:], purpose, size)
+}
+
+// Mixnet is the Transport using the actual mixnet via the base mixnet client
+type Mixnet struct {
+	*client.NetClient
+	cfg             *clientConfig.Config
+	privateKey      *sphinx.PrivateKey
+	received        chan []byte
+	minPollInterval time.Duration
+	maxPollInterval time.Duration
+	haltedCh        chan struct{}
+	haltOnce        sync.Once
+	// the network view might be refreshed in the background while somebody else is reading it
+	networkMu sync.RWMutex
+	providers []config.MixConfig
+}
+
+func (m *Mixnet) Start() error {
+	if err := m.NetClient.Start(); err != nil {
+		return err
+	}
+	// the base client does not keep the providers, so fetch the view once more
+	if err := m.UpdateNetworkView(); err != nil {
+		m.NetClient.Shutdown()
+		return err
+	}
+	go m.forwardReceived()
+	return nil
+}
+
+func (m *Mixnet) Shutdown() {
+	m.haltOnce.Do(func() {
+		close(m.haltedCh)
+		m.NetClient.Shutdown()
+	})
+}
+
+// forwardReceived moves the messages from the buffer of the base client to the channel.
+// The base client does not notify about new messages, so its buffer has to be checked periodically,
+// but to not waste CPU when idle, the interval is increased every time nothing was received
+// and goes back to the minimum as soon as there is some traffic.
+func (m *Mixnet) forwardReceived() {
+	interval := m.minPollInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.haltedCh:
+			return
+		case <-timer.C:
+			msgs := m.GetReceivedMessages()
+			if len(msgs) == 0 {
+				interval *= 2
+				if interval > m.maxPollInterval {
+					interval = m.maxPollInterval
+				}
+			} else {
+				interval = m.minPollInterval
+			}
+			for _, msg := range msgs {
+				select {
+				case m.received <- msg:
+				case <-m.haltedCh:
+					return
+				}
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// Flush passes a loop cover message to the outgoing queue of the base client. The queue sends one packet at a time
+// and SendMessage blocks until the packet is picked up, so once it returns, everything before it has been written
+// to the provider. The loop message merely takes the place of the cover message the queue would have sent otherwise.
+func (m *Mixnet) Flush() error {
+	return m.NetClient.SendMessage([]byte(loopCoverMessage), config.ClientConfig{
+		Id:       base64.URLEncoding.EncodeToString(m.GetPublicKey().Bytes()),
+		PubKey:   m.GetPublicKey().Bytes(),
+		Provider: &m.Provider,
+	})
+}
+
+func (m *Mixnet) Received() <-chan []byte {
+	return m.received
+}
+
+func (m *Mixnet) PublicKey() []byte {
+	return m.GetPublicKey().Bytes()
+}
+
+func (m *Mixnet) ProviderPublicKey() []byte {
+	return m.Provider.PubKey
+}
+
+func (m *Mixnet) DeriveKey(purpose string, size int) ([]byte, error) {
+	return DeriveKey(m.privateKey.Bytes(), purpose, size)
+}
+
+func (m *Mixnet) SharedKey(peerPublicKey []byte, purpose string, size int) ([]byte, error) {
+	return SharedKey(m.privateKey.Bytes(), peerPublicKey, purpose, size)
+}
+
+// UpdateNetworkView does the same as the base client, but it also remembers all of the providers.
+func (m *Mixnet) UpdateNetworkView() error {
+	newTopology, err := topology.GetNetworkTopology(m.cfg.Client.DirectoryServerTopologyEndpoint)
+	if err != nil {
+		return err
+	}
+
+	m.networkMu.Lock()
+	defer m.networkMu.Unlock()
+	if err := m.ReadInNetworkFromTopology(newTopology); err != nil {
+		return err
+	}
+	m.providers = make([]config.MixConfig, 0, len(newTopology.MixProviderNodes))
+	for _, presence := range newTopology.MixProviderNodes {
+		if provider, err := topology.ProviderPresenceToConfig(presence); err == nil {
+			m.providers = append(m.providers, provider)
+		}
+	}
+	return nil
+}
+
+func (m *Mixnet) Clients() []config.ClientConfig {
+	m.networkMu.RLock()
+	defer m.networkMu.RUnlock()
+	return append([]config.ClientConfig{}, m.Network.Clients...)
+}
+
+func (m *Mixnet) Providers() []config.MixConfig {
+	m.networkMu.RLock()
+	defer m.networkMu.RUnlock()
+	return append([]config.MixConfig{}, m.providers...)
+}
+
+// NewMixnet creates the base mixnet client with the provided configuration.
+// Received messages are checked for with the interval between minPollInterval and maxPollInterval,
+// depending on the traffic. Non-positive values are replaced with the defaults.
+func NewMixnet(cfg *clientConfig.Config, minPollInterval, maxPollInterval time.Duration) (*Mixnet, error) {
+	if minPollInterval <= 0 {
+		minPollInterval = defaultMinReceivedPollInterval
+	}
+	if maxPollInterval <= 0 {
+		maxPollInterval = defaultMaxReceivedPollInterval
+	}
+	if maxPollInterval < minPollInterval {
+		maxPollInterval = minPollInterval
+	}
+
+	baseClient, err := client.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the base client does not expose its private key, so it has to be loaded once more
+	privateKey := new(sphinx.PrivateKey)
+	if err := helpers.FromPEMFile(privateKey, cfg.Client.PrivateKeyFile(), constants.PrivateKeyPEMType); err != nil {
+		return nil, err
+	}
+	return &Mixnet{
+		NetClient:       baseClient,
+		cfg:             cfg,
+		privateKey:      privateKey,
+		received:        make(chan []byte),
+		minPollInterval: minPollInterval,
+		maxPollInterval: maxPollInterval,
+		haltedCh:        make(chan struct{}),
+	}, nil
+}
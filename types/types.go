@@ -1,18 +1,28 @@
 // various types related to chat client. Mostly to deal with circular dependencies
 package types
 
-import "github.com/nymtech/nym-mixnet/config"
+import (
+	"github.com/nymtech/nym-mixnet/config"
+	"time"
+)
 
 type Session struct {
 	recipient      config.ClientConfig
 	recipientAlias string
-	sessionNonce   int64
+	// the messages are sent to the group instead of the recipient while it is open
+	groupID   string
+	groupName string
 }
 
 func (s *Session) Recipient() config.ClientConfig {
 	return s.recipient
 }
 
+// UpdateRecipient replaces the recipient, for example when it has switched to another endpoint
+func (s *Session) UpdateRecipient(recipient config.ClientConfig) {
+	s.recipient = recipient
+}
+
 func (s *Session) UpdateAlias(alias string) {
 	s.recipientAlias = alias
 }
@@ -21,15 +31,273 @@ func (s *Session) RecipientAlias() string {
 	return s.recipientAlias
 }
 
-func (s *Session) IncrementNonce() int64 {
-	s.sessionNonce++
-	return s.sessionNonce
+// OpenGroup makes the group the target of the messages instead of the recipient
+func (s *Session) OpenGroup(id, name string) {
+	s.groupID = id
+	s.groupName = name
+}
+
+// CloseGroup makes the recipient the target of the messages again
+func (s *Session) CloseGroup() {
+	s.groupID = ""
+	s.groupName = ""
+}
+
+// Group returns the ID of the open group or an empty string if the messages are sent to the recipient
+func (s *Session) Group() string {
+	return s.groupID
+}
+
+func (s *Session) GroupName() string {
+	return s.groupName
 }
 
 func NewSession(recipient config.ClientConfig, alias string) *Session {
 	return &Session{
 		recipient:      recipient,
 		recipientAlias: alias,
-		sessionNonce:   0,
 	}
 }
+
+// Contact is the representation of a client exposed outside the chat client itself, for example by the daemon API.
+// Keys are encoded in base64. When it is used to point at a particular client,
+// either the alias or both of the keys have to be specified.
+type Contact struct {
+	Alias             string `json:"alias,omitempty"`
+	PublicKey         string `json:"publicKey,omitempty"`
+	ProviderPublicKey string `json:"providerPublicKey,omitempty"`
+}
+
+// Endpoint is one of the pairs of keys a contact can be reached at. Messages are sent to the preferred one,
+// while the ones received from any of them belong to the same conversation.
+type Endpoint struct {
+	PublicKey         string `json:"publicKey"`
+	ProviderPublicKey string `json:"providerPublicKey"`
+	Preferred         bool   `json:"preferred"`
+}
+
+// Message is the representation of a chat message exposed outside the chat client itself
+type Message struct {
+	// Sender is the display name of the sender, i.e. its alias if one was assigned
+	Sender                  string    `json:"sender"`
+	SenderPublicKey         string    `json:"senderPublicKey"`
+	SenderProviderPublicKey string    `json:"senderProviderPublicKey"`
+	Content                 string    `json:"content"`
+	SentAt                  time.Time `json:"sentAt"`
+	Outgoing                bool      `json:"outgoing"`
+	// Group is the ID of the group the message was sent to, empty for the direct messages
+	Group string `json:"group,omitempty"`
+}
+
+// ContactRequest holds all messages received from a sender we have not assigned an alias to yet
+type ContactRequest struct {
+	Contact
+	Messages []*Message `json:"messages"`
+}
+
+// ReceivedKind specifies why the user should be notified about the received message
+type ReceivedKind int
+
+const (
+	// ReceivedMessage is a regular message from one of our contacts
+	ReceivedMessage ReceivedKind = iota
+	// ReceivedContactRequest is the first message from a sender without an alias, which was held in the requests inbox
+	ReceivedContactRequest
+	// ReceivedPresence means one of our contacts has appeared in or disappeared from the network view.
+	// Such notifications carry no message.
+	ReceivedPresence
+	// ReceivedKeyChange means keys different from the pinned ones were seen for one of our contacts.
+	// Such notifications carry no message either, the message that caused it is handled on its own.
+	ReceivedKeyChange
+	// ReceivedProfile means one of our contacts has updated its profile
+	ReceivedProfile
+	// ReceivedGroupMessage means a message was sent to one of our groups
+	ReceivedGroupMessage
+	// ReceivedGroupChange means the membership of one of our groups has changed, possibly by us joining it
+	ReceivedGroupChange
+)
+
+// Received is pushed to the subscribers of the chat client for everything that requires user's attention
+type Received struct {
+	Kind      ReceivedKind
+	Message   *Message
+	Presence  *Presence
+	KeyChange *KeyChange
+	Profile   *ContactProfile
+	// Group is the group the message was sent to, only set alongside ReceivedGroupMessage
+	Group       *Group
+	GroupChange *GroupChange
+}
+
+// Profile is how a client would like to be presented to the others. The name is merely a suggestion,
+// it never replaces the alias assigned by the user on its own.
+type Profile struct {
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status,omitempty"`
+	// UpdatedAt is when the profile was set, or received in case of the profiles of the contacts
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Version is incremented with every change of the profile, so that the outdated ones can be told apart
+	Version uint64 `json:"version,omitempty"`
+}
+
+// ContactProfile is the profile received from a contact. Its alias is the one assigned by the user, if any.
+type ContactProfile struct {
+	Contact
+	Profile
+}
+
+// KeyChangeKind describes how the seen keys differ from the pinned ones
+type KeyChangeKind string
+
+const (
+	// KeyChangeProvider means the pinned public key was seen with a different provider
+	KeyChangeProvider KeyChangeKind = "provider"
+	// KeyChangeName means the pinned name was claimed by a client with a different public key
+	KeyChangeName KeyChangeKind = "name"
+)
+
+const (
+	// KeySourceMessage means the differing keys were those of the sender of a received message
+	KeySourceMessage = "message"
+	// KeySourceAlias means the differing keys were given an alias
+	KeySourceAlias = "alias"
+	// KeySourceInvite means the differing keys came in an invite
+	KeySourceInvite = "invite"
+	// KeySourceProfile means the differing keys came in a profile update
+	KeySourceProfile = "profile update"
+)
+
+// KeyChange is the record of keys, different from the ones seen first, showing up for a contact.
+// It is either legitimate, such as the contact moving to another provider, or somebody trying to impersonate them.
+type KeyChange struct {
+	Kind KeyChangeKind `json:"kind"`
+	At   time.Time     `json:"at"`
+	// Source is what the keys were seen in, one of the KeySource constants
+	Source string `json:"source"`
+	// Pinned are the keys first seen for the contact, including its name
+	Pinned Contact `json:"pinned"`
+	// Seen are the keys that differ from the pinned ones
+	Seen Contact `json:"seen"`
+	// Accepted is set once the user has pinned the seen keys instead
+	Accepted bool `json:"accepted,omitempty"`
+}
+
+// Presence tells whether the client is present in the network view of the directory server.
+// Zero times mean the client has not been seen (since it went offline in case of Since).
+type Presence struct {
+	Contact
+	Online   bool      `json:"online"`
+	Since    time.Time `json:"since,omitempty"`
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+}
+
+// ContactStats are the traffic statistics of a single contact since the client was started.
+// All durations are expressed in nanoseconds when encoded.
+type ContactStats struct {
+	Contact
+	MessagesSent     uint64 `json:"messagesSent"`
+	MessagesReceived uint64 `json:"messagesReceived"`
+	FailedSends      uint64 `json:"failedSends"`
+	BytesSent        uint64 `json:"bytesSent"`
+	BytesReceived    uint64 `json:"bytesReceived"`
+	// SentBytesPerSecond and ReceivedBytesPerSecond are the average throughput since the client was started
+	SentBytesPerSecond     float64 `json:"sentBytesPerSecond"`
+	ReceivedBytesPerSecond float64 `json:"receivedBytesPerSecond"`
+	// AvgSendDuration is how long it took on average for our messages to be picked up by the transport
+	AvgSendDuration time.Duration `json:"avgSendDuration"`
+	// one-way delays are estimated from the timestamps of the received messages, so MinDelay and AvgDelay
+	// are clock-dependent, i.e. they include the offset between the sender's clock and ours, with negative
+	// samples clamped to zero. AvgExtraDelay, i.e. how much longer than the fastest one the messages took
+	// on average, is not affected by the offset, unless the samples were clamped.
+	DelaySamples  uint64        `json:"delaySamples"`
+	MinDelay      time.Duration `json:"minDelay"`
+	AvgDelay      time.Duration `json:"avgDelay"`
+	AvgExtraDelay time.Duration `json:"avgExtraDelay"`
+	LastActivity  time.Time     `json:"lastActivity"`
+}
+
+// Stats are the traffic statistics of the chat client
+type Stats struct {
+	Since    time.Time       `json:"since"`
+	Uptime   time.Duration   `json:"uptime"`
+	Contacts []*ContactStats `json:"contacts"`
+}
+
+// GroupRole defines what the member is allowed to do within the group
+type GroupRole string
+
+const (
+	// GroupRoleOwner is the role of the creator of the group
+	GroupRoleOwner GroupRole = "owner"
+	// GroupRoleAdmin is the role of the members the owner has promoted, allowed to manage the membership
+	GroupRoleAdmin GroupRole = "admin"
+	// GroupRoleMember is the role of everybody else
+	GroupRoleMember GroupRole = "member"
+)
+
+// GroupMember is one of the clients the messages of the group are sent to
+type GroupMember struct {
+	Contact
+	Role GroupRole `json:"role"`
+	// SigningKey is the base64 encoded key the member signs the changes of the group with, empty until it is known
+	SigningKey string `json:"signingKey,omitempty"`
+	// Verified tells whether the user has confirmed the safety number of the conversation with the member
+	Verified bool `json:"verified"`
+}
+
+// Group is a conversation of several clients, in which every message is sent to each of the members separately
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Role is our own role within the group
+	Role GroupRole `json:"role"`
+	// Members are all members of the group apart from us
+	Members []*GroupMember `json:"members"`
+	// Banned are the clients that can not be added to the group again
+	Banned   []Contact `json:"banned,omitempty"`
+	JoinedAt time.Time `json:"joinedAt"`
+	// Version is incremented with every change of the group signed by an admin
+	Version uint64 `json:"version"`
+}
+
+// GroupCheckpoint is the group as it was before the last change signed by an admin, alongside the hash of that change,
+// so that a concurrent change of the same version can replace it
+type GroupCheckpoint struct {
+	Group      *Group `json:"group"`
+	ChangeHash []byte `json:"changeHash"`
+}
+
+// SenderKey is the symmetric key a member of the group encrypts its messages to the group with
+type SenderKey struct {
+	Key        []byte `json:"key"`
+	Generation uint32 `json:"generation"`
+	// PreviousKey is kept for the messages encrypted with it that are still on their way, until PreviousKeyExpiresAt
+	PreviousKey          []byte    `json:"previousKey,omitempty"`
+	PreviousKeyExpiresAt time.Time `json:"previousKeyExpiresAt"`
+}
+
+// GroupChangeKind says what happened to the membership of the group
+type GroupChangeKind string
+
+const (
+	GroupMemberAdded    GroupChangeKind = "added"
+	GroupMemberLeft     GroupChangeKind = "left"
+	GroupMemberRemoved  GroupChangeKind = "removed"
+	GroupMemberBanned   GroupChangeKind = "banned"
+	GroupMemberPromoted GroupChangeKind = "promoted"
+	GroupRenamed        GroupChangeKind = "renamed"
+	// GroupSynced means the group has been replaced by the one of its owner after conflicting changes
+	GroupSynced GroupChangeKind = "synced"
+)
+
+// GroupChange is a change of the membership of the group announced by one of its members
+type GroupChange struct {
+	Group Group           `json:"group"`
+	Kind  GroupChangeKind `json:"kind"`
+	// Member is whom the change is about, which might be us
+	Member Contact `json:"member"`
+	// By is the member who has made the change
+	By Contact `json:"by"`
+	// PreviousName is the name of the group before it was renamed
+	PreviousName string `json:"previousName,omitempty"`
+}
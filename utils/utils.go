@@ -1,6 +1,9 @@
 package utils
 
-import "github.com/nymtech/nym-mixnet/sphinx"
+import (
+	"encoding/base64"
+	"github.com/nymtech/nym-mixnet/sphinx"
+)
 
 func KeysFromBytes(targetKey, targetProvKey []byte) (*sphinx.PublicKey, *sphinx.PublicKey) {
 	if targetKey == nil || targetProvKey == nil {
@@ -18,3 +21,17 @@ func KeysFromBytes(targetKey, targetProvKey []byte) (*sphinx.PublicKey, *sphinx.
 
 	return targetPub, targetProvPub
 }
+
+// KeysFromB64Strings decodes the pair of base64 encoded keys, such as the ones provided by the user in commands
+func KeysFromB64Strings(targetKey, targetProvKey string) (*sphinx.PublicKey, *sphinx.PublicKey) {
+	targetKeyB, err := base64.URLEncoding.DecodeString(targetKey)
+	if err != nil {
+		return nil, nil
+	}
+	targetProvKeyB, err := base64.URLEncoding.DecodeString(targetProvKey)
+	if err != nil {
+		return nil, nil
+	}
+
+	return KeysFromBytes(targetKeyB, targetProvKeyB)
+}